@@ -0,0 +1,82 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ALACConfig is the ALACSpecificConfig magic cookie of an alac box.
+//
+//	struct ALACSpecificConfig {
+//		uint32 frameLength;
+//		uint8  compatibleVersion;
+//		uint8  bitDepth;
+//		uint8  pb, mb, kb;   // rice parameters
+//		uint8  numChannels;
+//		uint16 maxRun;
+//		uint32 maxFrameBytes;
+//		uint32 avgBitRate;
+//		uint32 sampleRate;
+//	}
+type ALACConfig struct {
+	FrameLength        uint32
+	CompatibleVersion  byte
+	BitDepth           byte
+	RiceHistoryMult    byte // pb
+	RiceInitialHistory byte // mb
+	RiceLimit          byte // kb
+	NumChannels        byte
+	MaxRun             uint16
+	MaxFrameBytes      uint32 // 0 when unknown
+	AvgBitRate         uint32 // 0 when unknown
+	SampleRate         uint32
+}
+
+// MaxBitrate derives the peak bitrate from the largest frame, or 0 when the
+// cookie does not give it.
+func (c *ALACConfig) MaxBitrate() uint32 {
+	if c.MaxFrameBytes == 0 || c.FrameLength == 0 {
+		return 0
+	}
+	return uint32(uint64(c.MaxFrameBytes) * 8 * uint64(c.SampleRate) / uint64(c.FrameLength))
+}
+
+// parseALACConfig decodes the payload of an alac box. The ALACSpecificBox
+// of an ISO sample entry is a full box; the magic cookie inside a QuickTime
+// wave box has no version and flags.
+func parseALACConfig(buf []byte, fullBox bool) (*ALACConfig, error) {
+	if fullBox {
+		if len(buf) < 4 {
+			return nil, fmt.Errorf("alac too short: %d bytes", len(buf))
+		}
+		buf = buf[4:]
+	}
+	if len(buf) < 24 {
+		return nil, fmt.Errorf("alac magic cookie too short: %d bytes", len(buf))
+	}
+	return &ALACConfig{
+		FrameLength:        binary.BigEndian.Uint32(buf[0:]),
+		CompatibleVersion:  buf[4],
+		BitDepth:           buf[5],
+		RiceHistoryMult:    buf[6],
+		RiceInitialHistory: buf[7],
+		RiceLimit:          buf[8],
+		NumChannels:        buf[9],
+		MaxRun:             binary.BigEndian.Uint16(buf[10:]),
+		MaxFrameBytes:      binary.BigEndian.Uint32(buf[12:]),
+		AvgBitRate:         binary.BigEndian.Uint32(buf[16:]),
+		SampleRate:         binary.BigEndian.Uint32(buf[20:]),
+	}, nil
+}
+
+func applyALACConfig(info *TrackInfo, cfg *ALACConfig) {
+	info.ALAC = cfg
+	info.CodecName = "ALAC"
+	info.SampleRate = cfg.SampleRate
+	info.Channels = uint16(cfg.NumChannels)
+	info.SampleSize = uint16(cfg.BitDepth)
+	if cfg.AvgBitRate > 0 {
+		info.Bitrate = cfg.AvgBitRate
+	}
+	info.MaxBitrate = cfg.MaxBitrate()
+}
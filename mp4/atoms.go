@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 // 解析不同的Atom类型
@@ -35,6 +37,17 @@ const (
 	BoxTypeUDTA = "udta"
 	BoxTypeMDAT = "mdat"
 	BoxTypeIODS = "iods"
+	BoxTypeEMSG = "emsg"
+	BoxTypeMVEX = "mvex"
+	BoxTypeTREX = "trex"
+	BoxTypeMOOF = "moof"
+	BoxTypeMFHD = "mfhd"
+	BoxTypeTRAF = "traf"
+	BoxTypeTFHD = "tfhd"
+	BoxTypeTFDT = "tfdt"
+	BoxTypeTRUN = "trun"
+	BoxTypePRFT = "prft"
+	BoxTypeSTYP = "styp"
 )
 
 // parseStsd parses a stsd box from the current position of an io.ReadSeeker.
@@ -88,6 +101,7 @@ func parseStsd(rs io.ReadSeeker, stsdSize uint32, info *TrackInfo) error {
 		return fmt.Errorf("read stsd version/flags: %w", err)
 	}
 	payloadRemaining -= 4
+	stsdVersion := verFlags[0]
 
 	// read entry_count (uint32)
 	var entryCount uint32
@@ -123,7 +137,7 @@ func parseStsd(rs io.ReadSeeker, stsdSize uint32, info *TrackInfo) error {
 		payloadRemaining -= int64(8)
 		entryPayloadSize := int64(entrySize) - 8 // remaining bytes inside this sample entry
 
-		if entrySize < 8 {
+		if entrySize < 8 || entryPayloadSize > payloadRemaining {
 			return fmt.Errorf("invalid sample entry size %d", entrySize)
 		}
 
@@ -172,7 +186,8 @@ func parseStsd(rs io.ReadSeeker, stsdSize uint32, info *TrackInfo) error {
 		payloadRemaining -= 8
 
 		switch entryType {
-		case "avc1", "encv", "avc3":
+		case "avc1", "encv", "avc3", "hvc1", "hev1", "av01", "vp08", "vp09", "vvc1", "vvi1",
+			"dvh1", "dvhe", "dva1", "dvav":
 			// Visual sample entry: parse fields described in ISO/IEC 14496-12
 			// next 16 bytes: pre_defined (2), reserved (2), pre_defined[3] (12)
 			if entryPayloadSize < 16 {
@@ -220,115 +235,109 @@ func parseStsd(rs io.ReadSeeker, stsdSize uint32, info *TrackInfo) error {
 			entryPayloadSize -= skipBytes
 			payloadRemaining -= skipBytes
 
-			// Now inside the sample entry there may be child boxes (avcC, btrt, pasp ...)
-			// parse them by reading child box headers until we've consumed entryPayloadSize
-			for entryPayloadSize > 0 {
-				// read child box header
-				var childSize uint32
-				var childType [4]byte
-				if err := binary.Read(rs, binary.BigEndian, &childSize); err != nil {
-					return fmt.Errorf("read child size: %w", err)
-				}
-				if _, err := io.ReadFull(rs, childType[:]); err != nil {
-					return fmt.Errorf("read child type: %w", err)
-				}
-				if childSize == 0 {
-					// child extends to end of parent
-					childSize = uint32(entryPayloadSize)
-				}
-				childBodySize := int64(childSize - 8)
-				entryPayloadSize -= int64(childSize)
-				payloadRemaining -= int64(childSize)
-
-				switch string(childType[:]) {
-				case "avcC":
-					// read avcC box into info.AVCConfig
-					buf := make([]byte, childBodySize)
-					if _, err := io.ReadFull(rs, buf); err != nil {
-						return fmt.Errorf("read avcC: %w", err)
-					}
-					// info.AVCConfig = buf
-				default:
-					// skip unknown child
-					if _, err := rs.Seek(childBodySize, io.SeekCurrent); err != nil {
-						return fmt.Errorf("skip child %s: %w", string(childType[:]), err)
-					}
+			// child boxes of the sample entry (avcC, btrt, pasp ...)
+			if entryPayloadSize > 0 {
+				buf := make([]byte, entryPayloadSize)
+				if _, err := io.ReadFull(rs, buf); err != nil {
+					return fmt.Errorf("read visual sample entry children: %w", err)
 				}
+				payloadRemaining -= entryPayloadSize
+				entryPayloadSize = 0
+				parseVisualChildBoxes(buf, info)
 			}
-
-		case "mp4a", "enca":
-			// Audio sample entry
-			// after data_reference_index there's 8 bytes reserved, then:
-			// channelcount(2), samplesize(2), pre_defined(2), reserved(2), samplerate(32 as 16.16)
-			if entryPayloadSize < 8+2+2+2+2+4 {
+			finishVisualEntry(info)
+
+		case "mp4a", "enca", "lpcm", "sowt", "twos", "in24", "in32", "fl32", "fl64",
+			"raw ", "NONE", "ulaw", "alaw", "ima4", ".mp3", "alac", "Opus", "fLaC", "ac-3", "ec-3", "ac-4",
+			"mha1", "mha2", "mhm1", "mhm2", "dtsc", "dtsh", "dtsl", "dtse", "dtsx", "dtsy":
+			// Audio sample entry (ISO/IEC 14496-12 12.2.3), laid out like
+			// the QuickTime sound description:
+			// version(2), revision(2), vendor(4), channelcount(2), samplesize(2),
+			// compression_id(2), packet_size(2), samplerate(32 as 16.16)
+			if entryPayloadSize < 20 {
 				if _, err := rs.Seek(entryStart+int64(entrySize), io.SeekStart); err != nil {
 					return err
 				}
 				payloadRemaining -= int64(entrySize - 8)
 				continue
 			}
-			// skip 8 reserved
-			if _, err := rs.Seek(8, io.SeekCurrent); err != nil {
-				return err
+			var fields [20]byte
+			if _, err := io.ReadFull(rs, fields[:]); err != nil {
+				return fmt.Errorf("read audio sample entry: %w", err)
 			}
-			entryPayloadSize -= 8
-			payloadRemaining -= 8
-
-			var channelCount uint16
-			var sampleSize uint16
-			if err := binary.Read(rs, binary.BigEndian, &channelCount); err != nil {
-				return fmt.Errorf("read channelCount: %w", err)
+			entryPayloadSize -= 20
+			payloadRemaining -= 20
+
+			entryVersion := binary.BigEndian.Uint16(fields[0:])
+			info.Channels = binary.BigEndian.Uint16(fields[8:])
+			info.SampleSize = binary.BigEndian.Uint16(fields[10:])
+			info.SampleRate = binary.BigEndian.Uint32(fields[16:]) >> 16
+
+			// ISO AudioSampleEntryV1 only exists in version 1 stsd boxes and
+			// has no extra fields, its sample rate comes from srat. In
+			// version 0 stsd boxes versions 1 and 2 are QuickTime sound
+			// descriptions with extra fields.
+			extra := int64(0)
+			if stsdVersion == 0 {
+				switch entryVersion {
+				case 1:
+					extra = 16
+				case 2:
+					extra = 36
+				}
 			}
-			if err := binary.Read(rs, binary.BigEndian, &sampleSize); err != nil {
-				return fmt.Errorf("read sampleSize: %w", err)
+			if extra > 0 {
+				if entryPayloadSize < extra {
+					return fmt.Errorf("sound description v%d too short: %d bytes", entryVersion, entryPayloadSize)
+				}
+				buf := make([]byte, extra)
+				if _, err := io.ReadFull(rs, buf); err != nil {
+					return fmt.Errorf("read sound description v%d: %w", entryVersion, err)
+				}
+				entryPayloadSize -= extra
+				payloadRemaining -= extra
+				if entryVersion == 1 {
+					applySoundDescriptionV1(info, buf)
+				} else {
+					applySoundDescriptionV2(info, buf)
+				}
 			}
-			entryPayloadSize -= 4
-			payloadRemaining -= 4
 
-			// skip pre_defined + reserved
-			if _, err := rs.Seek(4, io.SeekCurrent); err != nil {
-				return err
+			// parse child boxes inside audio sample entry (esds, srat, wave ...)
+			if entryPayloadSize > 0 {
+				buf := make([]byte, entryPayloadSize)
+				if _, err := io.ReadFull(rs, buf); err != nil {
+					return fmt.Errorf("read audio sample entry children: %w", err)
+				}
+				payloadRemaining -= entryPayloadSize
+				entryPayloadSize = 0
+				parseAudioChildBoxes(buf, info, false)
 			}
-			entryPayloadSize -= 4
-			payloadRemaining -= 4
 
-			// read sample rate (32-bit 16.16 fixed)
-			var sampleRate uint32
-			if err := binary.Read(rs, binary.BigEndian, &sampleRate); err != nil {
-				return fmt.Errorf("read sampleRate: %w", err)
+		case "tx3g", "text", "wvtt", "stpp", "c608":
+			// text, subtitle and caption sample entries
+			buf := make([]byte, entryPayloadSize)
+			if _, err := io.ReadFull(rs, buf); err != nil {
+				return fmt.Errorf("read %s sample entry: %w", entryType, err)
+			}
+			payloadRemaining -= entryPayloadSize
+			entryPayloadSize = 0
+			if err := parseSubtitleSampleEntry(entryType, buf, info); err != nil {
+				info.Warnings = append(info.Warnings, boxWarning(entryType, err))
 			}
-			entryPayloadSize -= 4
-			payloadRemaining -= 4
 
-			// parse child boxes inside audio sample entry
-			for entryPayloadSize > 0 {
-				var childSize uint32
-				var childType [4]byte
-				if err := binary.Read(rs, binary.BigEndian, &childSize); err != nil {
-					return err
-				}
-				if _, err := io.ReadFull(rs, childType[:]); err != nil {
-					return err
-				}
-				if childSize == 0 {
-					childSize = uint32(entryPayloadSize)
-				}
-				childBodySize := int64(childSize - 8)
-				entryPayloadSize -= int64(childSize)
-				payloadRemaining -= int64(childSize)
-
-				switch string(childType[:]) {
-				case "esds":
-					buf := make([]byte, childBodySize)
-					if _, err := io.ReadFull(rs, buf); err != nil {
-						return fmt.Errorf("read esds: %w", err)
-					}
-					// info.ESDS = buf
-				default:
-					if _, err := rs.Seek(childBodySize, io.SeekCurrent); err != nil {
-						return err
-					}
-				}
+		case "tmcd":
+			buf := make([]byte, entryPayloadSize)
+			if _, err := io.ReadFull(rs, buf); err != nil {
+				return fmt.Errorf("read tmcd sample entry: %w", err)
+			}
+			payloadRemaining -= entryPayloadSize
+			entryPayloadSize = 0
+			tc, err := parseTimecodeSampleEntry(buf)
+			if err != nil {
+				info.Warnings = append(info.Warnings, boxWarning(entryType, err))
+			} else {
+				info.Timecode = tc
 			}
 
 		default:
@@ -359,7 +368,7 @@ func parseStsd(rs io.ReadSeeker, stsdSize uint32, info *TrackInfo) error {
 }
 
 // 解析stts (Decoding Time to Sample) Atom
-func parseStts(r io.Reader, trackInfo *TrackInfo) error {
+func parseStts(r io.Reader, size int64, trackInfo *TrackInfo) error {
 	io.CopyN(io.Discard, r, 4) // 跳过version和flags
 
 	var entryCount uint32
@@ -367,6 +376,10 @@ func parseStts(r io.Reader, trackInfo *TrackInfo) error {
 		return err
 	}
 
+	if int64(entryCount) > (size-8)/8 {
+		return fmt.Errorf("stts: %d entries exceed box", entryCount)
+	}
+
 	var totalSampleCount uint32
 	var totalDuration uint32
 
@@ -376,13 +389,13 @@ func parseStts(r io.Reader, trackInfo *TrackInfo) error {
 		if err := binary.Read(r, binary.BigEndian, &sampleCount); err != nil {
 			return err
 		}
+		if err := binary.Read(r, binary.BigEndian, &sampleDelta); err != nil {
+			return err
+		}
 		entries[i] = TimeToSampleEntry{
 			Count: sampleCount,
 			Delta: sampleDelta,
 		}
-		if err := binary.Read(r, binary.BigEndian, &sampleDelta); err != nil {
-			return err
-		}
 
 		totalSampleCount += sampleCount
 		totalDuration += sampleCount * sampleDelta
@@ -420,17 +433,59 @@ type MVHDBox struct {
 	ModificationTime uint64
 	Timescale        uint32
 	Duration         uint64
-	Rate             uint32   // 播放速率（16.16定点数）
-	Volume           uint16   // 音量（8.8定点数）
-	Matrix           [9]int32 // 变换矩阵
+	Rate             uint32 // 播放速率（16.16定点数）
+	Volume           uint16 // 音量（8.8定点数）
+	Matrix           Matrix // 变换矩阵
 	NextTrackID      uint32
 }
 
+// tkhd flags
+const (
+	TrackEnabled           = 0x000001
+	TrackInMovie           = 0x000002
+	TrackInPreview         = 0x000004
+	TrackSizeIsAspectRatio = 0x000008
+)
+
 type TKHDBox struct {
-	TrackID  uint32
-	Width    uint32
-	Height   uint32
-	Duration uint64
+	Flags            uint32
+	CreationTime     uint64
+	ModificationTime uint64
+	TrackID          uint32
+	Duration         uint64 // in the movie timescale
+	Layer            int16  // lower layers are closer to the viewer
+	AlternateGroup   int16  // 0 when the track has no alternatives
+	Volume           int16  // 8.8 fixed point
+	Width            uint32
+	Height           uint32
+}
+
+// Enabled reports whether the track is enabled for playback.
+func (b *TKHDBox) Enabled() bool { return b.Flags&TrackEnabled != 0 }
+
+// InMovie reports whether the track is used in the presentation.
+func (b *TKHDBox) InMovie() bool { return b.Flags&TrackInMovie != 0 }
+
+// InPreview reports whether the track is used when previewing.
+func (b *TKHDBox) InPreview() bool { return b.Flags&TrackInPreview != 0 }
+
+// SizeIsAspectRatio reports whether Width and Height only give the aspect
+// ratio rather than a size in pixels.
+func (b *TKHDBox) SizeIsAspectRatio() bool { return b.Flags&TrackSizeIsAspectRatio != 0 }
+
+// PlaybackRate returns the preferred playback rate, 1.0 being normal speed.
+func (b *MVHDBox) PlaybackRate() float64 { return float64(int32(b.Rate)) / (1 << 16) }
+
+// PlaybackVolume returns the preferred volume, 1.0 being full volume.
+func (b *MVHDBox) PlaybackVolume() float64 { return float64(int16(b.Volume)) / (1 << 8) }
+
+// TrackVolume returns the track volume, 1.0 being full volume.
+func (b *TKHDBox) TrackVolume() float64 { return float64(b.Volume) / (1 << 8) }
+
+// mp4Time converts seconds since 1904-01-01 00:00:00 UTC, the epoch of
+// mvhd, tkhd and mdhd times, to a time.Time.
+func mp4Time(secs uint64) time.Time {
+	return time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(secs) * time.Second)
 }
 
 type HDLRBox struct {
@@ -474,12 +529,15 @@ type sttsBox struct {
 }
 
 // Parse ctts box (if exists)
-func parseCtts(r io.Reader, info *TrackInfo) error {
+func parseCtts(r io.Reader, size int64, info *TrackInfo) error {
 	var versionFlags uint32
 	binary.Read(r, binary.BigEndian, &versionFlags)
 	version := versionFlags >> 24
 	var entryCount uint32
 	binary.Read(r, binary.BigEndian, &entryCount)
+	if int64(entryCount) > (size-8)/8 {
+		return fmt.Errorf("ctts: %d entries exceed box", entryCount)
+	}
 	items := make([]CompositionOffsetEntry, entryCount)
 	for i := 0; i < int(entryCount); i++ {
 		binary.Read(r, binary.BigEndian, &items[i].Count)
@@ -548,3 +606,147 @@ func DetectDiscontinuities(trackID int64, dts, pts []uint64) []string {
 	}
 	return issues
 }
+
+// parseVisualChildBoxes handles the boxes of a visual sample entry. A broken
+// optional box only produces a warning, so the other tracks are not lost.
+func parseVisualChildBoxes(buf []byte, info *TrackInfo) {
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("visual sample entry: %v", err))
+			return
+		}
+		if err := parseVisualConfig(boxType, body, info); err != nil {
+			info.Warnings = append(info.Warnings, boxWarning(boxType, err))
+		}
+		buf = rest
+	}
+}
+
+// parseVisualConfig decodes a decoder configuration box of a visual sample
+// entry and fills the codec specific track fields.
+func parseVisualConfig(boxType string, buf []byte, info *TrackInfo) error {
+	switch boxType {
+	case "avcC":
+		cfg, err := parseAVCDecoderConfig(buf)
+		if err != nil {
+			return err
+		}
+		applyAVCConfig(info, cfg)
+	case "hvcC":
+		cfg, err := parseHEVCDecoderConfig(buf)
+		if err != nil {
+			return err
+		}
+		applyHEVCConfig(info, cfg)
+	case "av1C":
+		cfg, err := parseAV1CodecConfig(buf)
+		if err != nil {
+			return err
+		}
+		applyAV1Config(info, cfg)
+	case "vpcC":
+		cfg, err := parseVPCodecConfig(buf)
+		if err != nil {
+			return err
+		}
+		applyVPCodecConfig(info, cfg)
+	case "vvcC":
+		cfg, err := parseVVCDecoderConfig(buf)
+		if err != nil {
+			return err
+		}
+		applyVVCConfig(info, cfg)
+	case "dvcC", "dvvC", "dvwC":
+		cfg, err := parseDolbyVisionConfig(boxType, buf)
+		if err != nil {
+			return err
+		}
+		applyDolbyVisionConfig(info, cfg)
+	case "colr":
+		colr, err := parseColourBox(buf)
+		if err != nil {
+			return err
+		}
+		info.Colr = colr
+	case "mdcv":
+		m, err := parseMasteringDisplay(buf)
+		if err != nil {
+			return err
+		}
+		info.MasteringDisplay = m
+	case "clli":
+		c, err := parseContentLightLevel(buf)
+		if err != nil {
+			return err
+		}
+		info.ContentLightLevel = c
+	case "pasp":
+		p, err := parsePixelAspectRatio(buf)
+		if err != nil {
+			return err
+		}
+		info.PixelAspect = p
+	case "clap":
+		c, err := parseCleanAperture(buf)
+		if err != nil {
+			return err
+		}
+		info.CleanAperture = c
+	case "fiel":
+		f, err := parseFieldInfo(buf)
+		if err != nil {
+			return err
+		}
+		info.FieldOrder = f
+	}
+	return nil
+}
+
+// boxWarning formats the decoding error of an optional box as a warning,
+// naming the box unless the error already does.
+func boxWarning(boxType string, err error) string {
+	if msg := err.Error(); strings.HasPrefix(msg, boxType) {
+		return msg
+	}
+	return fmt.Sprintf("%s: %v", boxType, err)
+}
+
+// setBitstreamSize records the coded size from the codec's parameter sets
+// and replaces the sample entry size with the cropped one. The sample entry
+// may carry either, anything else only produces a warning.
+func setBitstreamSize(info *TrackInfo, source string, codedWidth, codedHeight, width, height uint32) {
+	info.CodedWidth, info.CodedHeight = codedWidth, codedHeight
+	if info.Width != width || info.Height != height {
+		if info.Width != codedWidth || info.Height != codedHeight {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("sample entry size %dx%d differs from %s size %dx%d (coded %dx%d)",
+				info.Width, info.Height, source, width, height, codedWidth, codedHeight))
+		}
+		info.Width, info.Height = width, height
+	}
+}
+
+// nextBox splits the first box off buf and returns its type, its payload
+// and the data after it. A size of 0 extends the box to the end of buf.
+func nextBox(buf []byte) (string, []byte, []byte, error) {
+	if len(buf) < 8 {
+		return "", nil, nil, fmt.Errorf("truncated box header: %d bytes", len(buf))
+	}
+	size := uint64(binary.BigEndian.Uint32(buf))
+	boxType := string(buf[4:8])
+	header := uint64(8)
+	switch size {
+	case 0:
+		size = uint64(len(buf))
+	case 1:
+		if len(buf) < 16 {
+			return "", nil, nil, fmt.Errorf("truncated largesize of %s box", boxType)
+		}
+		size = binary.BigEndian.Uint64(buf[8:])
+		header = 16
+	}
+	if size < header || size > uint64(len(buf)) {
+		return "", nil, nil, fmt.Errorf("invalid size %d of %s box", size, boxType)
+	}
+	return boxType, buf[header:size], buf[size:], nil
+}
@@ -0,0 +1,73 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testBox builds a box with a 32 bit size.
+func testBox(boxType string, payload []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	return append(append(b, boxType...), payload...)
+}
+
+func TestNextBox(t *testing.T) {
+	large := append(binary.BigEndian.AppendUint32(nil, 1), "free"...)
+	large = binary.BigEndian.AppendUint64(large, 18)
+	large = append(large, "ab"...)
+
+	tests := []struct {
+		name     string
+		buf      []byte
+		wantType string
+		wantBody string
+		wantRest string
+		wantErr  bool
+	}{
+		{"plain", append(testBox("abcd", []byte("xy")), "rest"...), "abcd", "xy", "rest", false},
+		{"empty payload", testBox("free", nil), "free", "", "", false},
+		{"size 0 runs to end", append([]byte{0, 0, 0, 0}, "mdatdata"...), "mdat", "data", "", false},
+		{"largesize", large, "free", "ab", "", false},
+		{"truncated header", []byte{0, 0, 0, 8, 'a'}, "", "", "", true},
+		{"size below header", []byte{0, 0, 0, 7, 'a', 'b', 'c', 'd'}, "", "", "", true},
+		{"size past end", []byte{0, 0, 0, 12, 'a', 'b', 'c', 'd'}, "", "", "", true},
+		{"truncated largesize", []byte{0, 0, 0, 1, 'a', 'b', 'c', 'd', 0}, "", "", "", true},
+		{"largesize below header", append([]byte{0, 0, 0, 1, 'a', 'b', 'c', 'd'}, 0, 0, 0, 0, 0, 0, 0, 8), "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxType, body, rest, err := nextBox(tt.buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nextBox() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if boxType != tt.wantType || string(body) != tt.wantBody || string(rest) != tt.wantRest {
+				t.Errorf("nextBox() = %q, %q, %q, want %q, %q, %q", boxType, body, rest, tt.wantType, tt.wantBody, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestParseVisualChildBoxesWarnsOnBadSize(t *testing.T) {
+	info := &TrackInfo{}
+	buf := append(testBox("pasp", []byte{0, 0, 0, 4, 0, 0, 0, 3}), 0, 0, 0, 3, 'a', 'v', 'c', 'C')
+	parseVisualChildBoxes(buf, info)
+	if info.PixelAspect == nil {
+		t.Error("pasp before the broken box was not decoded")
+	}
+	if len(info.Warnings) != 1 {
+		t.Errorf("warnings = %q, want one", info.Warnings)
+	}
+}
+
+func TestParseStsdRejectsOversizedEntry(t *testing.T) {
+	payload := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	payload = append(payload, 0x7f, 0xff, 0xff, 0xff, 'a', 'v', 'c', '1')
+	stsd := bytes.NewReader(payload)
+	if err := parseStsd(stsd, uint32(8+len(payload)), &TrackInfo{}); err == nil {
+		t.Error("parseStsd accepted a sample entry larger than the box")
+	}
+}
@@ -0,0 +1,180 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// LPCM format flags of a QuickTime sound description v2
+const (
+	lpcmFlagFloat     = 1 << 0
+	lpcmFlagBigEndian = 1 << 1
+	lpcmFlagSigned    = 1 << 2
+)
+
+// applySoundDescriptionV1 applies the QuickTime sound description v1
+// fields that follow the version 0 layout:
+//
+//	samplesPerPacket(4), bytesPerPacket(4), bytesPerFrame(4), bytesPerSample(4)
+func applySoundDescriptionV1(info *TrackInfo, buf []byte) {
+	info.SamplesPerPacket = binary.BigEndian.Uint32(buf[0:])
+	info.BytesPerFrame = binary.BigEndian.Uint32(buf[8:])
+}
+
+// applySoundDescriptionV2 applies a QuickTime sound description v2. The
+// version 0 fields hold fixed values and the real format follows:
+//
+//	sizeOfStructOnly(4), audioSampleRate(float64), numAudioChannels(4),
+//	always7F000000(4), constBitsPerChannel(4), formatSpecificFlags(4),
+//	constBytesPerAudioPacket(4), constLPCMFramesPerAudioPacket(4)
+//
+// constLPCMFramesPerAudioPacket is what samplesPerPacket holds in version 1,
+// so it is stored in SamplesPerPacket.
+func applySoundDescriptionV2(info *TrackInfo, buf []byte) {
+	info.SampleRate = uint32(math.Round(math.Float64frombits(binary.BigEndian.Uint64(buf[4:]))))
+	info.Channels = uint16(binary.BigEndian.Uint32(buf[12:]))
+	info.SampleSize = uint16(binary.BigEndian.Uint32(buf[20:]))
+	info.AudioFormatFlags = binary.BigEndian.Uint32(buf[24:])
+	info.BytesPerPacket = binary.BigEndian.Uint32(buf[28:])
+	info.SamplesPerPacket = binary.BigEndian.Uint32(buf[32:])
+}
+
+// parseAudioChildBoxes handles the boxes of an audio sample entry. QuickTime
+// files nest the decoder configuration inside a wave box. Boxes that fail
+// to decode only produce a warning, so the rest of the file is still
+// parsed. inWave is set for the boxes of a wave box.
+func parseAudioChildBoxes(buf []byte, info *TrackInfo, inWave bool) {
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("audio sample entry: %v", err))
+			return
+		}
+		if err := parseAudioChildBox(boxType, body, info, inWave); err != nil {
+			info.Warnings = append(info.Warnings, boxWarning(boxType, err))
+		}
+		buf = rest
+	}
+}
+
+func parseAudioChildBox(boxType string, body []byte, info *TrackInfo, inWave bool) error {
+	switch boxType {
+	case "esds":
+		es, err := parseEsds(body)
+		if err != nil {
+			return err
+		}
+		applyEsds(info, es)
+	case "dOps":
+		cfg, err := parseOpusConfig(body)
+		if err != nil {
+			return err
+		}
+		applyOpusConfig(info, cfg)
+	case "dfLa":
+		cfg, err := parseFLACConfig(body)
+		if err != nil {
+			return err
+		}
+		applyFLACConfig(info, cfg)
+	case "alac":
+		cfg, err := parseALACConfig(body, !inWave)
+		if err != nil {
+			return err
+		}
+		applyALACConfig(info, cfg)
+	case "dac3":
+		cfg, err := parseAC3Config(body)
+		if err != nil {
+			return err
+		}
+		applyAC3Config(info, cfg)
+	case "dec3":
+		cfg, err := parseEC3Config(body)
+		if err != nil {
+			return err
+		}
+		applyEC3Config(info, cfg)
+	case "dac4":
+		cfg, err := parseAC4Config(body)
+		if err != nil {
+			return err
+		}
+		applyAC4Config(info, cfg)
+	case "mhaC":
+		cfg, err := parseMPEGHConfig(body)
+		if err != nil {
+			return err
+		}
+		applyMPEGHConfig(info, cfg)
+	case "ddts":
+		cfg, err := parseDTSConfig(body)
+		if err != nil {
+			return err
+		}
+		applyDTSConfig(info, cfg)
+	case "udts":
+		cfg, err := parseDTSUHDConfig(body)
+		if err != nil {
+			return err
+		}
+		applyDTSUHDConfig(info, cfg)
+	case "chnl":
+		cfg, err := parseChannelLayoutConfig(body, int(info.Channels))
+		if err != nil {
+			return err
+		}
+		applyChannelLayoutConfig(info, cfg)
+	case "chan":
+		l, err := parseQTChannelLayout(body)
+		if err != nil {
+			return err
+		}
+		applyQTChannelLayout(info, l)
+	case "srat":
+		// SamplingRateBox of AudioSampleEntryV1 (ISO/IEC 14496-12 12.2.3.2)
+		if len(body) < 8 {
+			return fmt.Errorf("srat too short: %d bytes", len(body))
+		}
+		info.SampleRate = binary.BigEndian.Uint32(body[4:])
+	case "wave":
+		parseAudioChildBoxes(body, info, true)
+	}
+	return nil
+}
+
+// LPCMFormat describes the sample format of uncompressed QuickTime audio,
+// e.g. "24 bit signed integer little endian".
+func LPCMFormat(codec string, sampleSize uint16, flags uint32) string {
+	switch codec {
+	case "sowt":
+		return fmt.Sprintf("%d bit signed integer little endian", sampleSize)
+	case "twos":
+		return fmt.Sprintf("%d bit signed integer big endian", sampleSize)
+	case "in24":
+		return "24 bit signed integer big endian"
+	case "in32":
+		return "32 bit signed integer big endian"
+	case "fl32":
+		return "32 bit float big endian"
+	case "fl64":
+		return "64 bit float big endian"
+	case "raw ", "NONE":
+		return fmt.Sprintf("%d bit unsigned integer", sampleSize)
+	case "lpcm":
+		kind := "unsigned integer"
+		if flags&lpcmFlagFloat != 0 {
+			kind = "float"
+		} else if flags&lpcmFlagSigned != 0 {
+			kind = "signed integer"
+		}
+		order := "little endian"
+		if flags&lpcmFlagBigEndian != 0 {
+			order = "big endian"
+		}
+		return fmt.Sprintf("%d bit %s %s", sampleSize, kind, order)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,389 @@
+package mp4
+
+import (
+	"errors"
+	"fmt"
+)
+
+// obu_type of a sequence header OBU (AV1 bitstream specification 6.2.2)
+const av1OBUSequenceHeader = 1
+
+// AV1CodecConfig is a decoded av1C box (AV1 Codec ISO Media File Format
+// Binding 2.3.3).
+//
+//	aligned(8) class AV1CodecConfigurationRecord {
+//		unsigned int(1) marker = 1;
+//		unsigned int(7) version = 1;
+//		unsigned int(3) seq_profile;
+//		unsigned int(5) seq_level_idx_0;
+//		unsigned int(1) seq_tier_0;
+//		unsigned int(1) high_bitdepth;
+//		unsigned int(1) twelve_bit;
+//		unsigned int(1) monochrome;
+//		unsigned int(1) chroma_subsampling_x;
+//		unsigned int(1) chroma_subsampling_y;
+//		unsigned int(2) chroma_sample_position;
+//		unsigned int(3) reserved = 0;
+//		unsigned int(1) initial_presentation_delay_present;
+//		unsigned int(4) initial_presentation_delay_minus_one;
+//		unsigned int(8) configOBUs[];
+//	}
+type AV1CodecConfig struct {
+	Version                  byte
+	SeqProfile               byte
+	SeqLevelIdx              byte
+	SeqTier                  byte
+	HighBitDepth             bool
+	TwelveBit                bool
+	Monochrome               bool
+	ChromaSubsamplingX       bool
+	ChromaSubsamplingY       bool
+	ChromaSamplePosition     byte
+	InitialPresentationDelay byte // 0 when not present
+	ConfigOBUs               []byte
+}
+
+// BitDepth returns the bit depth signalled by the high_bitdepth and
+// twelve_bit flags.
+func (c *AV1CodecConfig) BitDepth() uint8 {
+	switch {
+	case c.TwelveBit:
+		return 12
+	case c.HighBitDepth:
+		return 10
+	default:
+		return 8
+	}
+}
+
+// AV1SequenceHeader is a decoded sequence header OBU (AV1 5.5). Operating
+// points other than the first are skipped.
+type AV1SequenceHeader struct {
+	SeqProfile              uint8
+	StillPicture            bool
+	ReducedStillPicture     bool
+	TimingInfoPresent       bool
+	NumUnitsInDisplayTick   uint32
+	TimeScale               uint32
+	EqualPictureInterval    bool
+	NumTicksPerPicture      uint32
+	OperatingPoints         int
+	SeqLevelIdx             uint8 // of operating point 0
+	SeqTier                 uint8
+	MaxFrameWidth           uint32
+	MaxFrameHeight          uint32
+	Use128x128Superblock    bool
+	EnableOrderHint         bool
+	EnableSuperres          bool
+	EnableCDEF              bool
+	EnableRestoration       bool
+	BitDepth                uint8
+	Monochrome              bool
+	ColorDescription        bool
+	ColorPrimaries          uint8
+	TransferCharacteristics uint8
+	MatrixCoefficients      uint8
+	ColorRange              bool // full range
+	SubsamplingX            bool
+	SubsamplingY            bool
+	ChromaSamplePosition    uint8
+	FilmGrainParamsPresent  bool
+}
+
+// FrameRate returns the frame rate of the timing info when the picture
+// interval is constant, or 0.
+func (s *AV1SequenceHeader) FrameRate() float64 {
+	if !s.TimingInfoPresent || !s.EqualPictureInterval || s.NumUnitsInDisplayTick == 0 {
+		return 0
+	}
+	return float64(s.TimeScale) / (float64(s.NumUnitsInDisplayTick) * float64(s.NumTicksPerPicture))
+}
+
+// ChromaFormat names the chroma subsampling, e.g. "4:2:0".
+func (s *AV1SequenceHeader) ChromaFormat() string {
+	return av1ChromaFormat(s.Monochrome, s.SubsamplingX, s.SubsamplingY)
+}
+
+func av1ChromaFormat(mono, x, y bool) string {
+	switch {
+	case mono:
+		return ChromaFormatName(0)
+	case x && y:
+		return ChromaFormatName(1)
+	case x:
+		return ChromaFormatName(2)
+	default:
+		return ChromaFormatName(3)
+	}
+}
+
+func parseAV1CodecConfig(buf []byte) (*AV1CodecConfig, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("av1C too short: %d bytes", len(buf))
+	}
+	if buf[0]&0x80 == 0 {
+		return nil, errors.New("av1C: marker bit not set")
+	}
+	c := &AV1CodecConfig{
+		Version:              buf[0] & 0x7f,
+		SeqProfile:           buf[1] >> 5,
+		SeqLevelIdx:          buf[1] & 0x1f,
+		SeqTier:              buf[2] >> 7,
+		HighBitDepth:         buf[2]&0x40 != 0,
+		TwelveBit:            buf[2]&0x20 != 0,
+		Monochrome:           buf[2]&0x10 != 0,
+		ChromaSubsamplingX:   buf[2]&0x08 != 0,
+		ChromaSubsamplingY:   buf[2]&0x04 != 0,
+		ChromaSamplePosition: buf[2] & 0x03,
+		ConfigOBUs:           buf[4:],
+	}
+	if buf[3]&0x10 != 0 {
+		c.InitialPresentationDelay = buf[3]&0x0f + 1
+	}
+	return c, nil
+}
+
+// applyAV1Config fills the track fields derived from av1C and the sequence
+// header OBU among its configOBUs.
+func applyAV1Config(info *TrackInfo, cfg *AV1CodecConfig) {
+	info.AV1Config = cfg
+	info.Profile = AV1ProfileName(cfg.SeqProfile)
+	info.Level = AV1LevelName(cfg.SeqLevelIdx, cfg.SeqTier)
+	info.ChromaFormat = av1ChromaFormat(cfg.Monochrome, cfg.ChromaSubsamplingX, cfg.ChromaSubsamplingY)
+	info.BitDepth = cfg.BitDepth()
+
+	obu, err := findAV1OBU(cfg.ConfigOBUs, av1OBUSequenceHeader)
+	if err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("av1C configOBUs: %v", err))
+		return
+	}
+	if obu == nil {
+		return
+	}
+	seq, err := parseAV1SequenceHeader(obu)
+	if err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("av1C sequence header: %v", err))
+		return
+	}
+	info.AV1SequenceHeader = seq
+	if seq.SeqProfile != cfg.SeqProfile || seq.SeqLevelIdx != cfg.SeqLevelIdx {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("av1C profile/level %d/%d differ from sequence header %d/%d",
+			cfg.SeqProfile, cfg.SeqLevelIdx, seq.SeqProfile, seq.SeqLevelIdx))
+	}
+	info.ChromaFormat = seq.ChromaFormat()
+	info.BitDepth = seq.BitDepth
+
+	// frames may be smaller than the maximum, so only a larger sample
+	// entry size is suspicious
+	info.CodedWidth, info.CodedHeight = seq.MaxFrameWidth, seq.MaxFrameHeight
+	if info.Width > seq.MaxFrameWidth || info.Height > seq.MaxFrameHeight {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("sample entry size %dx%d exceeds sequence header max frame size %dx%d",
+			info.Width, info.Height, seq.MaxFrameWidth, seq.MaxFrameHeight))
+	}
+
+	if seq.ColorDescription || seq.ColorRange {
+		info.Color = &ColorInfo{
+			Primaries: uint16(seq.ColorPrimaries),
+			Transfer:  uint16(seq.TransferCharacteristics),
+			Matrix:    uint16(seq.MatrixCoefficients),
+			FullRange: seq.ColorRange,
+			Source:    "AV1 sequence header",
+		}
+	}
+}
+
+// findAV1OBU returns the payload of the first OBU of the given type in a
+// sequence of low overhead bitstream format OBUs, or nil if there is none.
+func findAV1OBU(data []byte, obuType uint8) ([]byte, error) {
+	br := newBitReader(data)
+	for br.bitsLeft() > 0 {
+		br.skipBits(1) // obu_forbidden_bit
+		typ := uint8(br.readBits(4))
+		extension := br.readFlag()
+		hasSize := br.readFlag()
+		br.skipBits(1) // obu_reserved_1bit
+		if extension {
+			br.skipBits(8) // temporal_id, spatial_id, reserved
+		}
+		size := uint64(br.bitsLeft() / 8)
+		if hasSize {
+			size = br.readLEB128()
+		}
+		if br.err != nil {
+			return nil, br.err
+		}
+		if size > uint64(br.bitsLeft()/8) {
+			return nil, fmt.Errorf("OBU size %d exceeds data", size)
+		}
+		payload := br.readBytes(int(size))
+		if typ == obuType {
+			return payload, nil
+		}
+	}
+	return nil, nil
+}
+
+func parseAV1SequenceHeader(obu []byte) (*AV1SequenceHeader, error) {
+	br := newBitReader(obu)
+	s := &AV1SequenceHeader{}
+
+	s.SeqProfile = uint8(br.readBits(3))
+	s.StillPicture = br.readFlag()
+	s.ReducedStillPicture = br.readFlag()
+	if s.ReducedStillPicture {
+		s.OperatingPoints = 1
+		s.SeqLevelIdx = uint8(br.readBits(5))
+	} else {
+		var decoderModelInfoPresent bool
+		bufferDelayLength := 0
+		s.TimingInfoPresent = br.readFlag()
+		if s.TimingInfoPresent {
+			s.NumUnitsInDisplayTick = uint32(br.readBits(32))
+			s.TimeScale = uint32(br.readBits(32))
+			s.EqualPictureInterval = br.readFlag()
+			if s.EqualPictureInterval {
+				s.NumTicksPerPicture = br.readUVLC() + 1
+			}
+			decoderModelInfoPresent = br.readFlag()
+			if decoderModelInfoPresent {
+				bufferDelayLength = int(br.readBits(5)) + 1
+				br.skipBits(32) // num_units_in_decoding_tick
+				br.skipBits(10) // buffer_removal_time_length_minus_1, frame_presentation_time_length_minus_1
+			}
+		}
+		initialDisplayDelayPresent := br.readFlag()
+		s.OperatingPoints = int(br.readBits(5)) + 1
+		for i := 0; i < s.OperatingPoints; i++ {
+			br.skipBits(12) // operating_point_idc
+			level := uint8(br.readBits(5))
+			var tier uint8
+			if level > 7 {
+				tier = uint8(br.readBits(1))
+			}
+			if i == 0 {
+				s.SeqLevelIdx, s.SeqTier = level, tier
+			}
+			if decoderModelInfoPresent && br.readFlag() {
+				br.skipBits(2*bufferDelayLength + 1) // decoder/encoder_buffer_delay, low_delay_mode_flag
+			}
+			if initialDisplayDelayPresent && br.readFlag() {
+				br.skipBits(4) // initial_display_delay_minus_1
+			}
+		}
+	}
+
+	widthBits := int(br.readBits(4)) + 1
+	heightBits := int(br.readBits(4)) + 1
+	s.MaxFrameWidth = uint32(br.readBits(widthBits)) + 1
+	s.MaxFrameHeight = uint32(br.readBits(heightBits)) + 1
+	if !s.ReducedStillPicture && br.readFlag() { // frame_id_numbers_present_flag
+		br.skipBits(7) // delta_frame_id_length_minus_2, additional_frame_id_length_minus_1
+	}
+	s.Use128x128Superblock = br.readFlag()
+	br.skipBits(2) // enable_filter_intra, enable_intra_edge_filter
+	if !s.ReducedStillPicture {
+		br.skipBits(4) // enable_interintra_compound, enable_masked_compound, enable_warped_motion, enable_dual_filter
+		s.EnableOrderHint = br.readFlag()
+		if s.EnableOrderHint {
+			br.skipBits(2) // enable_jnt_comp, enable_ref_frame_mvs
+		}
+		forceScreenContentTools := uint64(2)
+		if !br.readFlag() { // seq_choose_screen_content_tools
+			forceScreenContentTools = br.readBits(1)
+		}
+		if forceScreenContentTools > 0 && !br.readFlag() { // seq_choose_integer_mv
+			br.skipBits(1) // seq_force_integer_mv
+		}
+		if s.EnableOrderHint {
+			br.skipBits(3) // order_hint_bits_minus_1
+		}
+	}
+	s.EnableSuperres = br.readFlag()
+	s.EnableCDEF = br.readFlag()
+	s.EnableRestoration = br.readFlag()
+	parseAV1ColorConfig(br, s)
+	s.FilmGrainParamsPresent = br.readFlag()
+
+	if br.err != nil {
+		return nil, fmt.Errorf("av1 sequence header: %w", br.err)
+	}
+	return s, nil
+}
+
+// parseAV1ColorConfig decodes color_config() (AV1 5.5.2).
+func parseAV1ColorConfig(br *bitReader, s *AV1SequenceHeader) {
+	s.BitDepth = 8
+	if br.readFlag() { // high_bitdepth
+		s.BitDepth = 10
+		if s.SeqProfile == 2 && br.readFlag() { // twelve_bit
+			s.BitDepth = 12
+		}
+	}
+	if s.SeqProfile != 1 {
+		s.Monochrome = br.readFlag()
+	}
+	s.ColorPrimaries, s.TransferCharacteristics, s.MatrixCoefficients = 2, 2, 2
+	s.ColorDescription = br.readFlag()
+	if s.ColorDescription {
+		s.ColorPrimaries = uint8(br.readBits(8))
+		s.TransferCharacteristics = uint8(br.readBits(8))
+		s.MatrixCoefficients = uint8(br.readBits(8))
+	}
+
+	switch {
+	case s.Monochrome:
+		s.ColorRange = br.readFlag()
+		s.SubsamplingX, s.SubsamplingY = true, true
+		return
+	case s.ColorPrimaries == 1 && s.TransferCharacteristics == 13 && s.MatrixCoefficients == 0:
+		// sRGB implies full range 4:4:4
+		s.ColorRange = true
+	default:
+		s.ColorRange = br.readFlag()
+		switch s.SeqProfile {
+		case 0:
+			s.SubsamplingX, s.SubsamplingY = true, true
+		case 1:
+		default:
+			if s.BitDepth == 12 {
+				s.SubsamplingX = br.readFlag()
+				if s.SubsamplingX {
+					s.SubsamplingY = br.readFlag()
+				}
+			} else {
+				s.SubsamplingX = true
+			}
+		}
+		if s.SubsamplingX && s.SubsamplingY {
+			s.ChromaSamplePosition = uint8(br.readBits(2))
+		}
+	}
+	br.skipBits(1) // separate_uv_delta_q
+}
+
+// AV1ProfileName names a seq_profile.
+func AV1ProfileName(profile byte) string {
+	switch profile {
+	case 0:
+		return "Main"
+	case 1:
+		return "High"
+	case 2:
+		return "Professional"
+	default:
+		return fmt.Sprintf("Unknown (%d)", profile)
+	}
+}
+
+// AV1LevelName formats a seq_level_idx as "X.Y" (X = 2 + idx>>2, Y = idx&3),
+// adding the tier when the high tier is signalled.
+func AV1LevelName(idx, tier byte) string {
+	if idx == 31 {
+		return "Max"
+	}
+	name := fmt.Sprintf("%d.%d", 2+idx>>2, idx&3)
+	if tier == 1 {
+		name += " High tier"
+	}
+	return name
+}
@@ -0,0 +1,258 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// AVCDecoderConfig is a decoded avcC box (ISO/IEC 14496-15 5.3.3.1).
+//
+//	aligned(8) class AVCDecoderConfigurationRecord {
+//		unsigned int(8) configurationVersion = 1;
+//		unsigned int(8) AVCProfileIndication;
+//		unsigned int(8) profile_compatibility;
+//		unsigned int(8) AVCLevelIndication;
+//		bit(6) reserved = '111111'b;
+//		unsigned int(2) lengthSizeMinusOne;
+//		bit(3) reserved = '111'b;
+//		unsigned int(5) numOfSequenceParameterSets;
+//		for (i=0; i< numOfSequenceParameterSets; i++) {
+//			unsigned int(16) sequenceParameterSetLength ;
+//			bit(8*sequenceParameterSetLength) sequenceParameterSetNALUnit;
+//		}
+//		unsigned int(8) numOfPictureParameterSets;
+//		for (i=0; i< numOfPictureParameterSets; i++) {
+//			unsigned int(16) pictureParameterSetLength;
+//			bit(8*pictureParameterSetLength) pictureParameterSetNALUnit;
+//		}
+//		if( profile_idc == 100 || profile_idc == 110 ||
+//		    profile_idc == 122 || profile_idc == 144 )
+//		{
+//			bit(6) reserved = '111111'b;
+//			unsigned int(2) chroma_format;
+//			bit(5) reserved = '11111'b;
+//			unsigned int(3) bit_depth_luma_minus8;
+//			bit(5) reserved = '11111'b;
+//			unsigned int(3) bit_depth_chroma_minus8;
+//			unsigned int(8) numOfSequenceParameterSetExt;
+//			for (i=0; i< numOfSequenceParameterSetExt; i++) {
+//				unsigned int(16) sequenceParameterSetExtLength;
+//				bit(8*sequenceParameterSetExtLength) sequenceParameterSetExtNALUnit;
+//			}
+//		}
+//	}
+type AVCDecoderConfig struct {
+	ConfigurationVersion byte
+	Profile              byte // AVCProfileIndication (profile_idc)
+	ProfileCompatibility byte // constraint_set0..5 flags
+	Level                byte // AVCLevelIndication (level_idc)
+	NALULengthSize       int
+	SPS                  [][]byte
+	PPS                  [][]byte
+
+	// High profile extension; HasExtension is false for older writers
+	// that omit it.
+	HasExtension   bool
+	ChromaFormat   byte
+	BitDepthLuma   byte
+	BitDepthChroma byte
+	SPSExt         [][]byte
+}
+
+func parseAVCDecoderConfig(buf []byte) (*AVCDecoderConfig, error) {
+	if len(buf) < 7 {
+		return nil, fmt.Errorf("avcC too short: %d bytes", len(buf))
+	}
+	c := &AVCDecoderConfig{
+		ConfigurationVersion: buf[0],
+		Profile:              buf[1],
+		ProfileCompatibility: buf[2],
+		Level:                buf[3],
+		NALULengthSize:       int(buf[4]&0x03) + 1,
+	}
+
+	pos := 5
+	var err error
+	if c.SPS, pos, err = readParameterSets(buf, pos, int(buf[pos]&0x1f)); err != nil {
+		return nil, fmt.Errorf("avcC SPS: %w", err)
+	}
+	if pos >= len(buf) {
+		return nil, fmt.Errorf("avcC: missing numOfPictureParameterSets")
+	}
+	if c.PPS, pos, err = readParameterSets(buf, pos, int(buf[pos])); err != nil {
+		return nil, fmt.Errorf("avcC PPS: %w", err)
+	}
+
+	switch c.Profile {
+	case 100, 110, 122, 144, 244:
+		if len(buf)-pos < 4 {
+			break
+		}
+		c.HasExtension = true
+		c.ChromaFormat = buf[pos] & 0x03
+		c.BitDepthLuma = buf[pos+1]&0x07 + 8
+		c.BitDepthChroma = buf[pos+2]&0x07 + 8
+		if c.SPSExt, _, err = readParameterSets(buf, pos+3, int(buf[pos+3])); err != nil {
+			return nil, fmt.Errorf("avcC SPS ext: %w", err)
+		}
+	default:
+		// 4:2:0 8 bit is implied by the lower profiles
+		c.ChromaFormat = 1
+		c.BitDepthLuma = 8
+		c.BitDepthChroma = 8
+	}
+
+	return c, nil
+}
+
+// applyAVCConfig fills the track fields derived from avcC and its SPS/PPS.
+// Parameter sets that fail to decode only produce a warning.
+func applyAVCConfig(info *TrackInfo, cfg *AVCDecoderConfig) {
+	info.AVCConfig = cfg
+	info.AVCProfile = cfg.Profile
+	info.AVCLevel = cfg.Level
+	info.Profile = AVCProfileName(cfg.Profile, cfg.ProfileCompatibility)
+	info.Level = AVCLevelName(cfg.Profile, cfg.ProfileCompatibility, cfg.Level)
+	info.ChromaFormat = ChromaFormatName(uint32(cfg.ChromaFormat))
+	info.BitDepth = cfg.BitDepthLuma
+
+	for i, nal := range cfg.SPS {
+		sps, err := parseH264SPS(nal)
+		if err != nil {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("avcC SPS %d: %v", i, err))
+			continue
+		}
+		if info.H264SPS == nil {
+			info.H264SPS = sps
+		}
+	}
+	for i, nal := range cfg.PPS {
+		pps, err := parseH264PPS(nal, info.H264SPS)
+		if err != nil {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("avcC PPS %d: %v", i, err))
+			continue
+		}
+		info.H264PPS = append(info.H264PPS, pps)
+	}
+
+	sps := info.H264SPS
+	if sps == nil {
+		return
+	}
+	if sps.ProfileIdc != cfg.Profile || sps.LevelIdc != cfg.Level {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("avcC profile/level %d/%d differ from SPS %d/%d",
+			cfg.Profile, cfg.Level, sps.ProfileIdc, sps.LevelIdc))
+	}
+	info.ChromaFormat = ChromaFormatName(sps.ChromaFormatIdc)
+	info.BitDepth = uint8(sps.BitDepthLuma)
+
+	// the sample entry carries the coded size, the SPS cropping window
+	// gives the size that is actually displayed
+	setBitstreamSize(info, "SPS", sps.CodedWidth, sps.CodedHeight, sps.Width, sps.Height)
+
+	if vui := sps.VUI; vui != nil && (vui.ColourDescription || vui.VideoFullRange) {
+		info.Color = &ColorInfo{
+			Primaries: uint16(vui.ColourPrimaries),
+			Transfer:  uint16(vui.TransferCharacteristics),
+			Matrix:    uint16(vui.MatrixCoefficients),
+			FullRange: vui.VideoFullRange,
+			Source:    "H.264 VUI",
+		}
+	}
+}
+
+// readParameterSets reads count length-prefixed NAL units starting right
+// after the count field at buf[pos].
+func readParameterSets(buf []byte, pos, count int) ([][]byte, int, error) {
+	pos++
+	sets := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		if pos+2 > len(buf) {
+			return nil, pos, fmt.Errorf("truncated parameter set %d", i)
+		}
+		n := int(binary.BigEndian.Uint16(buf[pos:]))
+		pos += 2
+		if pos+n > len(buf) {
+			return nil, pos, fmt.Errorf("parameter set %d length %d exceeds box", i, n)
+		}
+		sets = append(sets, buf[pos:pos+n])
+		pos += n
+	}
+	return sets, pos, nil
+}
+
+// AVCProfileName returns the H.264 profile name for a profile_idc and the
+// constraint flags byte (constraint_set0_flag in the most significant bit).
+func AVCProfileName(profile, constraints byte) string {
+	set1 := constraints&0x40 != 0
+	set3 := constraints&0x10 != 0
+	set4 := constraints&0x08 != 0
+	set5 := constraints&0x04 != 0
+
+	switch profile {
+	case 66:
+		if set1 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case 77:
+		return "Main"
+	case 88:
+		return "Extended"
+	case 100:
+		if set4 && set5 {
+			return "Constrained High"
+		}
+		if set4 {
+			return "Progressive High"
+		}
+		return "High"
+	case 110:
+		if set3 {
+			return "High 10 Intra"
+		}
+		if set4 {
+			return "Progressive High 10"
+		}
+		return "High 10"
+	case 122:
+		if set3 {
+			return "High 4:2:2 Intra"
+		}
+		return "High 4:2:2"
+	case 244:
+		if set3 {
+			return "High 4:4:4 Intra"
+		}
+		return "High 4:4:4 Predictive"
+	case 44:
+		return "CAVLC 4:4:4 Intra"
+	case 83:
+		return "Scalable Baseline"
+	case 86:
+		return "Scalable High"
+	case 118:
+		return "Multiview High"
+	case 128:
+		return "Stereo High"
+	case 134:
+		return "MFC High"
+	case 138:
+		return "Multiview Depth High"
+	default:
+		return fmt.Sprintf("Unknown (%d)", profile)
+	}
+}
+
+// AVCLevelName formats a level_idc, e.g. 31 as "3.1". Level 1b is signalled
+// as level_idc 9, or as 11 with constraint_set3 in the Baseline/Main/Extended
+// profiles.
+func AVCLevelName(profile, constraints, level byte) string {
+	if level == 9 || (level == 11 && constraints&0x10 != 0 && (profile == 66 || profile == 77 || profile == 88)) {
+		return "1b"
+	}
+	if level%10 == 0 {
+		return fmt.Sprintf("%d", level/10)
+	}
+	return fmt.Sprintf("%d.%d", level/10, level%10)
+}
@@ -0,0 +1,176 @@
+package mp4
+
+import (
+	"errors"
+)
+
+var errBitstreamOverrun = errors.New("bitstream: read past end of data")
+
+// bitReader reads MSB-first bit fields from a byte slice.
+//
+// Errors are sticky: once a read runs past the end of the data every
+// following read returns zero and err keeps the first failure, so callers
+// can decode a whole syntax structure and check err once at the end.
+type bitReader struct {
+	buf []byte
+	pos int // bit position
+	err error
+}
+
+func newBitReader(buf []byte) *bitReader {
+	return &bitReader{buf: buf}
+}
+
+// readBits reads n (<= 64) bits as an unsigned integer.
+func (br *bitReader) readBits(n int) uint64 {
+	if br.err != nil || n == 0 {
+		return 0
+	}
+	if n > 64 || br.pos+n > len(br.buf)*8 {
+		br.err = errBitstreamOverrun
+		br.pos = len(br.buf) * 8
+		return 0
+	}
+	var v uint64
+	for i := 0; i < n; i++ {
+		b := br.buf[br.pos>>3] >> (7 - uint(br.pos&7)) & 1
+		v = v<<1 | uint64(b)
+		br.pos++
+	}
+	return v
+}
+
+func (br *bitReader) readFlag() bool {
+	return br.readBits(1) == 1
+}
+
+func (br *bitReader) skipBits(n int) {
+	if br.err != nil {
+		return
+	}
+	if br.pos+n > len(br.buf)*8 {
+		br.err = errBitstreamOverrun
+		br.pos = len(br.buf) * 8
+		return
+	}
+	br.pos += n
+}
+
+// readBytes reads n whole bytes; the reader must be byte aligned.
+func (br *bitReader) readBytes(n int) []byte {
+	if br.err != nil {
+		return nil
+	}
+	if br.pos&7 != 0 || br.pos/8+n > len(br.buf) {
+		br.err = errBitstreamOverrun
+		return nil
+	}
+	start := br.pos / 8
+	br.pos += n * 8
+	return br.buf[start : start+n]
+}
+
+func (br *bitReader) byteAlign() {
+	if br.pos&7 != 0 {
+		br.skipBits(8 - br.pos&7)
+	}
+}
+
+func (br *bitReader) bitsLeft() int {
+	return len(br.buf)*8 - br.pos
+}
+
+// readUE reads an unsigned exp-Golomb code ue(v).
+func (br *bitReader) readUE() uint32 {
+	leadingZeros := 0
+	for !br.readFlag() {
+		if br.err != nil {
+			return 0
+		}
+		leadingZeros++
+		if leadingZeros > 31 {
+			br.err = errors.New("bitstream: invalid exp-Golomb code")
+			return 0
+		}
+	}
+	return uint32(1)<<uint(leadingZeros) - 1 + uint32(br.readBits(leadingZeros))
+}
+
+// readSE reads a signed exp-Golomb code se(v).
+func (br *bitReader) readSE() int32 {
+	v := br.readUE()
+	if v&1 == 1 {
+		return int32(v/2) + int32(v&1)
+	}
+	return -int32(v / 2)
+}
+
+// moreRBSPData implements more_rbsp_data(): it reports whether there is
+// syntax left before the rbsp_stop_one_bit.
+func (br *bitReader) moreRBSPData() bool {
+	if br.err != nil {
+		return false
+	}
+	last := len(br.buf) - 1
+	for last >= 0 && br.buf[last] == 0 {
+		last--
+	}
+	if last < 0 {
+		return false
+	}
+	b := br.buf[last]
+	stopBit := last*8 + 7
+	for b&1 == 0 {
+		b >>= 1
+		stopBit--
+	}
+	return br.pos < stopBit
+}
+
+// unescapeRBSP turns a NAL unit payload into its RBSP by dropping the
+// emulation_prevention_three_byte of every 0x000003 sequence.
+func unescapeRBSP(nal []byte) []byte {
+	out := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// readUVLC reads an AV1 variable length unsigned code uvlc().
+func (br *bitReader) readUVLC() uint32 {
+	leadingZeros := 0
+	for !br.readFlag() {
+		if br.err != nil {
+			return 0
+		}
+		leadingZeros++
+	}
+	if leadingZeros >= 32 {
+		return 1<<32 - 1
+	}
+	return uint32(br.readBits(leadingZeros)) + uint32(1)<<uint(leadingZeros) - 1
+}
+
+// readLEB128 reads an AV1 leb128() value; the reader must be byte aligned.
+func (br *bitReader) readLEB128() uint64 {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		b := br.readBits(8)
+		v |= (b & 0x7f) << (7 * uint(i))
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return v
+}
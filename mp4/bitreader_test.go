@@ -0,0 +1,98 @@
+package mp4
+
+import "testing"
+
+func TestBitReaderReadBits(t *testing.T) {
+	br := newBitReader([]byte{0xa5, 0x0f, 0xff})
+	tests := []struct {
+		n    int
+		want uint64
+	}{
+		{1, 1},
+		{3, 0b010},
+		{4, 0b0101},
+		{8, 0x0f},
+		{0, 0},
+		{2, 0b11},
+	}
+	for _, tt := range tests {
+		if got := br.readBits(tt.n); got != tt.want {
+			t.Errorf("readBits(%d) = %#x, want %#x", tt.n, got, tt.want)
+		}
+	}
+	if br.bitsLeft() != 6 {
+		t.Errorf("bitsLeft() = %d, want 6", br.bitsLeft())
+	}
+	if br.err != nil {
+		t.Fatalf("unexpected error %v", br.err)
+	}
+}
+
+func TestBitReaderOverrunIsSticky(t *testing.T) {
+	br := newBitReader([]byte{0xff})
+	br.readBits(4)
+	if got := br.readBits(5); got != 0 || br.err != errBitstreamOverrun {
+		t.Fatalf("readBits past end = %d, %v", got, br.err)
+	}
+	if got := br.readBits(1); got != 0 {
+		t.Errorf("read after overrun = %d, want 0", got)
+	}
+	if got := br.readBytes(1); got != nil {
+		t.Errorf("readBytes after overrun = %v, want nil", got)
+	}
+}
+
+func TestBitReaderByteAlign(t *testing.T) {
+	br := newBitReader([]byte{0x80, 0x12, 0x34})
+	br.readFlag()
+	br.byteAlign()
+	if got := br.readBytes(2); string(got) != "\x12\x34" {
+		t.Errorf("readBytes after byteAlign = %x, want 1234", got)
+	}
+	br = newBitReader([]byte{0x80, 0x12})
+	br.readFlag()
+	if br.readBytes(1); br.err == nil {
+		t.Error("readBytes on an unaligned reader succeeded")
+	}
+}
+
+func TestBitReaderExpGolomb(t *testing.T) {
+	// ue(v) codes 1, 010, 011, 00100, 00111 and 0001000 packed MSB first
+	br := newBitReader([]byte{0b10100110, 0b01000011, 0b10001000})
+	for _, want := range []uint32{0, 1, 2, 3, 6, 7} {
+		if got := br.readUE(); got != want {
+			t.Errorf("readUE() = %d, want %d", got, want)
+		}
+	}
+	if br.err != nil {
+		t.Fatalf("unexpected error %v", br.err)
+	}
+
+	// se(v) of the codes for 0 to 4
+	br = newBitReader([]byte{0b10100110, 0b01000010, 0b10000000})
+	for _, want := range []int32{0, 1, -1, 2, -2} {
+		if got := br.readSE(); got != want {
+			t.Errorf("readSE() = %d, want %d", got, want)
+		}
+	}
+
+	br = newBitReader([]byte{0, 0, 0, 0, 0})
+	if br.readUE(); br.err == nil {
+		t.Error("readUE of a code without a stop bit succeeded")
+	}
+}
+
+func TestUnescapeRBSP(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"\x00\x00\x03\x01", "\x00\x00\x01"},
+		{"\x00\x00\x03\x00\x00\x03", "\x00\x00\x00\x00"},
+		{"\x00\x03\x01", "\x00\x03\x01"},
+	}
+	for _, tt := range tests {
+		if got := string(unescapeRBSP([]byte(tt.in))); got != tt.want {
+			t.Errorf("unescapeRBSP(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
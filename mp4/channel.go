@@ -0,0 +1,114 @@
+package mp4
+
+import (
+	"strings"
+)
+
+// Speaker labels used in TrackInfo.ChannelLayout, following the common
+// Dolby/SMPTE abbreviations.
+const (
+	SpeakerL    = "L"
+	SpeakerR    = "R"
+	SpeakerC    = "C"
+	SpeakerLFE  = "LFE"
+	SpeakerLs   = "Ls"
+	SpeakerRs   = "Rs"
+	SpeakerCs   = "Cs"
+	SpeakerLrs  = "Lrs"
+	SpeakerRrs  = "Rrs"
+	SpeakerLc   = "Lc"
+	SpeakerRc   = "Rc"
+	SpeakerLw   = "Lw"
+	SpeakerRw   = "Rw"
+	SpeakerLsd  = "Lsd"
+	SpeakerRsd  = "Rsd"
+	SpeakerTs   = "Ts"
+	SpeakerLvh  = "Lvh"
+	SpeakerRvh  = "Rvh"
+	SpeakerCvh  = "Cvh"
+	SpeakerLtf  = "Ltf"
+	SpeakerRtf  = "Rtf"
+	SpeakerLtr  = "Ltr"
+	SpeakerRtr  = "Rtr"
+	SpeakerLFE2 = "LFE2"
+)
+
+// ac3ChannelModes lists the full bandwidth channels of each AC-3 acmod
+// (ETSI TS 102 366 Table 4.3); mode 0 is dual mono.
+var ac3ChannelModes = [8][]string{
+	{"Ch1", "Ch2"},
+	{SpeakerC},
+	{SpeakerL, SpeakerR},
+	{SpeakerL, SpeakerC, SpeakerR},
+	{SpeakerL, SpeakerR, SpeakerCs},
+	{SpeakerL, SpeakerC, SpeakerR, SpeakerCs},
+	{SpeakerL, SpeakerR, SpeakerLs, SpeakerRs},
+	{SpeakerL, SpeakerC, SpeakerR, SpeakerLs, SpeakerRs},
+}
+
+// ac3ChannelLayout returns the speakers of an acmod and lfeon pair.
+func ac3ChannelLayout(acmod byte, lfe bool) []string {
+	speakers := append([]string(nil), ac3ChannelModes[acmod&0x07]...)
+	if lfe {
+		speakers = append(speakers, SpeakerLFE)
+	}
+	return speakers
+}
+
+// ec3ChannelLocations are the speakers of the chan_loc bits of dec3, most
+// significant bit first (ETSI TS 102 366 Table F.6.1).
+var ec3ChannelLocations = [9][]string{
+	{SpeakerLc, SpeakerRc},
+	{SpeakerLrs, SpeakerRrs},
+	{SpeakerCs},
+	{SpeakerTs},
+	{SpeakerLsd, SpeakerRsd},
+	{SpeakerLw, SpeakerRw},
+	{SpeakerLvh, SpeakerRvh},
+	{SpeakerCvh},
+	{SpeakerLFE2},
+}
+
+// formatChannelLayout joins speakers into the space separated form of
+// TrackInfo.ChannelLayout.
+func formatChannelLayout(speakers []string) string {
+	return strings.Join(speakers, " ")
+}
+
+// cicpChannelCounts gives the channel count of each ChannelConfiguration
+// value of ISO/IEC 23091-3 (CICP), shared by AAC, MPEG-H and chnl.
+var cicpChannelCounts = [...]uint16{0, 1, 2, 3, 4, 5, 6, 8, 2, 3, 4, 7, 8, 24, 8, 12, 10, 12, 14, 12, 14}
+
+// cicpChannelLayouts gives the speakers of the CICP ChannelConfiguration
+// values in their channel order; configurations without an entry are only
+// reported by count.
+var cicpChannelLayouts = map[uint8][]string{
+	1:  {SpeakerC},
+	2:  {SpeakerL, SpeakerR},
+	3:  {SpeakerL, SpeakerR, SpeakerC},
+	4:  {SpeakerL, SpeakerR, SpeakerC, SpeakerCs},
+	5:  {SpeakerL, SpeakerR, SpeakerC, SpeakerLs, SpeakerRs},
+	6:  {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs},
+	7:  {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLc, SpeakerRc},
+	8:  {"Ch1", "Ch2"},
+	9:  {SpeakerL, SpeakerR, SpeakerCs},
+	10: {SpeakerL, SpeakerR, SpeakerLs, SpeakerRs},
+	11: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerCs},
+	12: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs},
+	14: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLtf, SpeakerRtf},
+	16: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLtf, SpeakerRtf, SpeakerLtr, SpeakerRtr},
+	19: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs, SpeakerLtf, SpeakerRtf, SpeakerLtr, SpeakerRtr},
+	20: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs, SpeakerLtf, SpeakerRtf, SpeakerLtr, SpeakerRtr, SpeakerLw, SpeakerRw},
+}
+
+// applyCICPLayout sets the channel count and layout of a CICP
+// ChannelConfiguration; unknown or unspecified (0) values are ignored.
+func applyCICPLayout(info *TrackInfo, config uint8) {
+	if config == 0 || int(config) >= len(cicpChannelCounts) {
+		return
+	}
+	info.Channels = cicpChannelCounts[config]
+	if speakers, ok := cicpChannelLayouts[config]; ok {
+		info.ChannelLayout = formatChannelLayout(speakers)
+	}
+}
@@ -0,0 +1,178 @@
+package mp4
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Chapter is a titled section of the movie timeline.
+type Chapter struct {
+	Start time.Duration
+	End   time.Duration
+	Title string
+}
+
+// Chapter export formats accepted by ExtractChapters.
+const (
+	ChaptersFFMetadata = "ffmetadata"
+	ChaptersWebVTT     = "vtt"
+	ChaptersJSON       = "json"
+)
+
+// nero chapter start times are in 100 ns units
+const chplTimescale = 10000000
+
+// parseChpl decodes a Nero chapter list (udta/chpl): a FullBox header, a
+// 32 bit reserved field in version 1, an 8 bit chapter count and for each
+// chapter a 64 bit start time and a Pascal string title. Chapter ends are
+// left for resolveChapters.
+func parseChpl(buf []byte) ([]Chapter, error) {
+	if len(buf) < 5 {
+		return nil, fmt.Errorf("chpl too short: %d bytes", len(buf))
+	}
+	pos := 4
+	if buf[0] != 0 {
+		pos += 4
+	}
+	if len(buf) < pos+1 {
+		return nil, fmt.Errorf("chpl too short: %d bytes", len(buf))
+	}
+	count := int(buf[pos])
+	pos++
+	chapters := make([]Chapter, 0, count)
+	for i := 0; i < count; i++ {
+		if len(buf) < pos+9 {
+			return chapters, fmt.Errorf("chpl: chapter %d exceeds box", i+1)
+		}
+		start := int64(binary.BigEndian.Uint64(buf[pos:]))
+		n := int(buf[pos+8])
+		pos += 9
+		if len(buf) < pos+n {
+			return chapters, fmt.Errorf("chpl: title of chapter %d exceeds box", i+1)
+		}
+		chapters = append(chapters, Chapter{
+			Start: mediaTime(start, chplTimescale),
+			Title: string(buf[pos : pos+n]),
+		})
+		pos += n
+	}
+	return chapters, nil
+}
+
+// chapterTrackChapters returns the chapters of the first text track
+// referenced through tref/chap, using the decoded cues of its samples.
+func chapterTrackChapters(tracks []TrackInfo) []Chapter {
+	for _, t := range tracks {
+		for _, ref := range t.References {
+			if ref.Type != "chap" {
+				continue
+			}
+			for _, id := range ref.TrackIDs {
+				for _, ct := range tracks {
+					if ct.TrackID != id || len(ct.Cues) == 0 {
+						continue
+					}
+					chapters := make([]Chapter, len(ct.Cues))
+					for i, c := range ct.Cues {
+						chapters[i] = Chapter{Start: c.Start, End: c.End, Title: cuePlainText(c.Text)}
+					}
+					return chapters
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveChapters collects the chapters of the movie. A QuickTime chapter
+// track is preferred over a Nero chapter list; chapters without an end run
+// until the next chapter or the end of the movie.
+func (p *MP4Parser) resolveChapters() {
+	if chapters := chapterTrackChapters(p.tracks); len(chapters) > 0 {
+		p.chapters = chapters
+		return
+	}
+	movieEnd := mediaTime(int64(p.mvhd.Duration), p.mvhd.Timescale)
+	for i := range p.chapters {
+		c := &p.chapters[i]
+		if i+1 < len(p.chapters) {
+			c.End = p.chapters[i+1].Start
+		} else if movieEnd > c.Start {
+			c.End = movieEnd
+		} else {
+			c.End = c.Start
+		}
+	}
+}
+
+// cuePlainText returns cue text without tags and escapes.
+func cuePlainText(text string) string {
+	var b strings.Builder
+	for _, tok := range cueTokens(text) {
+		if tok.tag == "" {
+			b.WriteString(tok.text)
+		}
+	}
+	return strings.TrimSpace(strings.ReplaceAll(b.String(), "\n", " "))
+}
+
+// ExtractChapters writes the chapters of the movie as an FFmpeg metadata
+// file, WebVTT chapters or JSON.
+func (p *MP4Parser) ExtractChapters(format string, w io.Writer) error {
+	switch format {
+	case ChaptersFFMetadata:
+		return WriteFFMetadata(w, p.chapters)
+	case ChaptersWebVTT, "webvtt":
+		return WriteChapterWebVTT(w, p.chapters)
+	case ChaptersJSON:
+		return WriteChaptersJSON(w, p.chapters)
+	default:
+		return fmt.Errorf("unknown chapter format %q", format)
+	}
+}
+
+var ffmetadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+
+// WriteFFMetadata writes chapters in the FFmpeg metadata format, with
+// millisecond timestamps.
+func WriteFFMetadata(w io.Writer, chapters []Chapter) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(";FFMETADATA1\n")
+	for _, c := range chapters {
+		fmt.Fprintf(bw, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.Start.Milliseconds(), c.End.Milliseconds(), ffmetadataEscaper.Replace(c.Title))
+	}
+	return bw.Flush()
+}
+
+// WriteChapterWebVTT writes chapters as a WebVTT chapters file, one cue
+// per chapter.
+func WriteChapterWebVTT(w io.Writer, chapters []Chapter) error {
+	cues := make([]Cue, len(chapters))
+	for i, c := range chapters {
+		cues[i] = Cue{Start: c.Start, End: c.End, ID: fmt.Sprintf("chapter-%d", i+1), Text: escapeCueText(c.Title)}
+	}
+	return WriteWebVTT(w, cues, "")
+}
+
+// WriteChaptersJSON writes chapters as a JSON array with start and end in
+// seconds.
+func WriteChaptersJSON(w io.Writer, chapters []Chapter) error {
+	type jsonChapter struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Title string  `json:"title"`
+	}
+	out := make([]jsonChapter, len(chapters))
+	for i, c := range chapters {
+		out[i] = jsonChapter{Start: c.Start.Seconds(), End: c.End.Seconds(), Title: c.Title}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
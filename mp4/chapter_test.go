@@ -0,0 +1,110 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// testChpl builds a chpl payload with chapters at the given starts, in
+// 100 ns units.
+func testChpl(version byte, starts []uint64, titles []string) []byte {
+	b := []byte{version, 0, 0, 0}
+	if version != 0 {
+		b = append(b, 0, 0, 0, 0)
+	}
+	b = append(b, byte(len(starts)))
+	for i, s := range starts {
+		b = binary.BigEndian.AppendUint64(b, s)
+		b = append(b, byte(len(titles[i])))
+		b = append(b, titles[i]...)
+	}
+	return b
+}
+
+func TestParseChpl(t *testing.T) {
+	for _, version := range []byte{0, 1} {
+		buf := testChpl(version, []uint64{0, 305000000}, []string{"Intro", "Part 2"})
+		chapters, err := parseChpl(buf)
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		want := []Chapter{{Start: 0, Title: "Intro"}, {Start: 30500 * time.Millisecond, Title: "Part 2"}}
+		if len(chapters) != len(want) {
+			t.Fatalf("version %d: got %d chapters, want %d", version, len(chapters), len(want))
+		}
+		for i := range want {
+			if chapters[i] != want[i] {
+				t.Errorf("version %d: chapter %d = %+v, want %+v", version, i, chapters[i], want[i])
+			}
+		}
+	}
+}
+
+func TestParseChplTruncated(t *testing.T) {
+	buf := testChpl(1, []uint64{0, 10000000}, []string{"One", "Two"})
+	chapters, err := parseChpl(buf[:len(buf)-2])
+	if err == nil {
+		t.Error("truncated chpl was accepted")
+	}
+	if len(chapters) != 1 || chapters[0].Title != "One" {
+		t.Errorf("chapters before the damage = %+v, want One", chapters)
+	}
+	if _, err := parseChpl([]byte{1, 0, 0}); err == nil {
+		t.Error("chpl without a count was accepted")
+	}
+}
+
+func TestResolveChapters(t *testing.T) {
+	p := &MP4Parser{
+		mvhd:     MVHDBox{Timescale: 1000, Duration: 90000},
+		chapters: []Chapter{{Start: 0, Title: "A"}, {Start: 30 * time.Second, Title: "B"}},
+	}
+	p.resolveChapters()
+	if p.chapters[0].End != 30*time.Second || p.chapters[1].End != 90*time.Second {
+		t.Errorf("chapter ends = %v, %v, want 30s, 1m30s", p.chapters[0].End, p.chapters[1].End)
+	}
+}
+
+var testChapters = []Chapter{
+	{Start: 0, End: 1500 * time.Millisecond, Title: "Intro; a=b"},
+	{Start: 1500 * time.Millisecond, End: 3 * time.Second, Title: "Tom & Jerry"},
+}
+
+func TestWriteFFMetadata(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteFFMetadata(&b, testChapters); err != nil {
+		t.Fatal(err)
+	}
+	want := ";FFMETADATA1\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=1500\ntitle=Intro\\; a\\=b\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=1500\nEND=3000\ntitle=Tom & Jerry\n"
+	if b.String() != want {
+		t.Errorf("WriteFFMetadata() =\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestWriteChapterWebVTT(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteChapterWebVTT(&b, testChapters); err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n" +
+		"chapter-1\n00:00:00.000 --> 00:00:01.500\nIntro; a=b\n\n" +
+		"chapter-2\n00:00:01.500 --> 00:00:03.000\nTom &amp; Jerry\n\n"
+	if b.String() != want {
+		t.Errorf("WriteChapterWebVTT() =\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestWriteChaptersJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteChaptersJSON(&b, testChapters[1:]); err != nil {
+		t.Fatal(err)
+	}
+	want := "[\n  {\n    \"start\": 1.5,\n    \"end\": 3,\n    \"title\": \"Tom \\u0026 Jerry\"\n  }\n]\n"
+	if b.String() != want {
+		t.Errorf("WriteChaptersJSON() =\n%q\nwant\n%q", b.String(), want)
+	}
+}
@@ -0,0 +1,357 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// chnl stream_structure bits
+const (
+	chnlChannelStructured = 1
+	chnlObjectStructured  = 2
+)
+
+// ChannelLayoutConfig is a decoded chnl box (ISO/IEC 14496-12 12.2.4).
+//
+//	aligned(8) class ChannelLayout extends FullBox('chnl', version, flags=0) {
+//		if (version == 0) {
+//			unsigned int(8) stream_structure;
+//			if (stream_structure & channelStructured) {
+//				unsigned int(8) definedLayout;
+//				if (definedLayout == 0) {
+//					for (i = 1; i <= channelCount; i++) {
+//						unsigned int(8) speaker_position;
+//						if (speaker_position == 126) {
+//							signed int(16) azimuth;
+//							signed int(8) elevation;
+//						}
+//					}
+//				} else {
+//					unsigned int(64) omittedChannelsMap;
+//				}
+//			}
+//			if (stream_structure & objectStructured)
+//				unsigned int(8) object_count;
+//		} else {
+//			unsigned int(4) stream_structure;
+//			unsigned int(4) format_ordering;
+//			unsigned int(8) baseChannelCount;
+//			if (stream_structure & channelStructured) {
+//				unsigned int(8) definedLayout;
+//				if (definedLayout == 0) {
+//					unsigned int(8) layout_channel_count;
+//					// speaker positions as in version 0
+//				} else {
+//					int(4) reserved = 0;
+//					unsigned int(3) channel_order_definition;
+//					unsigned int(1) omitted_channels_present;
+//					if (omitted_channels_present == 1)
+//						unsigned int(64) omittedChannelsMap;
+//				}
+//			}
+//		}
+//	}
+type ChannelLayoutConfig struct {
+	Version                uint8
+	StreamStructure        uint8
+	FormatOrdering         uint8
+	BaseChannelCount       uint8
+	DefinedLayout          uint8 // CICP ChannelConfiguration, 0 for explicit positions
+	SpeakerPositions       []ChnlSpeakerPosition
+	ChannelOrderDefinition uint8
+	OmittedChannelsMap     uint64
+	ObjectCount            uint8
+}
+
+// ChnlSpeakerPosition is one explicit speaker of a chnl box. Azimuth and
+// Elevation are only set for position 126.
+type ChnlSpeakerPosition struct {
+	Position  uint8 // CICP OutputChannelPosition
+	Azimuth   int16
+	Elevation int8
+}
+
+// cicpSpeakerPositions names the OutputChannelPosition values of
+// ISO/IEC 23091-3 Table 7.
+var cicpSpeakerPositions = [...]string{
+	SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLc, SpeakerRc,
+	SpeakerLrs, SpeakerRrs, SpeakerCs, SpeakerLsd, SpeakerRsd, "Lss", "Rss", SpeakerLw,
+	SpeakerRw, SpeakerLtf, SpeakerRtf, "Ctf", SpeakerLtr, SpeakerRtr, "Ctr", "Ltss",
+	"Rtss", SpeakerTs, SpeakerLFE2, "Lbf", "Rbf", "Cbf", "Lvs", "Rvs",
+	"LFE3", "Leos", "Reos", "Hwl", "Hwr",
+}
+
+// Name returns the speaker label of the position.
+func (p ChnlSpeakerPosition) Name() string {
+	if p.Position == 126 {
+		return fmt.Sprintf("Az%d/El%d", p.Azimuth, p.Elevation)
+	}
+	if int(p.Position) < len(cicpSpeakerPositions) {
+		return cicpSpeakerPositions[p.Position]
+	}
+	return fmt.Sprintf("Pos%d", p.Position)
+}
+
+// Speakers returns the channel speakers of the layout, dropping the channels
+// of omittedChannelsMap from a defined layout. It is nil when the layout is
+// unknown or the stream has no channel structure.
+func (c *ChannelLayoutConfig) Speakers() []string {
+	if c.StreamStructure&chnlChannelStructured == 0 {
+		return nil
+	}
+	if c.DefinedLayout == 0 {
+		speakers := make([]string, len(c.SpeakerPositions))
+		for i, p := range c.SpeakerPositions {
+			speakers[i] = p.Name()
+		}
+		return speakers
+	}
+	layout, ok := cicpChannelLayouts[c.DefinedLayout]
+	if !ok {
+		return nil
+	}
+	var speakers []string
+	for i, s := range layout {
+		if i < 64 && c.OmittedChannelsMap&(1<<uint(i)) != 0 {
+			continue
+		}
+		speakers = append(speakers, s)
+	}
+	return speakers
+}
+
+// parseChannelLayoutConfig decodes a chnl box; channelCount is the sample
+// entry channel count, which sizes the version 0 speaker list.
+func parseChannelLayoutConfig(buf []byte, channelCount int) (*ChannelLayoutConfig, error) {
+	if len(buf) < 5 {
+		return nil, fmt.Errorf("chnl too short: %d bytes", len(buf))
+	}
+	c := &ChannelLayoutConfig{Version: buf[0]}
+	if c.Version > 1 {
+		return nil, fmt.Errorf("chnl: unsupported version %d", c.Version)
+	}
+
+	br := newBitReader(buf[4:])
+	if c.Version == 0 {
+		c.StreamStructure = uint8(br.readBits(8))
+	} else {
+		c.StreamStructure = uint8(br.readBits(4))
+		c.FormatOrdering = uint8(br.readBits(4))
+		c.BaseChannelCount = uint8(br.readBits(8))
+	}
+	if c.StreamStructure&chnlChannelStructured != 0 {
+		c.DefinedLayout = uint8(br.readBits(8))
+		if c.DefinedLayout == 0 {
+			if c.Version == 1 {
+				channelCount = int(br.readBits(8))
+			}
+			for i := 0; i < channelCount && br.err == nil; i++ {
+				p := ChnlSpeakerPosition{Position: uint8(br.readBits(8))}
+				if p.Position == 126 {
+					p.Azimuth = int16(br.readBits(16))
+					p.Elevation = int8(br.readBits(8))
+				}
+				c.SpeakerPositions = append(c.SpeakerPositions, p)
+			}
+		} else if c.Version == 0 {
+			c.OmittedChannelsMap = br.readBits(64)
+		} else {
+			br.skipBits(4)
+			c.ChannelOrderDefinition = uint8(br.readBits(3))
+			if br.readFlag() {
+				c.OmittedChannelsMap = br.readBits(64)
+			}
+		}
+	}
+	if c.Version == 0 && c.StreamStructure&chnlObjectStructured != 0 {
+		c.ObjectCount = uint8(br.readBits(8))
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("chnl: %w", br.err)
+	}
+	return c, nil
+}
+
+func applyChannelLayoutConfig(info *TrackInfo, cfg *ChannelLayoutConfig) {
+	info.Chnl = cfg
+	info.AudioObjects = cfg.ObjectCount
+	if speakers := cfg.Speakers(); len(speakers) > 0 {
+		info.ChannelLayout = formatChannelLayout(speakers)
+	}
+}
+
+// QuickTime AudioChannelLayout tags with a special meaning
+const (
+	qtChannelLayoutUseDescriptions = 0
+	qtChannelLayoutUseBitmap       = 1 << 16
+)
+
+// QTChannelLayout is a decoded QuickTime chan box, which wraps a Core Audio
+// AudioChannelLayout.
+//
+//	struct AudioChannelLayout {
+//		UInt32 mChannelLayoutTag;
+//		UInt32 mChannelBitmap;
+//		UInt32 mNumberChannelDescriptions;
+//		AudioChannelDescription mChannelDescriptions[];
+//	}
+//	struct AudioChannelDescription {
+//		UInt32  mChannelLabel;
+//		UInt32  mChannelFlags;
+//		Float32 mCoordinates[3];
+//	}
+type QTChannelLayout struct {
+	LayoutTag     uint32
+	ChannelBitmap uint32
+	Descriptions  []QTChannelDescription
+}
+
+// QTChannelDescription is one AudioChannelDescription of a chan box.
+type QTChannelDescription struct {
+	Label       uint32
+	Flags       uint32
+	Coordinates [3]float32
+}
+
+// qtChannelBits are the speakers of the AudioChannelBitmap bits, least
+// significant bit first.
+var qtChannelBits = [...]string{
+	SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLc, SpeakerRc,
+	SpeakerCs, SpeakerLsd, SpeakerRsd, SpeakerTs, SpeakerLtf, "Ctf", SpeakerRtf, SpeakerLtr,
+	"Ctr", SpeakerRtr,
+}
+
+// qtChannelLabels names the common AudioChannelLabel values.
+var qtChannelLabels = map[uint32]string{
+	1:  SpeakerL,
+	2:  SpeakerR,
+	3:  SpeakerC,
+	4:  SpeakerLFE,
+	5:  SpeakerLs,
+	6:  SpeakerRs,
+	7:  SpeakerLc,
+	8:  SpeakerRc,
+	9:  SpeakerCs,
+	10: SpeakerLsd,
+	11: SpeakerRsd,
+	12: SpeakerTs,
+	13: SpeakerLtf,
+	14: "Ctf",
+	15: SpeakerRtf,
+	16: SpeakerLtr,
+	17: "Ctr",
+	18: SpeakerRtr,
+	33: SpeakerLrs,
+	34: SpeakerRrs,
+	35: SpeakerLw,
+	36: SpeakerRw,
+	37: SpeakerLFE2,
+	38: "Lt",
+	39: "Rt",
+	42: "M",
+}
+
+// qtChannelLayoutTags gives the speakers of the common predefined layouts,
+// keyed by the upper 16 bits of mChannelLayoutTag.
+var qtChannelLayoutTags = map[uint32][]string{
+	100: {"M"},
+	101: {SpeakerL, SpeakerR},
+	102: {SpeakerL, SpeakerR},
+	103: {"Lt", "Rt"},
+	108: {SpeakerL, SpeakerR, SpeakerLs, SpeakerRs},
+	109: {SpeakerL, SpeakerR, SpeakerLs, SpeakerRs, SpeakerC},
+	110: {SpeakerL, SpeakerR, SpeakerLs, SpeakerRs, SpeakerC, SpeakerCs},
+	113: {SpeakerL, SpeakerR, SpeakerC},
+	114: {SpeakerC, SpeakerL, SpeakerR},
+	115: {SpeakerL, SpeakerR, SpeakerC, SpeakerCs},
+	116: {SpeakerC, SpeakerL, SpeakerR, SpeakerCs},
+	117: {SpeakerL, SpeakerR, SpeakerC, SpeakerLs, SpeakerRs},
+	118: {SpeakerL, SpeakerR, SpeakerLs, SpeakerRs, SpeakerC},
+	119: {SpeakerL, SpeakerC, SpeakerR, SpeakerLs, SpeakerRs},
+	120: {SpeakerC, SpeakerL, SpeakerR, SpeakerLs, SpeakerRs},
+	121: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs},
+	122: {SpeakerL, SpeakerR, SpeakerLs, SpeakerRs, SpeakerC, SpeakerLFE},
+	123: {SpeakerL, SpeakerC, SpeakerR, SpeakerLs, SpeakerRs, SpeakerLFE},
+	124: {SpeakerC, SpeakerL, SpeakerR, SpeakerLs, SpeakerRs, SpeakerLFE},
+	125: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerCs},
+	126: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLc, SpeakerRc},
+	127: {SpeakerC, SpeakerLc, SpeakerRc, SpeakerL, SpeakerR, SpeakerLs, SpeakerRs, SpeakerLFE},
+	128: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs},
+	145: {SpeakerL, SpeakerR, SpeakerCs},
+	146: {SpeakerL, SpeakerR, SpeakerLs, SpeakerRs},
+	192: {SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs, SpeakerLtf, SpeakerRtf, SpeakerLtr, SpeakerRtr},
+}
+
+// Speakers returns the speakers described by the layout, or nil when they
+// are not known.
+func (l *QTChannelLayout) Speakers() []string {
+	switch l.LayoutTag {
+	case qtChannelLayoutUseDescriptions:
+		speakers := make([]string, len(l.Descriptions))
+		for i, d := range l.Descriptions {
+			name, ok := qtChannelLabels[d.Label]
+			if !ok {
+				name = fmt.Sprintf("Label%d", d.Label)
+			}
+			speakers[i] = name
+		}
+		return speakers
+	case qtChannelLayoutUseBitmap:
+		var speakers []string
+		for i, s := range qtChannelBits {
+			if l.ChannelBitmap&(1<<uint(i)) != 0 {
+				speakers = append(speakers, s)
+			}
+		}
+		return speakers
+	default:
+		return qtChannelLayoutTags[l.LayoutTag>>16]
+	}
+}
+
+// Channels returns the channel count carried in the low 16 bits of a
+// predefined layout tag, or the number of speakers otherwise.
+func (l *QTChannelLayout) Channels() int {
+	if l.LayoutTag != qtChannelLayoutUseDescriptions && l.LayoutTag != qtChannelLayoutUseBitmap {
+		return int(l.LayoutTag & 0xffff)
+	}
+	return len(l.Speakers())
+}
+
+func parseQTChannelLayout(buf []byte) (*QTChannelLayout, error) {
+	if len(buf) < 16 {
+		return nil, fmt.Errorf("chan too short: %d bytes", len(buf))
+	}
+	l := &QTChannelLayout{
+		LayoutTag:     binary.BigEndian.Uint32(buf[4:]),
+		ChannelBitmap: binary.BigEndian.Uint32(buf[8:]),
+	}
+	n := int(binary.BigEndian.Uint32(buf[12:]))
+	buf = buf[16:]
+	if n > len(buf)/20 {
+		return nil, fmt.Errorf("chan: %d channel descriptions exceed box", n)
+	}
+	for i := 0; i < n; i++ {
+		d := QTChannelDescription{
+			Label: binary.BigEndian.Uint32(buf),
+			Flags: binary.BigEndian.Uint32(buf[4:]),
+		}
+		for j := range d.Coordinates {
+			d.Coordinates[j] = math.Float32frombits(binary.BigEndian.Uint32(buf[8+4*j:]))
+		}
+		l.Descriptions = append(l.Descriptions, d)
+		buf = buf[20:]
+	}
+	return l, nil
+}
+
+func applyQTChannelLayout(info *TrackInfo, l *QTChannelLayout) {
+	info.Chan = l
+	if speakers := l.Speakers(); len(speakers) > 0 {
+		info.ChannelLayout = formatChannelLayout(speakers)
+	}
+	if n := l.Channels(); n > 0 && uint16(n) != info.Channels {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("chan layout has %d channels, sample entry has %d", n, info.Channels))
+	}
+}
@@ -0,0 +1,150 @@
+package mp4
+
+import (
+	"fmt"
+)
+
+// ColorInfo is the colour signalling of a video track, using the code
+// points of ISO/IEC 23091-2 (ITU-T H.273) shared by all codecs and boxes.
+type ColorInfo struct {
+	Primaries uint16
+	Transfer  uint16
+	Matrix    uint16
+	FullRange bool
+	Source    string // where the values come from, e.g. "H.264 VUI"
+}
+
+func (c *ColorInfo) String() string {
+	rng := "limited range"
+	if c.FullRange {
+		rng = "full range"
+	}
+	return fmt.Sprintf("primaries %s, transfer %s, matrix %s, %s",
+		ColorPrimariesName(c.Primaries), TransferCharacteristicsName(c.Transfer), MatrixCoefficientsName(c.Matrix), rng)
+}
+
+// ColorPrimariesName names a colour_primaries code point.
+func ColorPrimariesName(v uint16) string {
+	switch v {
+	case 1:
+		return "BT.709"
+	case 2:
+		return "unspecified"
+	case 4:
+		return "BT.470M"
+	case 5:
+		return "BT.470BG"
+	case 6:
+		return "SMPTE 170M"
+	case 7:
+		return "SMPTE 240M"
+	case 8:
+		return "Film"
+	case 9:
+		return "BT.2020"
+	case 10:
+		return "SMPTE ST 428-1"
+	case 11:
+		return "DCI-P3"
+	case 12:
+		return "Display P3"
+	case 22:
+		return "EBU 3213-E"
+	default:
+		return fmt.Sprintf("reserved(%d)", v)
+	}
+}
+
+// TransferCharacteristicsName names a transfer_characteristics code point.
+func TransferCharacteristicsName(v uint16) string {
+	switch v {
+	case 1:
+		return "BT.709"
+	case 2:
+		return "unspecified"
+	case 4:
+		return "Gamma 2.2"
+	case 5:
+		return "Gamma 2.8"
+	case 6:
+		return "SMPTE 170M"
+	case 7:
+		return "SMPTE 240M"
+	case 8:
+		return "Linear"
+	case 9:
+		return "Log 100:1"
+	case 10:
+		return "Log 316:1"
+	case 11:
+		return "IEC 61966-2-4"
+	case 12:
+		return "BT.1361"
+	case 13:
+		return "sRGB"
+	case 14:
+		return "BT.2020 10-bit"
+	case 15:
+		return "BT.2020 12-bit"
+	case 16:
+		return "PQ (SMPTE ST 2084)"
+	case 17:
+		return "SMPTE ST 428-1"
+	case 18:
+		return "HLG (ARIB STD-B67)"
+	default:
+		return fmt.Sprintf("reserved(%d)", v)
+	}
+}
+
+// MatrixCoefficientsName names a matrix_coefficients code point.
+func MatrixCoefficientsName(v uint16) string {
+	switch v {
+	case 0:
+		return "Identity (RGB)"
+	case 1:
+		return "BT.709"
+	case 2:
+		return "unspecified"
+	case 4:
+		return "FCC"
+	case 5:
+		return "BT.470BG"
+	case 6:
+		return "SMPTE 170M"
+	case 7:
+		return "SMPTE 240M"
+	case 8:
+		return "YCgCo"
+	case 9:
+		return "BT.2020 NCL"
+	case 10:
+		return "BT.2020 CL"
+	case 11:
+		return "SMPTE ST 2085"
+	case 12:
+		return "Chroma NCL"
+	case 13:
+		return "Chroma CL"
+	case 14:
+		return "ICtCp"
+	default:
+		return fmt.Sprintf("reserved(%d)", v)
+	}
+}
+
+// ChromaFormatName names a chroma_format_idc.
+func ChromaFormatName(idc uint32) string {
+	switch idc {
+	case 0:
+		return "4:0:0"
+	case 1:
+		return "4:2:0"
+	case 2:
+		return "4:2:2"
+	case 3:
+		return "4:4:4"
+	default:
+		return fmt.Sprintf("unknown(%d)", idc)
+	}
+}
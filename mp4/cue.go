@@ -0,0 +1,584 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Cue is one subtitle or caption with its presentation interval.
+type Cue struct {
+	Start    time.Duration
+	End      time.Duration
+	ID       string // WebVTT cue identifier
+	Settings string // WebVTT cue settings, e.g. "line:0 align:start"
+	Text     string // WebVTT cue text: lines separated by "\n", <b>, <i> and <u> tags, escaped &, < and >
+}
+
+// mediaTime converts a time in timescale units to a duration.
+func mediaTime(t int64, timescale uint32) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+	sec, rem := t/int64(timescale), t%int64(timescale)
+	return time.Duration(sec)*time.Second + time.Duration(rem)*time.Second/time.Duration(timescale)
+}
+
+// isSubtitleTrack reports whether the track has a sample entry whose
+// samples decodeCues understands.
+func isSubtitleTrack(t *TrackInfo) bool {
+	return t.TimedText != nil || t.WebVTT != nil || t.XMLSubtitle != nil || t.Codec == "c608"
+}
+
+// decodeCues reads the samples of the subtitle tracks into their Cues and
+// maps them to the movie timeline through the edit list. It runs at the end
+// of Parse while the file is still open.
+func (p *MP4Parser) decodeCues() {
+	for i := range p.tracks {
+		t := &p.tracks[i]
+		if !isSubtitleTrack(t) {
+			continue
+		}
+		samples, err := p.GetSamples(t.TrackID)
+		if err != nil {
+			t.Warnings = append(t.Warnings, fmt.Sprintf("subtitle samples: %v", err))
+		}
+		var cc *cea608Decoder
+		if t.Codec == "c608" {
+			cc = &cea608Decoder{}
+		}
+		for n, s := range samples {
+			// the buffer grows with the data read, so a corrupt sample size
+			// cannot force a huge allocation
+			buf, err := io.ReadAll(io.NewSectionReader(p.file, s.Offset, int64(s.Size)))
+			if err == nil && len(buf) < int(s.Size) {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				t.Warnings = append(t.Warnings, fmt.Sprintf("read subtitle sample %d: %v", n+1, err))
+				break
+			}
+			start := mediaTime(s.PresentationTime(), t.Timescale)
+			end := mediaTime(s.PresentationTime()+int64(s.Duration), t.Timescale)
+
+			var cues []Cue
+			switch {
+			case t.TimedText != nil:
+				cues, err = decodeTimedTextSample(buf, start, end)
+			case t.WebVTT != nil:
+				cues, err = decodeWebVTTSample(buf, start, end)
+			case t.XMLSubtitle != nil:
+				cues, err = decodeTTMLSample(buf, start, end)
+			case cc != nil:
+				cues, err = cc.decodeSample(buf, start)
+			}
+			if err != nil {
+				t.Warnings = append(t.Warnings, fmt.Sprintf("subtitle sample %d: %v", n+1, err))
+				continue
+			}
+			for _, c := range cues {
+				// a TTML cue clipped at a sample boundary continues in the
+				// next sample
+				if n := len(t.Cues); n > 0 && t.XMLSubtitle != nil {
+					if prev := &t.Cues[n-1]; prev.End == c.Start && prev.ID == c.ID && prev.Text == c.Text {
+						prev.End = c.End
+						continue
+					}
+				}
+				t.Cues = append(t.Cues, c)
+			}
+		}
+		if cc != nil && len(samples) > 0 {
+			last := samples[len(samples)-1]
+			t.Cues = append(t.Cues, cc.flush(mediaTime(last.PresentationTime()+int64(last.Duration), t.Timescale))...)
+		}
+		t.Cues = applyEditList(t.Cues, t.EditList, p.mvhd.Timescale, t.Timescale)
+	}
+}
+
+// decodeTimedTextSample decodes a tx3g or QuickTime text sample: a 16 bit
+// text length, the UTF-8 or UTF-16 text and optional modifier boxes. Bold,
+// italic and underlined runs of a styl box become <b>, <i> and <u> tags. An
+// empty sample clears the screen and gives no cue.
+func decodeTimedTextSample(buf []byte, start, end time.Duration) ([]Cue, error) {
+	if len(buf) < 2 {
+		return nil, fmt.Errorf("text sample too short: %d bytes", len(buf))
+	}
+	n := int(binary.BigEndian.Uint16(buf))
+	if n == 0 {
+		return nil, nil
+	}
+	if len(buf) < 2+n {
+		return nil, fmt.Errorf("text length %d exceeds sample", n)
+	}
+	text := buf[2 : 2+n]
+	var runes []rune
+	if len(text) >= 2 && text[0] == 0xfe && text[1] == 0xff {
+		u := make([]uint16, (len(text)-2)/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(text[2+2*i:])
+		}
+		runes = utf16.Decode(u)
+	} else {
+		runes = []rune(string(text))
+	}
+
+	var styles []TimedTextStyle
+	for mods := buf[2+n:]; len(mods) >= 8; {
+		boxType, body, rest, err := nextBox(mods)
+		if err != nil {
+			break
+		}
+		if boxType == "styl" && len(body) >= 2 {
+			count := int(binary.BigEndian.Uint16(body))
+			for i := 0; i < count && len(body) >= 2+12*(i+1); i++ {
+				styles = append(styles, parseTimedTextStyle(body[2+12*i:]))
+			}
+		}
+		mods = rest
+	}
+
+	// QuickTime text uses carriage returns between lines
+	s := strings.ReplaceAll(styleTimedText(runes, styles), "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return []Cue{{Start: start, End: end, Text: s}}, nil
+}
+
+// cueTags are the face style bits of a StyleRecord and their cue tags.
+var cueTags = []struct {
+	face uint8
+	tag  string
+}{
+	{1, "b"}, {2, "i"}, {4, "u"},
+}
+
+// styleTimedText escapes the text and wraps the character ranges of the
+// style records in cue tags.
+func styleTimedText(runes []rune, styles []TimedTextStyle) string {
+	faces := make([]uint8, len(runes))
+	for _, st := range styles {
+		for i := int(st.StartChar); i < int(st.EndChar) && i < len(runes); i++ {
+			faces[i] = st.FaceStyle
+		}
+	}
+	var b strings.Builder
+	var open uint8
+	for i, r := range runes {
+		if faces[i] != open {
+			closeCueTags(&b, open)
+			for _, t := range cueTags {
+				if faces[i]&t.face != 0 {
+					b.WriteString("<" + t.tag + ">")
+				}
+			}
+			open = faces[i]
+		}
+		b.WriteString(escapeCueText(string(r)))
+	}
+	closeCueTags(&b, open)
+	return b.String()
+}
+
+func closeCueTags(b *strings.Builder, faces uint8) {
+	for i := len(cueTags) - 1; i >= 0; i-- {
+		if faces&cueTags[i].face != 0 {
+			b.WriteString("</" + cueTags[i].tag + ">")
+		}
+	}
+}
+
+var cueEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeCueText escapes plain text for use as cue text.
+func escapeCueText(s string) string {
+	return cueEscaper.Replace(s)
+}
+
+// decodeWebVTTSample decodes the vttc boxes of a wvtt sample (ISO/IEC
+// 14496-30 7.4). A sample holding only vtte is a gap.
+func decodeWebVTTSample(buf []byte, start, end time.Duration) ([]Cue, error) {
+	var cues []Cue
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			return nil, err
+		}
+		if boxType == "vttc" {
+			cue := Cue{Start: start, End: end}
+			for len(body) >= 8 {
+				childType, child, childRest, err := nextBox(body)
+				if err != nil {
+					return nil, err
+				}
+				switch childType {
+				case "iden":
+					cue.ID = string(child)
+				case "sttg":
+					cue.Settings = string(child)
+				case "payl":
+					cue.Text = string(child)
+				}
+				body = childRest
+			}
+			cues = append(cues, cue)
+		}
+		buf = rest
+	}
+	return cues, nil
+}
+
+// decodeTTMLSample extracts the p elements of a TTML document. Their time
+// expressions are on the track timeline and are clipped to the sample
+// interval, which paragraphs without timing inherit. Inline bold, italic
+// and underline styles become cue tags.
+func decodeTTMLSample(buf []byte, start, end time.Duration) ([]Cue, error) {
+	d := xml.NewDecoder(bytes.NewReader(buf))
+	var (
+		cues      []Cue
+		cur       *Cue
+		closing   []string // end tags of the elements open inside cur
+		frameRate = 30.0
+		tickRate  = 0.0
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cues, fmt.Errorf("ttml: %w", err)
+		}
+		switch e := tok.(type) {
+		case xml.StartElement:
+			switch e.Name.Local {
+			case "tt":
+				for _, a := range e.Attr {
+					switch a.Name.Local {
+					case "frameRate":
+						if v, err := strconv.ParseFloat(a.Value, 64); err == nil && v > 0 {
+							frameRate = v
+						}
+					case "tickRate":
+						if v, err := strconv.ParseFloat(a.Value, 64); err == nil && v > 0 {
+							tickRate = v
+						}
+					}
+				}
+			case "p":
+				cue := Cue{Start: start, End: end}
+				var dur time.Duration
+				hasEnd := false
+				for _, a := range e.Attr {
+					if a.Name.Local == "id" {
+						cue.ID = a.Value
+						continue
+					}
+					v, ok := parseTTMLTime(a.Value, frameRate, tickRate)
+					if !ok {
+						continue
+					}
+					switch a.Name.Local {
+					case "begin":
+						cue.Start = v
+					case "end":
+						cue.End, hasEnd = v, true
+					case "dur":
+						dur = v
+					}
+				}
+				if !hasEnd && dur > 0 {
+					cue.End = cue.Start + dur
+				}
+				open, end := ttmlStyleTags(e.Attr)
+				cue.Text = open
+				cur, closing = &cue, []string{end}
+			case "br":
+				if cur != nil {
+					cur.Text += "\n"
+				}
+			default:
+				if cur != nil {
+					open, end := ttmlStyleTags(e.Attr)
+					cur.Text += open
+					closing = append(closing, end)
+				}
+			}
+		case xml.EndElement:
+			if cur == nil {
+				continue
+			}
+			if e.Name.Local == "br" {
+				continue
+			}
+			cur.Text += closing[len(closing)-1]
+			closing = closing[:len(closing)-1]
+			if len(closing) == 0 {
+				lines := strings.Split(cur.Text, "\n")
+				for i, l := range lines {
+					lines[i] = strings.TrimSpace(l)
+				}
+				cur.Text = strings.TrimSpace(strings.Join(lines, "\n"))
+				// a document repeated in several samples shows each cue
+				// only for the time of the sample
+				cur.Start, cur.End = max(cur.Start, start), min(cur.End, end)
+				if cur.Start < cur.End {
+					cues = append(cues, *cur)
+				}
+				cur = nil
+			}
+		case xml.CharData:
+			if cur != nil {
+				cur.Text += escapeCueText(collapseSpace(string(e)))
+			}
+		}
+	}
+	return cues, nil
+}
+
+// ttmlStyleTags returns the cue tags for the inline tts:fontWeight,
+// tts:fontStyle and tts:textDecoration attributes of an element.
+func ttmlStyleTags(attrs []xml.Attr) (open, end string) {
+	for _, a := range attrs {
+		var tag string
+		switch {
+		case a.Name.Local == "fontWeight" && a.Value == "bold":
+			tag = "b"
+		case a.Name.Local == "fontStyle" && (a.Value == "italic" || a.Value == "oblique"):
+			tag = "i"
+		case a.Name.Local == "textDecoration" && strings.Contains(a.Value, "underline") && !strings.Contains(a.Value, "noUnderline"):
+			tag = "u"
+		default:
+			continue
+		}
+		open += "<" + tag + ">"
+		end = "</" + tag + ">" + end
+	}
+	return open, end
+}
+
+// collapseSpace replaces each run of XML whitespace with a single space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// parseTTMLTime parses a TTML clock time (hh:mm:ss.fff or hh:mm:ss:ff) or
+// offset time (e.g. 1.5s, 200ms, 10f, 900t).
+func parseTTMLTime(s string, frameRate, tickRate float64) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) < 3 || len(parts) > 4 {
+			return 0, false
+		}
+		var v [3]float64
+		for i := 0; i < 3; i++ {
+			f, err := strconv.ParseFloat(parts[i], 64)
+			if err != nil {
+				return 0, false
+			}
+			v[i] = f
+		}
+		sec := v[0]*3600 + v[1]*60 + v[2]
+		if len(parts) == 4 {
+			frames, err := strconv.ParseFloat(parts[3], 64)
+			if err != nil {
+				return 0, false
+			}
+			sec += frames / frameRate
+		}
+		return time.Duration(sec * float64(time.Second)), true
+	}
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"ms", 0.001}, {"h", 3600}, {"m", 60}, {"s", 1}, {"f", 1 / frameRate}, {"t", 0},
+	}
+	for _, u := range units {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, u.suffix), 64)
+		if err != nil {
+			return 0, false
+		}
+		scale := u.scale
+		if u.suffix == "t" {
+			if tickRate == 0 {
+				return 0, false
+			}
+			scale = 1 / tickRate
+		}
+		return time.Duration(f * scale * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+// cea608Decoder turns the field 1 (CC1) byte pairs of c608 samples into
+// cues. Pop-on captions are shown on End Of Caption, roll-up and paint-on
+// text is emitted line by line.
+type cea608Decoder struct {
+	popOn       bool
+	buffer      []rune // off screen memory of pop-on captions
+	line        []rune // current roll-up or paint-on line
+	lineStart   time.Duration
+	shown       string // caption on screen
+	shownStart  time.Duration
+	lastControl [2]byte
+}
+
+// cea608Chars maps the basic characters that differ from ASCII.
+var cea608Chars = map[byte]rune{
+	0x2a: 'á', 0x5c: 'é', 0x5e: 'í', 0x5f: 'ó', 0x60: 'ú',
+	0x7b: 'ç', 0x7c: '÷', 0x7d: 'Ñ', 0x7e: 'ñ', 0x7f: '█',
+}
+
+// cea608Special is the special character set (0x11 0x30-0x3f).
+var cea608Special = []rune("®°½¿™¢£♪à èâêîôû")
+
+func (d *cea608Decoder) decodeSample(buf []byte, at time.Duration) ([]Cue, error) {
+	var cues []Cue
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			return nil, err
+		}
+		if boxType == "cdat" {
+			for ; len(body) >= 2; body = body[2:] {
+				cues = append(cues, d.pair(body[0]&0x7f, body[1]&0x7f, at)...)
+			}
+		}
+		buf = rest
+	}
+	return cues, nil
+}
+
+func (d *cea608Decoder) pair(b1, b2 byte, at time.Duration) []Cue {
+	if b1 == 0 && b2 == 0 {
+		return nil
+	}
+	if b1 >= 0x10 && b1 <= 0x1f {
+		// control codes are sent twice, act on the first only
+		if d.lastControl == [2]byte{b1, b2} {
+			d.lastControl = [2]byte{}
+			return nil
+		}
+		d.lastControl = [2]byte{b1, b2}
+		return d.control(b1, b2, at)
+	}
+	d.lastControl = [2]byte{}
+	d.char(b1)
+	d.char(b2)
+	return nil
+}
+
+func (d *cea608Decoder) char(b byte) {
+	if b < 0x20 {
+		return
+	}
+	r, ok := cea608Chars[b]
+	if !ok {
+		r = rune(b)
+	}
+	d.write(r)
+}
+
+func (d *cea608Decoder) write(r rune) {
+	if d.popOn {
+		d.buffer = append(d.buffer, r)
+	} else {
+		d.line = append(d.line, r)
+	}
+}
+
+func (d *cea608Decoder) control(b1, b2 byte, at time.Duration) []Cue {
+	switch {
+	case b1 == 0x11 && b2 >= 0x30 && b2 <= 0x3f:
+		d.write(cea608Special[b2-0x30])
+		return nil
+	case b1 == 0x11 && b2 >= 0x20 && b2 <= 0x2f:
+		// mid-row style change, shown as a space
+		d.write(' ')
+		return nil
+	case b2 >= 0x40 && b2 <= 0x7f && b1 <= 0x17:
+		// preamble address code: start a new row
+		if d.popOn && len(d.buffer) > 0 {
+			d.buffer = append(d.buffer, '\n')
+		}
+		return nil
+	case b1 != 0x14:
+		// other channels and extended characters are not decoded
+		return nil
+	}
+
+	var cues []Cue
+	switch b2 {
+	case 0x20: // RCL, resume caption loading
+		d.popOn = true
+	case 0x25, 0x26, 0x27, 0x29: // RU2, RU3, RU4, RDC
+		d.popOn = false
+		d.lineStart = at
+	case 0x2c: // EDM, erase displayed memory
+		cues = append(cues, d.hide(at)...)
+		cues = append(cues, d.endLine(at)...)
+	case 0x2d: // CR, carriage return
+		cues = append(cues, d.endLine(at)...)
+	case 0x2e: // ENM, erase non-displayed memory
+		d.buffer = nil
+	case 0x2f: // EOC, end of caption
+		cues = append(cues, d.hide(at)...)
+		d.shown, d.shownStart = strings.TrimSpace(string(d.buffer)), at
+		d.buffer = nil
+		d.popOn = true
+	}
+	return cues
+}
+
+// hide ends the pop-on caption on screen.
+func (d *cea608Decoder) hide(at time.Duration) []Cue {
+	if d.shown == "" {
+		return nil
+	}
+	cue := Cue{Start: d.shownStart, End: at, Text: escapeCueText(d.shown)}
+	d.shown = ""
+	return []Cue{cue}
+}
+
+// endLine emits the current roll-up or paint-on line.
+func (d *cea608Decoder) endLine(at time.Duration) []Cue {
+	text := strings.TrimSpace(string(d.line))
+	d.line = nil
+	start := d.lineStart
+	d.lineStart = at
+	if text == "" {
+		return nil
+	}
+	return []Cue{{Start: start, End: at, Text: escapeCueText(text)}}
+}
+
+// flush emits whatever is still on screen when the track ends.
+func (d *cea608Decoder) flush(at time.Duration) []Cue {
+	return append(d.hide(at), d.endLine(at)...)
+}
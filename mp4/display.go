@@ -0,0 +1,254 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// ColourBox is a decoded colr box (ISO/IEC 14496-12 12.1.5). nclx and the
+// QuickTime nclc type carry code points, rICC and prof an ICC profile.
+type ColourBox struct {
+	ColourType string
+	Color      *ColorInfo // nil for ICC profiles
+	ICCProfile []byte
+}
+
+// MasteringDisplay is a decoded mdcv box (ISO/IEC 23001-8, SMPTE ST 2086).
+// Chromaticities are in units of 0.00002, luminances in units of
+// 0.0001 cd/m².
+type MasteringDisplay struct {
+	Primaries    [3][2]uint16 // x, y of the primaries in G, B, R order
+	WhitePoint   [2]uint16
+	MaxLuminance uint32
+	MinLuminance uint32
+}
+
+// ContentLightLevel is a decoded clli box (CTA-861.3).
+type ContentLightLevel struct {
+	MaxCLL  uint16 // cd/m²
+	MaxFALL uint16 // cd/m²
+}
+
+// PixelAspectRatio is a decoded pasp box.
+type PixelAspectRatio struct {
+	HSpacing uint32
+	VSpacing uint32
+}
+
+// CleanAperture is a decoded clap box. Each value is a fraction; the
+// offsets are signed and relative to the picture centre.
+type CleanAperture struct {
+	WidthN, WidthD       uint32
+	HeightN, HeightD     uint32
+	HorizOffN, HorizOffD int32
+	VertOffN, VertOffD   int32
+}
+
+// FieldInfo is a decoded QuickTime fiel box.
+type FieldInfo struct {
+	Fields uint8 // 1 progressive, 2 interlaced
+	Order  uint8 // 0 unknown, 1 and 9 top field first, 6 and 14 bottom field first
+}
+
+func (f *FieldInfo) String() string {
+	if f.Fields < 2 {
+		return "progressive"
+	}
+	switch f.Order {
+	case 1:
+		return "interlaced, top field first (separated)"
+	case 6:
+		return "interlaced, bottom field first (separated)"
+	case 9:
+		return "interlaced, top field first"
+	case 14:
+		return "interlaced, bottom field first"
+	default:
+		return "interlaced"
+	}
+}
+
+// MaxLuminanceNits returns the maximum display mastering luminance in cd/m².
+func (m *MasteringDisplay) MaxLuminanceNits() float64 {
+	return float64(m.MaxLuminance) / 10000
+}
+
+// MinLuminanceNits returns the minimum display mastering luminance in cd/m².
+func (m *MasteringDisplay) MinLuminanceNits() float64 {
+	return float64(m.MinLuminance) / 10000
+}
+
+func (m *MasteringDisplay) String() string {
+	xy := func(v [2]uint16) string {
+		return fmt.Sprintf("(%.4f, %.4f)", float64(v[0])/50000, float64(v[1])/50000)
+	}
+	return fmt.Sprintf("R %s G %s B %s WP %s, %.4f-%.0f cd/m²",
+		xy(m.Primaries[2]), xy(m.Primaries[0]), xy(m.Primaries[1]), xy(m.WhitePoint),
+		m.MinLuminanceNits(), m.MaxLuminanceNits())
+}
+
+// Width returns the clean aperture width in pixels.
+func (c *CleanAperture) Width() float64 {
+	return fraction(int64(c.WidthN), int64(c.WidthD))
+}
+
+// Height returns the clean aperture height in pixels.
+func (c *CleanAperture) Height() float64 {
+	return fraction(int64(c.HeightN), int64(c.HeightD))
+}
+
+func fraction(n, d int64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+func parseColourBox(buf []byte) (*ColourBox, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("colr too short: %d bytes", len(buf))
+	}
+	c := &ColourBox{ColourType: string(buf[:4])}
+	switch c.ColourType {
+	case "nclx", "nclc":
+		if len(buf) < 10 {
+			return nil, fmt.Errorf("colr %s too short: %d bytes", c.ColourType, len(buf))
+		}
+		c.Color = &ColorInfo{
+			Primaries: binary.BigEndian.Uint16(buf[4:]),
+			Transfer:  binary.BigEndian.Uint16(buf[6:]),
+			Matrix:    binary.BigEndian.Uint16(buf[8:]),
+			Source:    "colr " + c.ColourType,
+		}
+		if c.ColourType == "nclx" && len(buf) > 10 {
+			c.Color.FullRange = buf[10]&0x80 != 0
+		}
+	case "rICC", "prof":
+		c.ICCProfile = buf[4:]
+	}
+	return c, nil
+}
+
+func parseMasteringDisplay(buf []byte) (*MasteringDisplay, error) {
+	if len(buf) < 24 {
+		return nil, fmt.Errorf("mdcv too short: %d bytes", len(buf))
+	}
+	m := &MasteringDisplay{}
+	for i := range m.Primaries {
+		m.Primaries[i][0] = binary.BigEndian.Uint16(buf[4*i:])
+		m.Primaries[i][1] = binary.BigEndian.Uint16(buf[4*i+2:])
+	}
+	m.WhitePoint[0] = binary.BigEndian.Uint16(buf[12:])
+	m.WhitePoint[1] = binary.BigEndian.Uint16(buf[14:])
+	m.MaxLuminance = binary.BigEndian.Uint32(buf[16:])
+	m.MinLuminance = binary.BigEndian.Uint32(buf[20:])
+	return m, nil
+}
+
+func parseContentLightLevel(buf []byte) (*ContentLightLevel, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("clli too short: %d bytes", len(buf))
+	}
+	return &ContentLightLevel{
+		MaxCLL:  binary.BigEndian.Uint16(buf),
+		MaxFALL: binary.BigEndian.Uint16(buf[2:]),
+	}, nil
+}
+
+func parsePixelAspectRatio(buf []byte) (*PixelAspectRatio, error) {
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("pasp too short: %d bytes", len(buf))
+	}
+	return &PixelAspectRatio{
+		HSpacing: binary.BigEndian.Uint32(buf),
+		VSpacing: binary.BigEndian.Uint32(buf[4:]),
+	}, nil
+}
+
+func parseCleanAperture(buf []byte) (*CleanAperture, error) {
+	if len(buf) < 32 {
+		return nil, fmt.Errorf("clap too short: %d bytes", len(buf))
+	}
+	return &CleanAperture{
+		WidthN:    binary.BigEndian.Uint32(buf),
+		WidthD:    binary.BigEndian.Uint32(buf[4:]),
+		HeightN:   binary.BigEndian.Uint32(buf[8:]),
+		HeightD:   binary.BigEndian.Uint32(buf[12:]),
+		HorizOffN: int32(binary.BigEndian.Uint32(buf[16:])),
+		HorizOffD: int32(binary.BigEndian.Uint32(buf[20:])),
+		VertOffN:  int32(binary.BigEndian.Uint32(buf[24:])),
+		VertOffD:  int32(binary.BigEndian.Uint32(buf[28:])),
+	}, nil
+}
+
+func parseFieldInfo(buf []byte) (*FieldInfo, error) {
+	if len(buf) < 2 {
+		return nil, fmt.Errorf("fiel too short: %d bytes", len(buf))
+	}
+	return &FieldInfo{Fields: buf[0], Order: buf[1]}, nil
+}
+
+// finishVisualEntry combines the boxes of a visual sample entry once all of
+// them are read: colr overrides the bitstream colour description, then the
+// display size and the HDR format are derived.
+func finishVisualEntry(info *TrackInfo) {
+	if colr := info.Colr; colr != nil && colr.Color != nil {
+		if c := info.Color; c != nil && (c.Primaries != colr.Color.Primaries ||
+			c.Transfer != colr.Color.Transfer || c.Matrix != colr.Color.Matrix) {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("colr %s (%s) differs from %s (%s)",
+				colr.ColourType, colr.Color, c.Source, c))
+		}
+		info.Color = colr.Color
+	}
+	setDisplaySize(info)
+	info.HDRFormat = classifyHDR(info)
+}
+
+// setDisplaySize computes the display size from the clean aperture and the
+// pixel aspect ratio, which falls back to the SAR of the H.264 or HEVC VUI.
+func setDisplaySize(info *TrackInfo) {
+	w, h := float64(info.Width), float64(info.Height)
+	if c := info.CleanAperture; c != nil && c.Width() > 0 && c.Height() > 0 {
+		w, h = c.Width(), c.Height()
+	}
+
+	hs, vs := uint32(1), uint32(1)
+	if p := info.PixelAspect; p != nil && p.HSpacing > 0 && p.VSpacing > 0 {
+		hs, vs = p.HSpacing, p.VSpacing
+	} else if sps := info.H264SPS; sps != nil && sps.VUI != nil {
+		if sw, sh := sps.VUI.SAR(); sw > 0 && sh > 0 {
+			hs, vs = uint32(sw), uint32(sh)
+		}
+	} else if sps := info.HEVCSPS; sps != nil && sps.VUI != nil {
+		if sw, sh := sps.VUI.SAR(); sw > 0 && sh > 0 {
+			hs, vs = uint32(sw), uint32(sh)
+		}
+	}
+	// stretch horizontally, as players do
+	w = w * float64(hs) / float64(vs)
+
+	info.DisplayWidth = uint32(math.Round(w))
+	info.DisplayHeight = uint32(math.Round(h))
+	if h > 0 {
+		info.DisplayAspect = w / h
+	}
+}
+
+// AspectRatioName formats a display aspect ratio, using the common names
+// where it is close to one of them.
+func AspectRatioName(dar float64) string {
+	common := []struct {
+		name  string
+		ratio float64
+	}{
+		{"4:3", 4.0 / 3}, {"16:9", 16.0 / 9}, {"1.85:1", 1.85}, {"2.39:1", 2.39},
+		{"1:1", 1}, {"3:2", 1.5}, {"21:9", 64.0 / 27}, {"9:16", 9.0 / 16},
+	}
+	for _, c := range common {
+		if math.Abs(dar-c.ratio) < 0.01 {
+			return c.name
+		}
+	}
+	return fmt.Sprintf("%.3f:1", dar)
+}
@@ -0,0 +1,308 @@
+package mp4
+
+import (
+	"fmt"
+)
+
+// AC3Config is a decoded dac3 box (ETSI TS 102 366 F.4).
+//
+//	class AC3SpecificBox {
+//		unsigned int(2) fscod;
+//		unsigned int(5) bsid;
+//		unsigned int(3) bsmod;
+//		unsigned int(3) acmod;
+//		unsigned int(1) lfeon;
+//		unsigned int(5) bit_rate_code;
+//		unsigned int(5) reserved = 0;
+//	}
+type AC3Config struct {
+	Fscod       byte
+	Bsid        byte
+	Bsmod       byte
+	Acmod       byte
+	LFEOn       bool
+	BitRateCode byte
+}
+
+// EC3Config is a decoded dec3 box (ETSI TS 102 366 F.6).
+type EC3Config struct {
+	DataRate      uint16 // kbit/s
+	Substreams    []EC3Substream
+	JOC           bool  // flag_ec3_extension_type_a, Dolby Atmos
+	JOCComplexity uint8 // complexity_index_type_a
+}
+
+// EC3Substream is one independent substream of a dec3 box.
+type EC3Substream struct {
+	Fscod     byte
+	Bsid      byte
+	Asvc      bool
+	Bsmod     byte
+	Acmod     byte
+	LFEOn     bool
+	NumDepSub byte
+	ChanLoc   uint16 // 9 bits, channels added by the dependent substreams
+}
+
+// AC4Config is a decoded dac4 box (ETSI TS 103 190-2 E.6). Presentations
+// are decoded up to the channel mode and mask.
+type AC4Config struct {
+	DSIVersion       byte
+	BitstreamVersion byte
+	FsIndex          byte
+	FrameRateIndex   byte
+	ShortProgramID   uint16 // 0 when not present
+	BitRateMode      byte
+	BitRate          uint32
+	Presentations    []AC4Presentation
+}
+
+// AC4Presentation is the start of an ac4_presentation_v1_dsi.
+type AC4Presentation struct {
+	Version      byte
+	Config       byte // presentation_config, 0x1f for a single substream
+	MDCompat     byte
+	ID           int  // -1 when not present
+	Decoded      bool // false for EMDF only or truncated presentations
+	ChannelCoded bool // valid when Decoded
+	ChannelMode  byte // dsi_presentation_ch_mode, valid when ChannelCoded
+	ChannelMask  uint32
+}
+
+var ac3SampleRates = [3]uint32{48000, 44100, 32000}
+
+var ac3BitRates = [19]uint32{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+
+// SampleRate returns the sample rate of the fscod, or 0 when reserved.
+func (c *AC3Config) SampleRate() uint32 {
+	if int(c.Fscod) < len(ac3SampleRates) {
+		return ac3SampleRates[c.Fscod]
+	}
+	return 0
+}
+
+// BitRate returns the nominal bitrate in bit/s.
+func (c *AC3Config) BitRate() uint32 {
+	if int(c.BitRateCode) < len(ac3BitRates) {
+		return ac3BitRates[c.BitRateCode] * 1000
+	}
+	return 0
+}
+
+func parseAC3Config(buf []byte) (*AC3Config, error) {
+	if len(buf) < 3 {
+		return nil, fmt.Errorf("dac3 too short: %d bytes", len(buf))
+	}
+	br := newBitReader(buf)
+	return &AC3Config{
+		Fscod:       byte(br.readBits(2)),
+		Bsid:        byte(br.readBits(5)),
+		Bsmod:       byte(br.readBits(3)),
+		Acmod:       byte(br.readBits(3)),
+		LFEOn:       br.readFlag(),
+		BitRateCode: byte(br.readBits(5)),
+	}, nil
+}
+
+func parseEC3Config(buf []byte) (*EC3Config, error) {
+	br := newBitReader(buf)
+	c := &EC3Config{DataRate: uint16(br.readBits(13))}
+	numIndSub := int(br.readBits(3)) + 1
+	for i := 0; i < numIndSub; i++ {
+		s := EC3Substream{
+			Fscod: byte(br.readBits(2)),
+			Bsid:  byte(br.readBits(5)),
+		}
+		br.skipBits(1)
+		s.Asvc = br.readFlag()
+		s.Bsmod = byte(br.readBits(3))
+		s.Acmod = byte(br.readBits(3))
+		s.LFEOn = br.readFlag()
+		br.skipBits(3)
+		s.NumDepSub = byte(br.readBits(4))
+		if s.NumDepSub > 0 {
+			s.ChanLoc = uint16(br.readBits(9))
+		} else {
+			br.skipBits(1)
+		}
+		c.Substreams = append(c.Substreams, s)
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("dec3: %w", br.err)
+	}
+	// the Atmos extension is optional trailing data
+	if br.bitsLeft() >= 16 {
+		br.skipBits(7)
+		c.JOC = br.readFlag()
+		c.JOCComplexity = uint8(br.readBits(8))
+	}
+	return c, nil
+}
+
+func parseAC4Config(buf []byte) (*AC4Config, error) {
+	br := newBitReader(buf)
+	c := &AC4Config{
+		DSIVersion:       byte(br.readBits(3)),
+		BitstreamVersion: byte(br.readBits(7)),
+		FsIndex:          byte(br.readBits(1)),
+		FrameRateIndex:   byte(br.readBits(4)),
+	}
+	if c.DSIVersion != 1 {
+		return nil, fmt.Errorf("dac4: unsupported ac4_dsi_version %d", c.DSIVersion)
+	}
+	nPresentations := int(br.readBits(9))
+	if c.BitstreamVersion > 1 {
+		if br.readFlag() { // b_program_id
+			c.ShortProgramID = uint16(br.readBits(16))
+			if br.readFlag() { // b_uuid
+				br.skipBits(128)
+			}
+		}
+	}
+	c.BitRateMode = byte(br.readBits(2))
+	c.BitRate = uint32(br.readBits(32))
+	br.skipBits(32) // bit_rate_precision
+	br.byteAlign()
+
+	for i := 0; i < nPresentations && br.err == nil; i++ {
+		version := byte(br.readBits(8))
+		presBytes := int(br.readBits(8))
+		if presBytes == 255 {
+			presBytes += int(br.readBits(16))
+		}
+		data := br.readBytes(presBytes)
+		if br.err != nil {
+			break
+		}
+		p := AC4Presentation{Version: version, ID: -1}
+		if version == 1 || version == 2 {
+			parseAC4PresentationV1(data, &p)
+		}
+		c.Presentations = append(c.Presentations, p)
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("dac4: %w", br.err)
+	}
+	return c, nil
+}
+
+// parseAC4PresentationV1 decodes the leading fields of an
+// ac4_presentation_v1_dsi; a truncated presentation keeps what was read.
+func parseAC4PresentationV1(data []byte, p *AC4Presentation) {
+	br := newBitReader(data)
+	p.Config = byte(br.readBits(5))
+	if p.Config == 0x06 {
+		// only EMDF substreams
+		return
+	}
+	p.MDCompat = byte(br.readBits(3))
+	if br.readFlag() { // b_presentation_id
+		p.ID = int(br.readBits(5))
+	}
+	br.skipBits(2 + 2 + 5 + 10) // frame rate multiply/fraction info, emdf version, key id
+	p.ChannelCoded = br.readFlag()
+	if p.ChannelCoded {
+		p.ChannelMode = byte(br.readBits(5))
+		if p.ChannelMode >= 11 && p.ChannelMode <= 14 {
+			br.skipBits(3) // pres_b_4_back_channels_present, pres_top_channel_pairs
+		}
+		p.ChannelMask = uint32(br.readBits(24))
+	}
+	if br.err != nil {
+		p.ChannelCoded = false
+		return
+	}
+	p.Decoded = true
+}
+
+// ac4ChannelModes gives the speakers of dsi_presentation_ch_mode 0 to 14.
+var ac4ChannelModes = [15][]string{
+	{SpeakerC},
+	{SpeakerL, SpeakerR},
+	{SpeakerL, SpeakerR, SpeakerC},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLs, SpeakerRs},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLs, SpeakerRs, SpeakerLw, SpeakerRw},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLw, SpeakerRw},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLs, SpeakerRs, SpeakerLvh, SpeakerRvh},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLvh, SpeakerRvh},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs, SpeakerLtf, SpeakerRtf, SpeakerLtr, SpeakerRtr},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs, SpeakerLtf, SpeakerRtf, SpeakerLtr, SpeakerRtr},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs, SpeakerLw, SpeakerRw, SpeakerLtf, SpeakerRtf, SpeakerLtr, SpeakerRtr},
+	{SpeakerL, SpeakerR, SpeakerC, SpeakerLFE, SpeakerLs, SpeakerRs, SpeakerLrs, SpeakerRrs, SpeakerLw, SpeakerRw, SpeakerLtf, SpeakerRtf, SpeakerLtr, SpeakerRtr},
+}
+
+func applyAC3Config(info *TrackInfo, cfg *AC3Config) {
+	info.AC3 = cfg
+	info.CodecName = "AC-3"
+	if rate := cfg.SampleRate(); rate > 0 {
+		info.SampleRate = rate
+	}
+	if rate := cfg.BitRate(); rate > 0 {
+		info.Bitrate = rate
+	}
+	speakers := ac3ChannelLayout(cfg.Acmod, cfg.LFEOn)
+	info.Channels = uint16(len(speakers))
+	info.ChannelLayout = formatChannelLayout(speakers)
+}
+
+func applyEC3Config(info *TrackInfo, cfg *EC3Config) {
+	info.EC3 = cfg
+	info.CodecName = "E-AC-3"
+	if cfg.JOC {
+		info.CodecName = "E-AC-3 JOC"
+		info.Atmos = true
+	}
+	info.Bitrate = uint32(cfg.DataRate) * 1000
+	if len(cfg.Substreams) == 0 {
+		return
+	}
+
+	// the first independent substream is the main program, its dependent
+	// substreams extend it beyond 5.1
+	s := cfg.Substreams[0]
+	if int(s.Fscod) < len(ac3SampleRates) {
+		info.SampleRate = ac3SampleRates[s.Fscod]
+	}
+	speakers := ac3ChannelLayout(s.Acmod, s.LFEOn)
+	for i, loc := range ec3ChannelLocations {
+		if s.ChanLoc&(1<<uint(8-i)) != 0 {
+			speakers = append(speakers, loc...)
+		}
+	}
+	info.Channels = uint16(len(speakers))
+	info.ChannelLayout = formatChannelLayout(speakers)
+}
+
+func applyAC4Config(info *TrackInfo, cfg *AC4Config) {
+	info.AC4 = cfg
+	info.CodecName = "AC-4"
+	if cfg.FsIndex == 1 {
+		info.SampleRate = 48000
+	} else {
+		info.SampleRate = 44100
+	}
+	if cfg.BitRate > 0 && cfg.BitRate != 0xffffffff {
+		info.Bitrate = cfg.BitRate
+	}
+	if len(cfg.Presentations) == 0 {
+		return
+	}
+
+	// object based presentations carry Atmos content. Height channels of a
+	// channel coded presentation do not tell whether it was mixed as Atmos.
+	p := cfg.Presentations[0]
+	switch {
+	case !p.Decoded:
+	case !p.ChannelCoded:
+		info.Atmos = true
+	case int(p.ChannelMode) < len(ac4ChannelModes):
+		speakers := ac4ChannelModes[p.ChannelMode]
+		info.Channels = uint16(len(speakers))
+		info.ChannelLayout = formatChannelLayout(speakers)
+	case p.ChannelMode == 15:
+		info.Channels = 24 // 22.2
+	}
+}
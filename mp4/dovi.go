@@ -0,0 +1,141 @@
+package mp4
+
+import "fmt"
+
+// DolbyVisionConfig is a decoded dvcC, dvvC or dvwC box (Dolby Vision
+// Streams within the ISO Base Media File Format, section 3.2).
+//
+//	align(8) class DOVIDecoderConfigurationRecord {
+//		unsigned int(8) dv_version_major;
+//		unsigned int(8) dv_version_minor;
+//		unsigned int(7) dv_profile;
+//		unsigned int(6) dv_level;
+//		bit(1) rpu_present_flag;
+//		bit(1) el_present_flag;
+//		bit(1) bl_present_flag;
+//		unsigned int(4) dv_bl_signal_compatibility_id;
+//		const unsigned int(28) reserved = 0;
+//		const unsigned int(32)[4] reserved = 0;
+//	}
+type DolbyVisionConfig struct {
+	BoxType                 string // dvcC, dvvC or dvwC
+	VersionMajor            uint8
+	VersionMinor            uint8
+	Profile                 uint8
+	Level                   uint8
+	RPUPresent              bool
+	ELPresent               bool
+	BLPresent               bool
+	BLSignalCompatibilityID uint8
+}
+
+// dolbyVisionLevels gives the maximum resolution and frame rate of the
+// dv_level values.
+var dolbyVisionLevels = [...]string{
+	1:  "1280x720@24",
+	2:  "1280x720@30",
+	3:  "1920x1080@24",
+	4:  "1920x1080@30",
+	5:  "1920x1080@60",
+	6:  "3840x2160@24",
+	7:  "3840x2160@30",
+	8:  "3840x2160@48",
+	9:  "3840x2160@60",
+	10: "3840x2160@120",
+	11: "7680x4320@60",
+	12: "7680x4320@120",
+	13: "7680x4320@240",
+}
+
+func parseDolbyVisionConfig(boxType string, buf []byte) (*DolbyVisionConfig, error) {
+	// the fields end in the fifth byte; the reserved bits are not needed
+	if len(buf) < 5 {
+		return nil, fmt.Errorf("%s too short: %d bytes", boxType, len(buf))
+	}
+	br := newBitReader(buf)
+	c := &DolbyVisionConfig{
+		BoxType:      boxType,
+		VersionMajor: uint8(br.readBits(8)),
+		VersionMinor: uint8(br.readBits(8)),
+		Profile:      uint8(br.readBits(7)),
+		Level:        uint8(br.readBits(6)),
+		RPUPresent:   br.readFlag(),
+		ELPresent:    br.readFlag(),
+		BLPresent:    br.readFlag(),
+	}
+	c.BLSignalCompatibilityID = uint8(br.readBits(4))
+	if br.err != nil {
+		return nil, fmt.Errorf("%s: %w", boxType, br.err)
+	}
+	return c, nil
+}
+
+// ProfileName formats the profile with the base layer compatibility, e.g.
+// "8.1" for profile 8 with an HDR10 base layer.
+func (c *DolbyVisionConfig) ProfileName() string {
+	switch c.Profile {
+	case 8, 9, 10, 20:
+		return fmt.Sprintf("%d.%d", c.Profile, c.BLSignalCompatibilityID)
+	default:
+		return fmt.Sprintf("%d", c.Profile)
+	}
+}
+
+// LevelName formats the level with its maximum resolution and frame rate.
+func (c *DolbyVisionConfig) LevelName() string {
+	if int(c.Level) < len(dolbyVisionLevels) && dolbyVisionLevels[c.Level] != "" {
+		return fmt.Sprintf("%02d (%s)", c.Level, dolbyVisionLevels[c.Level])
+	}
+	return fmt.Sprintf("%02d", c.Level)
+}
+
+// Compatibility names the cross-compatible format of the base layer, or
+// returns "" when it is not playable without Dolby Vision.
+func (c *DolbyVisionConfig) Compatibility() string {
+	switch c.BLSignalCompatibilityID {
+	case 1:
+		return "HDR10"
+	case 2:
+		return "SDR"
+	case 4:
+		return "HLG"
+	case 6:
+		return "BT.2100 PQ"
+	default:
+		return ""
+	}
+}
+
+func applyDolbyVisionConfig(info *TrackInfo, cfg *DolbyVisionConfig) {
+	info.DolbyVision = cfg
+	if !cfg.BLPresent && cfg.ELPresent {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("%s: enhancement layer without base layer", cfg.BoxType))
+	}
+}
+
+// classifyHDR names the dynamic range format of a video track from its
+// Dolby Vision configuration and colour transfer characteristics.
+func classifyHDR(info *TrackInfo) string {
+	if dv := info.DolbyVision; dv != nil {
+		name := "Dolby Vision " + dv.ProfileName()
+		if compat := dv.Compatibility(); compat != "" {
+			name += " (" + compat + " compatible)"
+		}
+		return name
+	}
+	if info.Color == nil {
+		return "SDR"
+	}
+	switch info.Color.Transfer {
+	case 16:
+		// HDR10 is PQ with BT.2020 primaries
+		if info.Color.Primaries == 9 {
+			return "HDR10"
+		}
+		return "PQ"
+	case 18:
+		return "HLG"
+	default:
+		return "SDR"
+	}
+}
@@ -0,0 +1,172 @@
+package mp4
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// DTSConfig is a decoded ddts box (ETSI TS 102 114 Annex E).
+//
+//	class DTSSpecificBox {
+//		unsigned int(32) DTSSamplingFrequency;
+//		unsigned int(32) maxBitrate;
+//		unsigned int(32) avgBitrate;
+//		unsigned int(8) pcmSampleDepth;
+//		bit(2) FrameDuration;
+//		bit(5) StreamConstruction;
+//		bit(1) CoreLFEPresent;
+//		bit(6) CoreLayout;
+//		bit(14) CoreSize;
+//		bit(1) StereoDownmix;
+//		bit(3) RepresentationType;
+//		bit(16) ChannelLayout;
+//		bit(1) MultiAssetFlag;
+//		bit(1) LBRDurationMod;
+//		bit(1) ReservedBoxPresent;
+//		bit(5) Reserved;
+//	}
+type DTSConfig struct {
+	SamplingFrequency  uint32
+	MaxBitrate         uint32
+	AvgBitrate         uint32
+	PCMSampleDepth     uint8
+	FrameDuration      uint32 // samples per frame
+	StreamConstruction uint8
+	CoreLFEPresent     bool
+	CoreLayout         uint8
+	CoreSize           uint16
+	StereoDownmix      bool
+	RepresentationType uint8
+	ChannelLayout      uint16
+	MultiAsset         bool
+	LBRDurationMod     bool
+}
+
+// DTSUHDConfig is the leading part of a udts box (ETSI TS 103 491 Annex B)
+// describing a DTS:X (DTS-UHD) stream.
+type DTSUHDConfig struct {
+	DecoderProfileCode uint8
+	FrameDurationCode  uint8
+	MaxPayloadCode     uint8
+	NumPresentations   uint8
+	ChannelMask        uint32
+	SampleRate         uint32
+	RepresentationType uint8
+}
+
+// dtsChannelLayoutBits are the speakers of the ddts ChannelLayout bits,
+// least significant bit first.
+var dtsChannelLayoutBits = [16][]string{
+	{SpeakerC},
+	{SpeakerL, SpeakerR},
+	{SpeakerLs, SpeakerRs},
+	{SpeakerLFE},
+	{SpeakerCs},
+	{"Lh", "Rh"},
+	{"Lsr", "Rsr"},
+	{"Ch"},
+	{"Oh"},
+	{SpeakerLc, SpeakerRc},
+	{SpeakerLw, SpeakerRw},
+	{"Lss", "Rss"},
+	{SpeakerLFE2},
+	{"Lhs", "Rhs"},
+	{"Chr"},
+	{"Lhr", "Rhr"},
+}
+
+func parseDTSConfig(buf []byte) (*DTSConfig, error) {
+	if len(buf) < 20 {
+		return nil, fmt.Errorf("ddts too short: %d bytes", len(buf))
+	}
+	br := newBitReader(buf)
+	c := &DTSConfig{
+		SamplingFrequency: uint32(br.readBits(32)),
+		MaxBitrate:        uint32(br.readBits(32)),
+		AvgBitrate:        uint32(br.readBits(32)),
+		PCMSampleDepth:    uint8(br.readBits(8)),
+	}
+	c.FrameDuration = 512 << br.readBits(2)
+	c.StreamConstruction = uint8(br.readBits(5))
+	c.CoreLFEPresent = br.readFlag()
+	c.CoreLayout = uint8(br.readBits(6))
+	c.CoreSize = uint16(br.readBits(14))
+	c.StereoDownmix = br.readFlag()
+	c.RepresentationType = uint8(br.readBits(3))
+	c.ChannelLayout = uint16(br.readBits(16))
+	c.MultiAsset = br.readFlag()
+	c.LBRDurationMod = br.readFlag()
+	return c, nil
+}
+
+func parseDTSUHDConfig(buf []byte) (*DTSUHDConfig, error) {
+	if len(buf) < 6 {
+		return nil, fmt.Errorf("udts too short: %d bytes", len(buf))
+	}
+	br := newBitReader(buf)
+	c := &DTSUHDConfig{
+		DecoderProfileCode: uint8(br.readBits(6)),
+		FrameDurationCode:  uint8(br.readBits(2)),
+		MaxPayloadCode:     uint8(br.readBits(3)),
+		NumPresentations:   uint8(br.readBits(5)) + 1,
+		ChannelMask:        uint32(br.readBits(32)),
+	}
+	base := uint32(44100)
+	if br.readFlag() { // BaseSamplingFrequencyCode
+		base = 48000
+	}
+	c.SampleRate = base << br.readBits(2) // SampleRateMod
+	c.RepresentationType = uint8(br.readBits(3))
+	return c, nil
+}
+
+// DTSCodecName names the DTS family sample entry types.
+func DTSCodecName(fourcc string) string {
+	switch fourcc {
+	case "dtsc":
+		return "DTS"
+	case "dtsh":
+		return "DTS-HD High Resolution"
+	case "dtsl":
+		return "DTS-HD Master Audio"
+	case "dtse":
+		return "DTS Express"
+	case "dtsx":
+		return "DTS:X"
+	case "dtsy":
+		return "DTS:X Profile 3"
+	default:
+		return "DTS"
+	}
+}
+
+func applyDTSConfig(info *TrackInfo, cfg *DTSConfig) {
+	info.DTS = cfg
+	info.CodecName = DTSCodecName(info.Codec)
+	info.SampleRate = cfg.SamplingFrequency
+	info.SampleSize = uint16(cfg.PCMSampleDepth)
+	if cfg.AvgBitrate > 0 {
+		info.Bitrate = cfg.AvgBitrate
+	}
+	info.MaxBitrate = cfg.MaxBitrate
+
+	var speakers []string
+	for i, s := range dtsChannelLayoutBits {
+		if cfg.ChannelLayout&(1<<uint(i)) != 0 {
+			speakers = append(speakers, s...)
+		}
+	}
+	if len(speakers) > 0 {
+		info.Channels = uint16(len(speakers))
+		info.ChannelLayout = formatChannelLayout(speakers)
+	}
+}
+
+func applyDTSUHDConfig(info *TrackInfo, cfg *DTSUHDConfig) {
+	info.DTSUHD = cfg
+	info.CodecName = DTSCodecName(info.Codec)
+	info.SampleRate = cfg.SampleRate
+	if n := bits.OnesCount32(cfg.ChannelMask); n > 0 {
+		info.Channels = uint16(n)
+	}
+}
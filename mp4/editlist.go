@@ -0,0 +1,108 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// EditListEntry is one entry of an elst box (ISO/IEC 14496-12 8.6.6).
+// SegmentDuration is in the movie timescale, MediaTime in the media
+// timescale; a MediaTime of -1 is an empty edit that delays the track.
+type EditListEntry struct {
+	SegmentDuration uint64
+	MediaTime       int64
+	MediaRate       int32 // 16.16 fixed point
+}
+
+// IsEmpty reports whether the entry is an empty edit.
+func (e EditListEntry) IsEmpty() bool {
+	return e.MediaTime == -1
+}
+
+// parseEdts reads the elst box of an edts box.
+func parseEdts(buf []byte) ([]EditListEntry, error) {
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			return nil, fmt.Errorf("edts: %w", err)
+		}
+		if boxType == "elst" {
+			return parseElst(body)
+		}
+		buf = rest
+	}
+	return nil, nil
+}
+
+func parseElst(buf []byte) ([]EditListEntry, error) {
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("elst too short: %d bytes", len(buf))
+	}
+	version := buf[0]
+	count := int(binary.BigEndian.Uint32(buf[4:]))
+	size := 12
+	if version == 1 {
+		size = 20
+	}
+	buf = buf[8:]
+	if count > len(buf)/size {
+		return nil, fmt.Errorf("elst: %d entries exceed box", count)
+	}
+	entries := make([]EditListEntry, count)
+	for i := range entries {
+		e := &entries[i]
+		if version == 1 {
+			e.SegmentDuration = binary.BigEndian.Uint64(buf)
+			e.MediaTime = int64(binary.BigEndian.Uint64(buf[8:]))
+			e.MediaRate = int32(binary.BigEndian.Uint32(buf[16:]))
+		} else {
+			e.SegmentDuration = uint64(binary.BigEndian.Uint32(buf))
+			e.MediaTime = int64(int32(binary.BigEndian.Uint32(buf[4:])))
+			e.MediaRate = int32(binary.BigEndian.Uint32(buf[8:]))
+		}
+		buf = buf[size:]
+	}
+	return entries, nil
+}
+
+// applyEditList maps cues from the media timeline to the presentation
+// timeline. Cues outside every edit are dropped and cues crossing an edit
+// boundary are clipped. Only normal rate edits are supported; dwells
+// (rate 0) are treated like normal edits.
+func applyEditList(cues []Cue, edits []EditListEntry, movieTimescale, mediaTimescale uint32) []Cue {
+	if len(edits) == 0 {
+		return cues
+	}
+	var out []Cue
+	var pos time.Duration
+	for i, e := range edits {
+		dur := mediaTime(int64(e.SegmentDuration), movieTimescale)
+		if e.IsEmpty() {
+			pos += dur
+			continue
+		}
+		start := mediaTime(e.MediaTime, mediaTimescale)
+		end := start + dur
+		// a zero duration in the last edit extends it to the end of the
+		// media, as written for fragmented files
+		open := e.SegmentDuration == 0 && i == len(edits)-1
+		for _, c := range cues {
+			if c.End <= start || (!open && c.Start >= end) {
+				continue
+			}
+			m := c
+			if m.Start < start {
+				m.Start = start
+			}
+			if !open && m.End > end {
+				m.End = end
+			}
+			m.Start += pos - start
+			m.End += pos - start
+			out = append(out, m)
+		}
+		pos += dur
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Well known emsg schemes with a built-in payload decoder.
+const (
+	SchemeSCTE35Bin = "urn:scte:scte35:2013:bin"
+	SchemeAOMID3    = "https://aomedia.org/emsg/ID3"
+	SchemeAppleID3  = "https://developer.apple.com/streaming/emsg-id3"
+)
+
+// EventMessage is a decoded emsg box.
+//
+//	aligned(8) class DASHEventMessageBox extends FullBox('emsg', version, flags = 0) {
+//		if (version==0) {
+//			string scheme_id_uri;
+//			string value;
+//			unsigned int(32) timescale;
+//			unsigned int(32) presentation_time_delta;
+//			unsigned int(32) event_duration;
+//			unsigned int(32) id;
+//		} else if (version==1) {
+//			unsigned int(32) timescale;
+//			unsigned int(64) presentation_time;
+//			unsigned int(32) event_duration;
+//			unsigned int(32) id;
+//			string scheme_id_uri;
+//			string value;
+//		}
+//		unsigned int(8) message_data[];
+//	}
+type EventMessage struct {
+	Offset                int64 // file offset of the emsg box
+	Version               byte
+	SchemeIDURI           string
+	Value                 string
+	Timescale             uint32
+	PresentationTimeDelta uint32 // version 0: relative to the segment start
+	PresentationTime      uint64 // version 1: on the media timeline
+	EventDuration         uint32 // 0xFFFFFFFF means unknown
+	ID                    uint32
+	MessageData           []byte
+
+	SCTE35 *SpliceInfoSection // set for urn:scte:scte35:2013:bin
+	ID3    *ID3Tag            // set for the ID3 schemes
+	Err    error              // payload decoding error, if any
+}
+
+// Time returns the presentation time of the event. For version 0 boxes it
+// is relative to the earliest presentation time of the segment.
+func (e *EventMessage) Time() time.Duration {
+	if e.Timescale == 0 {
+		return 0
+	}
+	t := uint64(e.PresentationTimeDelta)
+	if e.Version == 1 {
+		t = e.PresentationTime
+	}
+	return scaleToDuration(t, e.Timescale)
+}
+
+// Duration returns the event duration and false when it is unknown.
+func (e *EventMessage) Duration() (time.Duration, bool) {
+	if e.Timescale == 0 || e.EventDuration == 0xFFFFFFFF {
+		return 0, false
+	}
+	return scaleToDuration(uint64(e.EventDuration), e.Timescale), true
+}
+
+func scaleToDuration(v uint64, timescale uint32) time.Duration {
+	sec := v / uint64(timescale)
+	rem := v % uint64(timescale)
+	return time.Duration(sec)*time.Second + time.Duration(rem)*time.Second/time.Duration(timescale)
+}
+
+// parseEmsg decodes an emsg payload (everything after the box header).
+func parseEmsg(buf []byte) (*EventMessage, error) {
+	r := bytes.NewReader(buf)
+
+	var versionFlags uint32
+	if err := binary.Read(r, binary.BigEndian, &versionFlags); err != nil {
+		return nil, fmt.Errorf("read emsg version/flags: %w", err)
+	}
+	e := &EventMessage{Version: byte(versionFlags >> 24)}
+
+	var err error
+	switch e.Version {
+	case 0:
+		if e.SchemeIDURI, err = readCString(r); err != nil {
+			return nil, fmt.Errorf("read emsg scheme_id_uri: %w", err)
+		}
+		if e.Value, err = readCString(r); err != nil {
+			return nil, fmt.Errorf("read emsg value: %w", err)
+		}
+		fields := []interface{}{&e.Timescale, &e.PresentationTimeDelta, &e.EventDuration, &e.ID}
+		for _, f := range fields {
+			if err := binary.Read(r, binary.BigEndian, f); err != nil {
+				return nil, fmt.Errorf("read emsg v0 fields: %w", err)
+			}
+		}
+	case 1:
+		fields := []interface{}{&e.Timescale, &e.PresentationTime, &e.EventDuration, &e.ID}
+		for _, f := range fields {
+			if err := binary.Read(r, binary.BigEndian, f); err != nil {
+				return nil, fmt.Errorf("read emsg v1 fields: %w", err)
+			}
+		}
+		if e.SchemeIDURI, err = readCString(r); err != nil {
+			return nil, fmt.Errorf("read emsg scheme_id_uri: %w", err)
+		}
+		if e.Value, err = readCString(r); err != nil {
+			return nil, fmt.Errorf("read emsg value: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported emsg version %d", e.Version)
+	}
+
+	e.MessageData = buf[len(buf)-r.Len():]
+
+	switch e.SchemeIDURI {
+	case SchemeSCTE35Bin:
+		e.SCTE35, e.Err = parseSpliceInfoSection(e.MessageData)
+	case SchemeAOMID3, SchemeAppleID3:
+		e.ID3, e.Err = parseID3(e.MessageData)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,507 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MPEG-4 Systems descriptor tags (ISO/IEC 14496-1 7.2.2.1)
+const (
+	descrTagES                  = 0x03
+	descrTagDecoderConfig       = 0x04
+	descrTagDecoderSpecificInfo = 0x05
+	descrTagSLConfig            = 0x06
+)
+
+// objectTypeIndication values for MPEG-4 and MPEG-2 AAC audio
+const (
+	ObjectTypeMPEG4Audio   = 0x40
+	ObjectTypeMPEG2AACMain = 0x66
+	ObjectTypeMPEG2AACLC   = 0x67
+	ObjectTypeMPEG2AACSSR  = 0x68
+	ObjectTypeMPEG2Audio   = 0x69
+	ObjectTypeMPEG1Audio   = 0x6b
+)
+
+// ESDescriptor is the ES_Descriptor carried in an esds box
+// (ISO/IEC 14496-1 7.2.6.5).
+type ESDescriptor struct {
+	ESID           uint16
+	StreamPriority uint8
+	DependsOnESID  uint16 // 0 when streamDependenceFlag is not set
+	URL            string
+	OCRESID        uint16
+	DecoderConfig  *DecoderConfigDescriptor
+	SLConfig       byte // predefined SLConfigDescriptor value, 2 in MP4 files
+}
+
+// DecoderConfigDescriptor describes the elementary stream's decoder
+// (ISO/IEC 14496-1 7.2.6.6).
+type DecoderConfigDescriptor struct {
+	ObjectTypeIndication byte
+	StreamType           byte // 0x04 visual, 0x05 audio
+	UpStream             bool
+	BufferSizeDB         uint32
+	MaxBitrate           uint32
+	AvgBitrate           uint32
+	DecoderSpecificInfo  []byte
+	AudioConfig          *AudioSpecificConfig // for MPEG-4 audio
+}
+
+// AudioSpecificConfig is the MPEG-4 audio decoder specific info
+// (ISO/IEC 14496-3 1.6.2.1). SBR and PS are reported when they are
+// signalled explicitly, either hierarchically (object type 5 or 29) or by
+// the backward compatible sync extension.
+type AudioSpecificConfig struct {
+	ObjectType                 uint8
+	SamplingFrequencyIndex     uint8
+	SamplingFrequency          uint32
+	ChannelConfiguration       uint8
+	Channels                   uint16 // from the channel configuration or program config element
+	ExtensionObjectType        uint8
+	ExtensionSamplingFrequency uint32
+	SBR                        bool
+	PS                         bool
+	FrameLengthFlag            bool // 960 instead of 1024 samples per frame
+}
+
+// OutputSampleRate returns the sample rate after SBR upsampling.
+func (a *AudioSpecificConfig) OutputSampleRate() uint32 {
+	if a.SBR && a.ExtensionSamplingFrequency > 0 {
+		return a.ExtensionSamplingFrequency
+	}
+	return a.SamplingFrequency
+}
+
+// OutputChannels returns the channel count after parametric stereo.
+func (a *AudioSpecificConfig) OutputChannels() uint16 {
+	if a.PS && a.Channels == 1 {
+		return 2
+	}
+	return a.Channels
+}
+
+var aacSamplingFrequencies = [...]uint32{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050,
+	16000, 12000, 11025, 8000, 7350,
+}
+
+// channels of the channelConfiguration values (ISO/IEC 23001-8 CICP)
+var aacChannelConfigurations = [...]uint16{0, 1, 2, 3, 4, 5, 6, 8, 0, 0, 0, 7, 8, 24, 8}
+
+// parseEsds decodes the payload of an esds full box.
+func parseEsds(buf []byte) (*ESDescriptor, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("esds too short: %d bytes", len(buf))
+	}
+	tag, body, _, err := readDescriptor(buf[4:])
+	if err != nil {
+		return nil, fmt.Errorf("esds: %w", err)
+	}
+	if tag != descrTagES {
+		return nil, fmt.Errorf("esds: unexpected descriptor tag 0x%02x", tag)
+	}
+	return parseESDescriptor(body)
+}
+
+// readDescriptor reads one descriptor header with its expandable size
+// field and returns the tag, the body and what follows it.
+func readDescriptor(buf []byte) (byte, []byte, []byte, error) {
+	if len(buf) < 2 {
+		return 0, nil, nil, errors.New("truncated descriptor")
+	}
+	tag := buf[0]
+	var size int
+	pos := 1
+	for i := 0; i < 4; i++ {
+		if pos >= len(buf) {
+			return 0, nil, nil, errors.New("truncated descriptor size")
+		}
+		b := buf[pos]
+		pos++
+		size = size<<7 | int(b&0x7f)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if pos+size > len(buf) {
+		return 0, nil, nil, fmt.Errorf("descriptor 0x%02x size %d exceeds data", tag, size)
+	}
+	return tag, buf[pos : pos+size], buf[pos+size:], nil
+}
+
+func parseESDescriptor(buf []byte) (*ESDescriptor, error) {
+	if len(buf) < 3 {
+		return nil, errors.New("ES_Descriptor too short")
+	}
+	es := &ESDescriptor{
+		ESID:           binary.BigEndian.Uint16(buf),
+		StreamPriority: buf[2] & 0x1f,
+	}
+	flags := buf[2]
+	pos := 3
+	if flags&0x80 != 0 {
+		if pos+2 > len(buf) {
+			return nil, errors.New("ES_Descriptor: truncated dependsOn_ES_ID")
+		}
+		es.DependsOnESID = binary.BigEndian.Uint16(buf[pos:])
+		pos += 2
+	}
+	if flags&0x40 != 0 {
+		if pos >= len(buf) || pos+1+int(buf[pos]) > len(buf) {
+			return nil, errors.New("ES_Descriptor: truncated URL")
+		}
+		n := int(buf[pos])
+		es.URL = string(buf[pos+1 : pos+1+n])
+		pos += 1 + n
+	}
+	if flags&0x20 != 0 {
+		if pos+2 > len(buf) {
+			return nil, errors.New("ES_Descriptor: truncated OCR_ES_Id")
+		}
+		es.OCRESID = binary.BigEndian.Uint16(buf[pos:])
+		pos += 2
+	}
+
+	rest := buf[pos:]
+	for len(rest) > 0 {
+		tag, body, next, err := readDescriptor(rest)
+		if err != nil {
+			return nil, fmt.Errorf("ES_Descriptor: %w", err)
+		}
+		switch tag {
+		case descrTagDecoderConfig:
+			dc, err := parseDecoderConfigDescriptor(body)
+			if err != nil {
+				return nil, err
+			}
+			es.DecoderConfig = dc
+		case descrTagSLConfig:
+			if len(body) > 0 {
+				es.SLConfig = body[0]
+			}
+		}
+		rest = next
+	}
+	return es, nil
+}
+
+func parseDecoderConfigDescriptor(buf []byte) (*DecoderConfigDescriptor, error) {
+	if len(buf) < 13 {
+		return nil, errors.New("DecoderConfigDescriptor too short")
+	}
+	dc := &DecoderConfigDescriptor{
+		ObjectTypeIndication: buf[0],
+		StreamType:           buf[1] >> 2,
+		UpStream:             buf[1]&0x02 != 0,
+		BufferSizeDB:         uint32(buf[2])<<16 | uint32(buf[3])<<8 | uint32(buf[4]),
+		MaxBitrate:           binary.BigEndian.Uint32(buf[5:]),
+		AvgBitrate:           binary.BigEndian.Uint32(buf[9:]),
+	}
+
+	rest := buf[13:]
+	for len(rest) > 0 {
+		tag, body, next, err := readDescriptor(rest)
+		if err != nil {
+			return nil, fmt.Errorf("DecoderConfigDescriptor: %w", err)
+		}
+		if tag == descrTagDecoderSpecificInfo && dc.DecoderSpecificInfo == nil {
+			dc.DecoderSpecificInfo = body
+		}
+		rest = next
+	}
+
+	if dc.ObjectTypeIndication == ObjectTypeMPEG4Audio && len(dc.DecoderSpecificInfo) > 0 {
+		asc, err := parseAudioSpecificConfig(dc.DecoderSpecificInfo)
+		if err != nil {
+			return nil, err
+		}
+		dc.AudioConfig = asc
+	}
+	return dc, nil
+}
+
+func parseAudioSpecificConfig(buf []byte) (*AudioSpecificConfig, error) {
+	br := newBitReader(buf)
+	a := &AudioSpecificConfig{}
+
+	a.ObjectType = readAudioObjectType(br)
+	a.SamplingFrequencyIndex, a.SamplingFrequency = readSamplingFrequency(br)
+	a.ChannelConfiguration = uint8(br.readBits(4))
+	if int(a.ChannelConfiguration) < len(aacChannelConfigurations) {
+		a.Channels = aacChannelConfigurations[a.ChannelConfiguration]
+	}
+
+	if a.ObjectType == 5 || a.ObjectType == 29 {
+		a.ExtensionObjectType = 5
+		a.SBR = true
+		a.PS = a.ObjectType == 29
+		_, a.ExtensionSamplingFrequency = readSamplingFrequency(br)
+		a.ObjectType = readAudioObjectType(br)
+		if a.ObjectType == 22 {
+			br.skipBits(4) // extensionChannelConfiguration
+		}
+	}
+
+	switch a.ObjectType {
+	case 1, 2, 3, 4, 6, 7, 17, 19, 20, 21, 22, 23:
+		parseGASpecificConfig(br, a)
+	default:
+		// other object types carry configs that are not needed for the
+		// stream properties reported here
+		if br.err != nil {
+			return nil, fmt.Errorf("AudioSpecificConfig: %w", br.err)
+		}
+		return a, nil
+	}
+	switch a.ObjectType {
+	case 17, 19, 20, 21, 22, 23, 24, 25, 26, 27, 39:
+		br.skipBits(2) // epConfig
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("AudioSpecificConfig: %w", br.err)
+	}
+
+	// backward compatible SBR/PS signalling appended to the config
+	if a.ExtensionObjectType != 5 && br.bitsLeft() >= 16 {
+		if br.readBits(11) == 0x2b7 { // syncExtensionType
+			a.ExtensionObjectType = readAudioObjectType(br)
+			if a.ExtensionObjectType == 5 {
+				a.SBR = br.readFlag()
+				if a.SBR {
+					_, a.ExtensionSamplingFrequency = readSamplingFrequency(br)
+					if br.bitsLeft() >= 12 && br.readBits(11) == 0x548 {
+						a.PS = br.readFlag()
+					}
+				}
+			}
+		}
+		// a malformed extension does not invalidate the core config
+		br.err = nil
+	}
+	return a, nil
+}
+
+func readAudioObjectType(br *bitReader) uint8 {
+	aot := uint8(br.readBits(5))
+	if aot == 31 {
+		aot = 32 + uint8(br.readBits(6))
+	}
+	return aot
+}
+
+func readSamplingFrequency(br *bitReader) (uint8, uint32) {
+	idx := uint8(br.readBits(4))
+	if idx == 0x0f {
+		return idx, uint32(br.readBits(24))
+	}
+	if int(idx) < len(aacSamplingFrequencies) {
+		return idx, aacSamplingFrequencies[idx]
+	}
+	return idx, 0
+}
+
+// parseGASpecificConfig decodes GASpecificConfig (ISO/IEC 14496-3 4.4.1),
+// taking the channel count from the program config element when the
+// channel configuration is 0.
+func parseGASpecificConfig(br *bitReader, a *AudioSpecificConfig) {
+	a.FrameLengthFlag = br.readFlag()
+	if br.readFlag() { // dependsOnCoreCoder
+		br.skipBits(14) // coreCoderDelay
+	}
+	extensionFlag := br.readFlag()
+	if a.ChannelConfiguration == 0 {
+		a.Channels = readProgramConfigElement(br)
+	}
+	if a.ObjectType == 6 || a.ObjectType == 20 {
+		br.skipBits(3) // layerNr
+	}
+	if extensionFlag {
+		if a.ObjectType == 22 {
+			br.skipBits(16) // numOfSubFrame, layer_length
+		}
+		if a.ObjectType == 17 || a.ObjectType == 19 || a.ObjectType == 20 || a.ObjectType == 23 {
+			br.skipBits(3) // aacSection/Scalefactor/SpectralDataResilienceFlag
+		}
+		br.skipBits(1) // extensionFlag3
+	}
+}
+
+// readProgramConfigElement decodes program_config_element() and returns
+// the number of output channels it describes.
+func readProgramConfigElement(br *bitReader) uint16 {
+	br.skipBits(4 + 2 + 4) // element_instance_tag, object_type, sampling_frequency_index
+	numFront := int(br.readBits(4))
+	numSide := int(br.readBits(4))
+	numBack := int(br.readBits(4))
+	numLFE := int(br.readBits(2))
+	numAssocData := int(br.readBits(3))
+	numValidCC := int(br.readBits(4))
+	if br.readFlag() { // mono_mixdown_present
+		br.skipBits(4)
+	}
+	if br.readFlag() { // stereo_mixdown_present
+		br.skipBits(4)
+	}
+	if br.readFlag() { // matrix_mixdown_idx_present
+		br.skipBits(3)
+	}
+
+	var channels uint16
+	for i := 0; i < numFront+numSide+numBack; i++ {
+		if br.readFlag() { // is_cpe
+			channels += 2
+		} else {
+			channels++
+		}
+		br.skipBits(4) // element_tag_select
+	}
+	channels += uint16(numLFE)
+	br.skipBits(4*numLFE + 4*numAssocData + 5*numValidCC)
+	br.byteAlign()
+	br.skipBits(8 * int(br.readBits(8))) // comment_field_data
+	return channels
+}
+
+// AudioObjectTypeName names an MPEG-4 audio object type.
+func AudioObjectTypeName(aot uint8) string {
+	switch aot {
+	case 1:
+		return "AAC Main"
+	case 2:
+		return "AAC LC"
+	case 3:
+		return "AAC SSR"
+	case 4:
+		return "AAC LTP"
+	case 5:
+		return "SBR"
+	case 6:
+		return "AAC Scalable"
+	case 7:
+		return "TwinVQ"
+	case 8:
+		return "CELP"
+	case 9:
+		return "HVXC"
+	case 17:
+		return "ER AAC LC"
+	case 19:
+		return "ER AAC LTP"
+	case 20:
+		return "ER AAC Scalable"
+	case 22:
+		return "ER BSAC"
+	case 23:
+		return "ER AAC LD"
+	case 29:
+		return "PS"
+	case 32:
+		return "MPEG-1/2 Layer 1"
+	case 33:
+		return "MPEG-1/2 Layer 2"
+	case 34:
+		return "MPEG-1/2 Layer 3"
+	case 36:
+		return "ALS"
+	case 39:
+		return "ER AAC ELD"
+	case 42:
+		return "USAC"
+	default:
+		return fmt.Sprintf("object type %d", aot)
+	}
+}
+
+// ObjectTypeIndicationName names a DecoderConfigDescriptor
+// objectTypeIndication as registered by the MP4 registration authority.
+func ObjectTypeIndicationName(oti byte) string {
+	switch oti {
+	case 0x20:
+		return "MPEG-4 Visual"
+	case 0x21:
+		return "H.264"
+	case 0x23:
+		return "HEVC"
+	case 0x40:
+		return "MPEG-4 Audio"
+	case 0x60, 0x61, 0x62, 0x63, 0x64, 0x65:
+		return "MPEG-2 Video"
+	case ObjectTypeMPEG2AACMain:
+		return "MPEG-2 AAC Main"
+	case ObjectTypeMPEG2AACLC:
+		return "MPEG-2 AAC LC"
+	case ObjectTypeMPEG2AACSSR:
+		return "MPEG-2 AAC SSR"
+	case ObjectTypeMPEG2Audio:
+		return "MP3 (MPEG-2 Audio)"
+	case 0x6a:
+		return "MPEG-1 Video"
+	case ObjectTypeMPEG1Audio:
+		return "MP3"
+	case 0x6c:
+		return "JPEG"
+	case 0xa5:
+		return "AC-3"
+	case 0xa6:
+		return "E-AC-3"
+	case 0xa9:
+		return "DTS"
+	case 0xad:
+		return "Opus"
+	case 0xdd:
+		return "Vorbis"
+	case 0xe1:
+		return "QCELP"
+	default:
+		return fmt.Sprintf("objectTypeIndication 0x%02x", oti)
+	}
+}
+
+// audioCodecName gives a descriptive name for an MPEG-4 audio stream, e.g.
+// "HE-AAC v2" when SBR and PS are signalled.
+func audioCodecName(dc *DecoderConfigDescriptor) string {
+	a := dc.AudioConfig
+	if a == nil {
+		return ObjectTypeIndicationName(dc.ObjectTypeIndication)
+	}
+	switch {
+	case a.PS:
+		return "HE-AAC v2"
+	case a.SBR:
+		return "HE-AAC"
+	case a.ObjectType == 2:
+		return "AAC-LC"
+	case a.ObjectType == 34:
+		return "MP3"
+	default:
+		return AudioObjectTypeName(a.ObjectType)
+	}
+}
+
+// applyEsds fills the track fields derived from an esds box.
+func applyEsds(info *TrackInfo, es *ESDescriptor) {
+	info.ESDescriptor = es
+	dc := es.DecoderConfig
+	if dc == nil {
+		return
+	}
+	info.AudioCodecTag = uint32(dc.ObjectTypeIndication)
+	info.CodecName = audioCodecName(dc)
+	if dc.AvgBitrate > 0 {
+		info.Bitrate = dc.AvgBitrate
+	}
+	info.MaxBitrate = dc.MaxBitrate
+	if a := dc.AudioConfig; a != nil {
+		if rate := a.OutputSampleRate(); rate > 0 {
+			info.SampleRate = rate
+		}
+		if !a.PS {
+			applyCICPLayout(info, a.ChannelConfiguration)
+		}
+		if ch := a.OutputChannels(); ch > 0 {
+			info.Channels = ch
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// FLAC metadata block types (RFC 9639 8.1)
+const (
+	FLACStreamInfo    = 0
+	FLACPadding       = 1
+	FLACApplication   = 2
+	FLACSeekTable     = 3
+	FLACVorbisComment = 4
+	FLACCueSheet      = 5
+	FLACPicture       = 6
+)
+
+// FLACConfig is a decoded dfLa box (Encapsulation of FLAC in ISO Base Media
+// File Format 3.3.2): a full box holding the FLAC metadata blocks of the
+// stream, STREAMINFO first.
+type FLACConfig struct {
+	StreamInfo *FLACStreamInfoBlock
+	Vendor     string   // from the VORBIS_COMMENT block
+	Comments   []string // NAME=value entries of the VORBIS_COMMENT block
+	Blocks     []FLACMetadataBlock
+}
+
+// FLACMetadataBlock is one metadata block of a dfLa box.
+type FLACMetadataBlock struct {
+	Type byte
+	Last bool
+	Data []byte
+}
+
+// FLACStreamInfoBlock is a decoded STREAMINFO block.
+type FLACStreamInfoBlock struct {
+	MinBlockSize  uint16
+	MaxBlockSize  uint16
+	MinFrameSize  uint32
+	MaxFrameSize  uint32
+	SampleRate    uint32
+	Channels      uint8
+	BitsPerSample uint8
+	TotalSamples  uint64
+	MD5           [16]byte
+}
+
+// Comment returns the value of the first vorbis comment with the given
+// field name, compared case-insensitively.
+func (c *FLACConfig) Comment(name string) string {
+	for _, kv := range c.Comments {
+		if i := strings.IndexByte(kv, '='); i > 0 && strings.EqualFold(kv[:i], name) {
+			return kv[i+1:]
+		}
+	}
+	return ""
+}
+
+func parseFLACConfig(buf []byte) (*FLACConfig, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("dfLa too short: %d bytes", len(buf))
+	}
+	if buf[0] != 0 {
+		return nil, fmt.Errorf("dfLa: unsupported version %d", buf[0])
+	}
+	c := &FLACConfig{}
+	rest := buf[4:]
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, fmt.Errorf("dfLa: truncated metadata block header")
+		}
+		b := FLACMetadataBlock{
+			Last: rest[0]&0x80 != 0,
+			Type: rest[0] & 0x7f,
+		}
+		n := int(rest[1])<<16 | int(rest[2])<<8 | int(rest[3])
+		if 4+n > len(rest) {
+			return nil, fmt.Errorf("dfLa: metadata block %d length %d exceeds box", b.Type, n)
+		}
+		b.Data = rest[4 : 4+n]
+		rest = rest[4+n:]
+		c.Blocks = append(c.Blocks, b)
+
+		switch b.Type {
+		case FLACStreamInfo:
+			si, err := parseFLACStreamInfo(b.Data)
+			if err != nil {
+				return nil, err
+			}
+			c.StreamInfo = si
+		case FLACVorbisComment:
+			// a broken comment block does not make the stream unusable
+			c.Vendor, c.Comments, _ = parseVorbisComment(b.Data)
+		}
+		if b.Last {
+			break
+		}
+	}
+	if c.StreamInfo == nil {
+		return nil, fmt.Errorf("dfLa: missing STREAMINFO block")
+	}
+	return c, nil
+}
+
+func parseFLACStreamInfo(buf []byte) (*FLACStreamInfoBlock, error) {
+	if len(buf) < 34 {
+		return nil, fmt.Errorf("FLAC STREAMINFO too short: %d bytes", len(buf))
+	}
+	br := newBitReader(buf)
+	si := &FLACStreamInfoBlock{
+		MinBlockSize:  uint16(br.readBits(16)),
+		MaxBlockSize:  uint16(br.readBits(16)),
+		MinFrameSize:  uint32(br.readBits(24)),
+		MaxFrameSize:  uint32(br.readBits(24)),
+		SampleRate:    uint32(br.readBits(20)),
+		Channels:      uint8(br.readBits(3)) + 1,
+		BitsPerSample: uint8(br.readBits(5)) + 1,
+		TotalSamples:  br.readBits(36),
+	}
+	copy(si.MD5[:], buf[18:34])
+	return si, nil
+}
+
+// parseVorbisComment decodes a vorbis comment block, whose lengths are
+// little endian unlike the rest of the file.
+func parseVorbisComment(buf []byte) (string, []string, error) {
+	readString := func() (string, bool) {
+		if len(buf) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(buf)
+		if uint64(n) > uint64(len(buf)-4) {
+			return "", false
+		}
+		s := string(buf[4 : 4+n])
+		buf = buf[4+n:]
+		return s, true
+	}
+
+	vendor, ok := readString()
+	if !ok || len(buf) < 4 {
+		return "", nil, fmt.Errorf("vorbis comment: truncated vendor string")
+	}
+	count := binary.LittleEndian.Uint32(buf)
+	buf = buf[4:]
+	var comments []string
+	for i := uint32(0); i < count; i++ {
+		s, ok := readString()
+		if !ok {
+			return vendor, comments, fmt.Errorf("vorbis comment: truncated comment %d", i)
+		}
+		comments = append(comments, s)
+	}
+	return vendor, comments, nil
+}
+
+func applyFLACConfig(info *TrackInfo, cfg *FLACConfig) {
+	info.FLAC = cfg
+	info.CodecName = "FLAC"
+	si := cfg.StreamInfo
+	info.SampleRate = si.SampleRate
+	info.Channels = uint16(si.Channels)
+	info.SampleSize = uint16(si.BitsPerSample)
+}
@@ -0,0 +1,626 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// tfhd flags
+const (
+	tfhdBaseDataOffsetPresent         = 0x000001
+	tfhdSampleDescriptionIndexPresent = 0x000002
+	tfhdDefaultSampleDurationPresent  = 0x000008
+	tfhdDefaultSampleSizePresent      = 0x000010
+	tfhdDefaultSampleFlagsPresent     = 0x000020
+	tfhdDurationIsEmpty               = 0x010000
+	tfhdDefaultBaseIsMoof             = 0x020000
+)
+
+// trun flags
+const (
+	trunDataOffsetPresent                  = 0x000001
+	trunFirstSampleFlagsPresent            = 0x000004
+	trunSampleDurationPresent              = 0x000100
+	trunSampleSizePresent                  = 0x000200
+	trunSampleFlagsPresent                 = 0x000400
+	trunSampleCompositionTimeOffsetPresent = 0x000800
+)
+
+// sample_is_non_sync_sample bit of the sample flags
+const sampleIsNonSyncSample = 0x00010000
+
+// prft flags (ISO/IEC 14496-12 8.16.5)
+const (
+	PRFTEncoderInput   = 0
+	PRFTEncoderOutput  = 1
+	PRFTMoofFinalized  = 2
+	PRFTMoofWritten    = 4
+	PRFTArbitrary      = 8
+	PRFTCaptureTime    = 24
+	ntpUnixEpochOffset = 2208988800 // seconds between 1900-01-01 and 1970-01-01
+)
+
+// ProducerReferenceTime is a decoded prft box. It maps the wall-clock
+// time NTPTimestamp to MediaTime on the media timeline of the reference
+// track; Flags tell at which production step the wall-clock was taken.
+//
+//	aligned(8) class ProducerReferenceTimeBox extends FullBox('prft', version, flags) {
+//		unsigned int(32) reference_track_ID;
+//		unsigned int(64) ntp_timestamp;
+//		if (version==0) {
+//			unsigned int(32) media_time;
+//		} else {
+//			unsigned int(64) media_time;
+//		}
+//	}
+type ProducerReferenceTime struct {
+	Offset           int64 // file offset of the prft box
+	Version          byte
+	Flags            uint32
+	ReferenceTrackID uint32
+	NTPTimestamp     uint64
+	MediaTime        uint64
+}
+
+// Time converts the NTP timestamp to UTC.
+func (t *ProducerReferenceTime) Time() time.Time {
+	sec := int64(t.NTPTimestamp>>32) - ntpUnixEpochOffset
+	frac := t.NTPTimestamp & 0xFFFFFFFF
+	nsec := int64(frac * uint64(time.Second) >> 32)
+	return time.Unix(sec, nsec).UTC()
+}
+
+// FlagsName describes when the wall-clock time was taken.
+func (t *ProducerReferenceTime) FlagsName() string {
+	switch t.Flags {
+	case PRFTEncoderInput:
+		return "encoder input"
+	case PRFTEncoderOutput:
+		return "encoder output"
+	case PRFTMoofFinalized:
+		return "moof finalized"
+	case PRFTMoofWritten:
+		return "moof written"
+	case PRFTArbitrary:
+		return "arbitrary consistent"
+	case PRFTCaptureTime:
+		return "capture"
+	default:
+		return fmt.Sprintf("0x%x", t.Flags)
+	}
+}
+
+// TrackExtends holds the per track fragment defaults of a trex box.
+type TrackExtends struct {
+	TrackID                       uint32
+	DefaultSampleDescriptionIndex uint32
+	DefaultSampleDuration         uint32
+	DefaultSampleSize             uint32
+	DefaultSampleFlags            uint32
+}
+
+// Fragment is one movie fragment: a moof box and the mdat that follows it.
+type Fragment struct {
+	Offset         int64 // file offset of the moof box
+	Size           uint64
+	SequenceNumber uint32
+	MdatOffset     int64 // 0 if no mdat followed the moof
+	MdatSize       uint64
+	Segment        int                    // index of the segment (styp) the fragment belongs to
+	PRFT           *ProducerReferenceTime // prft box preceding the moof, if any
+	Tracks         []TrackFragment
+}
+
+// TrackFragment is a traf box with its trun samples resolved against the
+// tfhd and trex defaults.
+type TrackFragment struct {
+	TrackID                uint32
+	SampleDescriptionIndex uint32
+	BaseMediaDecodeTime    uint64
+	HasDecodeTime          bool // tfdt present
+	DurationIsEmpty        bool
+	Samples                []FragmentSample
+}
+
+// FragmentSample is one sample of a track run. Offset is the absolute file
+// offset of the sample data.
+type FragmentSample struct {
+	Offset            int64
+	Size              uint32
+	Duration          uint32
+	Flags             uint32
+	CompositionOffset int64 // unsigned in version 0 runs, signed in version 1
+}
+
+// IsSync reports whether the sample is a sync sample.
+func (s FragmentSample) IsSync() bool {
+	return s.Flags&sampleIsNonSyncSample == 0
+}
+
+// Duration returns the sum of the sample durations in media timescale units.
+func (tf *TrackFragment) Duration() uint64 {
+	var d uint64
+	for _, s := range tf.Samples {
+		d += uint64(s.Duration)
+	}
+	return d
+}
+
+// Bytes returns the total size of the sample data.
+func (tf *TrackFragment) Bytes() uint64 {
+	var n uint64
+	for _, s := range tf.Samples {
+		n += uint64(s.Size)
+	}
+	return n
+}
+
+func parsePrft(buf []byte) (*ProducerReferenceTime, error) {
+	r := bytes.NewReader(buf)
+	var versionFlags uint32
+	if err := binary.Read(r, binary.BigEndian, &versionFlags); err != nil {
+		return nil, fmt.Errorf("read prft version/flags: %w", err)
+	}
+	t := &ProducerReferenceTime{Version: byte(versionFlags >> 24), Flags: versionFlags & 0xFFFFFF}
+	if err := binary.Read(r, binary.BigEndian, &t.ReferenceTrackID); err != nil {
+		return nil, fmt.Errorf("read prft reference_track_ID: %w", err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &t.NTPTimestamp); err != nil {
+		return nil, fmt.Errorf("read prft ntp_timestamp: %w", err)
+	}
+	if t.Version == 0 {
+		var mediaTime uint32
+		if err := binary.Read(r, binary.BigEndian, &mediaTime); err != nil {
+			return nil, fmt.Errorf("read prft media_time: %w", err)
+		}
+		t.MediaTime = uint64(mediaTime)
+	} else if err := binary.Read(r, binary.BigEndian, &t.MediaTime); err != nil {
+		return nil, fmt.Errorf("read prft media_time: %w", err)
+	}
+	return t, nil
+}
+
+func parseTrex(buf []byte) (*TrackExtends, error) {
+	if len(buf) < 24 {
+		return nil, fmt.Errorf("trex too short: %d bytes", len(buf))
+	}
+	return &TrackExtends{
+		TrackID:                       binary.BigEndian.Uint32(buf[4:8]),
+		DefaultSampleDescriptionIndex: binary.BigEndian.Uint32(buf[8:12]),
+		DefaultSampleDuration:         binary.BigEndian.Uint32(buf[12:16]),
+		DefaultSampleSize:             binary.BigEndian.Uint32(buf[16:20]),
+		DefaultSampleFlags:            binary.BigEndian.Uint32(buf[20:24]),
+	}, nil
+}
+
+// trackFragmentHeader is a decoded tfhd box.
+type trackFragmentHeader struct {
+	flags                  uint32
+	trackID                uint32
+	baseDataOffset         uint64
+	sampleDescriptionIndex uint32
+	defaultSampleDuration  uint32
+	defaultSampleSize      uint32
+	defaultSampleFlags     uint32
+}
+
+func parseTfhd(buf []byte, trex map[uint32]*TrackExtends) (*trackFragmentHeader, error) {
+	r := bytes.NewReader(buf)
+	h := &trackFragmentHeader{}
+	if err := binary.Read(r, binary.BigEndian, &h.flags); err != nil {
+		return nil, fmt.Errorf("read tfhd version/flags: %w", err)
+	}
+	h.flags &= 0xFFFFFF
+	if err := binary.Read(r, binary.BigEndian, &h.trackID); err != nil {
+		return nil, fmt.Errorf("read tfhd track_ID: %w", err)
+	}
+
+	// start from the trex defaults of the track
+	if ex, ok := trex[h.trackID]; ok {
+		h.sampleDescriptionIndex = ex.DefaultSampleDescriptionIndex
+		h.defaultSampleDuration = ex.DefaultSampleDuration
+		h.defaultSampleSize = ex.DefaultSampleSize
+		h.defaultSampleFlags = ex.DefaultSampleFlags
+	}
+
+	var err error
+	if h.flags&tfhdBaseDataOffsetPresent != 0 && err == nil {
+		err = binary.Read(r, binary.BigEndian, &h.baseDataOffset)
+	}
+	if h.flags&tfhdSampleDescriptionIndexPresent != 0 && err == nil {
+		err = binary.Read(r, binary.BigEndian, &h.sampleDescriptionIndex)
+	}
+	if h.flags&tfhdDefaultSampleDurationPresent != 0 && err == nil {
+		err = binary.Read(r, binary.BigEndian, &h.defaultSampleDuration)
+	}
+	if h.flags&tfhdDefaultSampleSizePresent != 0 && err == nil {
+		err = binary.Read(r, binary.BigEndian, &h.defaultSampleSize)
+	}
+	if h.flags&tfhdDefaultSampleFlagsPresent != 0 && err == nil {
+		err = binary.Read(r, binary.BigEndian, &h.defaultSampleFlags)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read tfhd fields: %w", err)
+	}
+	return h, nil
+}
+
+func parseTfdt(buf []byte) (uint64, error) {
+	if len(buf) < 8 {
+		return 0, fmt.Errorf("tfdt too short: %d bytes", len(buf))
+	}
+	if buf[0] == 1 {
+		if len(buf) < 12 {
+			return 0, fmt.Errorf("tfdt too short: %d bytes", len(buf))
+		}
+		return binary.BigEndian.Uint64(buf[4:12]), nil
+	}
+	return uint64(binary.BigEndian.Uint32(buf[4:8])), nil
+}
+
+// parseTrun decodes a trun box. dataOffset is where the samples start when
+// the run has no data_offset of its own; the returned offset is the end of
+// this run's data, which is where the next run continues.
+func parseTrun(buf []byte, h *trackFragmentHeader, base, dataOffset int64) ([]FragmentSample, int64, error) {
+	r := bytes.NewReader(buf)
+	var versionFlags, sampleCount uint32
+	if err := binary.Read(r, binary.BigEndian, &versionFlags); err != nil {
+		return nil, 0, fmt.Errorf("read trun version/flags: %w", err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &sampleCount); err != nil {
+		return nil, 0, fmt.Errorf("read trun sample_count: %w", err)
+	}
+	version := versionFlags >> 24
+	flags := versionFlags & 0xFFFFFF
+
+	if flags&trunDataOffsetPresent != 0 {
+		var off int32
+		if err := binary.Read(r, binary.BigEndian, &off); err != nil {
+			return nil, 0, fmt.Errorf("read trun data_offset: %w", err)
+		}
+		dataOffset = base + int64(off)
+	}
+	firstSampleFlags, hasFirstSampleFlags := uint32(0), flags&trunFirstSampleFlagsPresent != 0
+	if hasFirstSampleFlags {
+		if err := binary.Read(r, binary.BigEndian, &firstSampleFlags); err != nil {
+			return nil, 0, fmt.Errorf("read trun first_sample_flags: %w", err)
+		}
+	}
+
+	perSample := 0
+	for _, f := range []uint32{trunSampleDurationPresent, trunSampleSizePresent, trunSampleFlagsPresent, trunSampleCompositionTimeOffsetPresent} {
+		if flags&f != 0 {
+			perSample += 4
+		}
+	}
+	if int64(sampleCount)*int64(perSample) > int64(r.Len()) {
+		return nil, 0, fmt.Errorf("trun sample_count %d exceeds box size", sampleCount)
+	}
+
+	samples := make([]FragmentSample, sampleCount)
+	for i := range samples {
+		s := FragmentSample{
+			Duration: h.defaultSampleDuration,
+			Size:     h.defaultSampleSize,
+			Flags:    h.defaultSampleFlags,
+		}
+		if i == 0 && hasFirstSampleFlags {
+			s.Flags = firstSampleFlags
+		}
+		if flags&trunSampleDurationPresent != 0 {
+			binary.Read(r, binary.BigEndian, &s.Duration)
+		}
+		if flags&trunSampleSizePresent != 0 {
+			binary.Read(r, binary.BigEndian, &s.Size)
+		}
+		if flags&trunSampleFlagsPresent != 0 {
+			binary.Read(r, binary.BigEndian, &s.Flags)
+		}
+		if flags&trunSampleCompositionTimeOffsetPresent != 0 {
+			var off uint32
+			binary.Read(r, binary.BigEndian, &off)
+			if version == 0 {
+				s.CompositionOffset = int64(off)
+			} else {
+				s.CompositionOffset = int64(int32(off))
+			}
+		}
+		s.Offset = dataOffset
+		dataOffset += int64(s.Size)
+		samples[i] = s
+	}
+
+	return samples, dataOffset, nil
+}
+
+// parse mvex atom
+func (p *MP4Parser) parseMvexAtom(size uint32) error {
+	end := cur(p.file) + int64(size)
+	for cur(p.file) < end {
+		atomSize, atomType, err := readHeader(p.file)
+		if err != nil {
+			return err
+		}
+		switch atomType {
+		case BoxTypeTREX:
+			buf, err := readChildPayload(p.file, atomType, atomSize, end)
+			if err != nil {
+				return err
+			}
+			ex, err := parseTrex(buf)
+			if err != nil {
+				return err
+			}
+			p.trex[ex.TrackID] = ex
+		default:
+			p.file.Seek(int64(atomSize-8), io.SeekCurrent)
+		}
+	}
+	return nil
+}
+
+// parse prft atom
+func (p *MP4Parser) parsePrftAtom(offset int64, dataSize int64) error {
+	buf, err := readPayload(p.file, dataSize)
+	if err != nil {
+		return fmt.Errorf("read prft: %w", err)
+	}
+	prft, err := parsePrft(buf)
+	if err != nil {
+		return err
+	}
+	prft.Offset = offset
+	p.prfts = append(p.prfts, *prft)
+	p.pendingPRFT = prft
+
+	return nil
+}
+
+// parse moof atom
+func (p *MP4Parser) parseMoofAtom(offset int64, dataSize int64) error {
+	end := cur(p.file) + dataSize
+	frag := Fragment{
+		Offset:  offset,
+		Size:    uint64(end - offset),
+		Segment: p.segment,
+		PRFT:    p.pendingPRFT,
+	}
+	p.pendingPRFT = nil
+
+	// data of the first traf without an explicit base starts at the moof
+	nextBase := offset
+	for cur(p.file) < end {
+		atomSize, atomType, err := readHeader(p.file)
+		if err != nil {
+			return err
+		}
+		switch atomType {
+		case BoxTypeMFHD:
+			buf, err := readChildPayload(p.file, atomType, atomSize, end)
+			if err != nil {
+				return err
+			}
+			if len(buf) < 8 {
+				return fmt.Errorf("mfhd too short: %d bytes", len(buf))
+			}
+			frag.SequenceNumber = binary.BigEndian.Uint32(buf[4:])
+		case BoxTypeTRAF:
+			if err := checkChildSize(p.file, atomType, atomSize, end); err != nil {
+				return err
+			}
+			tf, dataEnd, err := p.parseTrafAtom(atomSize-8, offset, nextBase)
+			if err != nil {
+				return err
+			}
+			frag.Tracks = append(frag.Tracks, *tf)
+			nextBase = dataEnd
+		default:
+			p.file.Seek(int64(atomSize-8), io.SeekCurrent)
+		}
+	}
+	p.file.Seek(end, io.SeekStart)
+
+	p.fragments = append(p.fragments, frag)
+	return nil
+}
+
+// parse traf atom, returns the end offset of the track fragment's data
+func (p *MP4Parser) parseTrafAtom(size uint32, moofOffset, implicitBase int64) (*TrackFragment, int64, error) {
+	end := cur(p.file) + int64(size)
+	tf := &TrackFragment{}
+	var header *trackFragmentHeader
+	base := implicitBase
+	dataOffset := implicitBase
+
+	for cur(p.file) < end {
+		atomSize, atomType, err := readHeader(p.file)
+		if err != nil {
+			return nil, 0, err
+		}
+		buf, err := readChildPayload(p.file, atomType, atomSize, end)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		switch atomType {
+		case BoxTypeTFHD:
+			if header, err = parseTfhd(buf, p.trex); err != nil {
+				return nil, 0, err
+			}
+			tf.TrackID = header.trackID
+			tf.SampleDescriptionIndex = header.sampleDescriptionIndex
+			tf.DurationIsEmpty = header.flags&tfhdDurationIsEmpty != 0
+			if header.flags&tfhdBaseDataOffsetPresent != 0 {
+				base = int64(header.baseDataOffset)
+			} else if header.flags&tfhdDefaultBaseIsMoof != 0 {
+				base = moofOffset
+			}
+			dataOffset = base
+		case BoxTypeTFDT:
+			if tf.BaseMediaDecodeTime, err = parseTfdt(buf); err != nil {
+				return nil, 0, err
+			}
+			tf.HasDecodeTime = true
+		case BoxTypeTRUN:
+			if header == nil {
+				return nil, 0, fmt.Errorf("trun before tfhd")
+			}
+			samples, next, err := parseTrun(buf, header, base, dataOffset)
+			if err != nil {
+				return nil, 0, err
+			}
+			tf.Samples = append(tf.Samples, samples...)
+			dataOffset = next
+		}
+	}
+
+	return tf, dataOffset, nil
+}
+
+// ChunkInfo describes one CMAF chunk (a moof/mdat pair) of one track.
+type ChunkInfo struct {
+	SequenceNumber      uint32
+	TrackID             uint32
+	Timescale           uint32 // 0 when the track's moov is not in the file
+	BaseMediaDecodeTime uint64
+	DurationTicks       uint64
+	Duration            time.Duration // 0 when Timescale is unknown
+	SampleCount         int
+	Bytes               uint64
+	StartsWithSync      bool
+	PRFT                *ProducerReferenceTime
+
+	// Latency is the producer wall-clock of the prft minus the wall-clock
+	// of its media_time. When the media timeline is anchored at the Unix
+	// epoch (DASH-IF live with UTC timing) this is the absolute encoder
+	// latency; otherwise the timeline is anchored at the first prft of the
+	// track and LatencyRelative is set, so the value is the latency change
+	// since then.
+	Latency         time.Duration
+	HasLatency      bool
+	LatencyRelative bool
+}
+
+// SegmentInfo groups the chunks of a segment. Segments are delimited by
+// styp boxes, or if there are none by the chunks of the video reference
+// track that start with a sync sample.
+type SegmentInfo struct {
+	Index       int
+	Chunks      []ChunkInfo
+	Duration    time.Duration // of the longest track
+	SampleCount int
+}
+
+// segmentReferenceTrack returns the video track whose sync samples delimit
+// segments when there are no styp boxes, or 0 if there is none. A track
+// without non-sync samples is not used: on audio and all-intra tracks every
+// chunk starts with a sync sample, so all fragments stay in one segment.
+func (p *MP4Parser) segmentReferenceTrack() uint32 {
+	for _, t := range p.tracks {
+		if t.HandlerType != "vide" {
+			continue
+		}
+		for _, frag := range p.fragments {
+			for _, tf := range frag.Tracks {
+				if tf.TrackID != t.TrackID {
+					continue
+				}
+				for _, s := range tf.Samples {
+					if !s.IsSync() {
+						return t.TrackID
+					}
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// AnalyzeChunks reports per segment chunk durations, sample counts and the
+// prft implied latency of a fragmented file.
+func (p *MP4Parser) AnalyzeChunks() []SegmentInfo {
+	timescales := map[uint32]uint32{}
+	for _, t := range p.tracks {
+		timescales[t.TrackID] = t.Timescale
+	}
+
+	// latency anchors per reference track: wall-clock minus media time
+	type anchor struct {
+		offset   time.Duration
+		relative bool
+	}
+	anchors := map[uint32]*anchor{}
+
+	refTrack := p.segmentReferenceTrack()
+	var segments []SegmentInfo
+	lastSegment := -1
+	for _, frag := range p.fragments {
+		newSegment := len(segments) == 0
+		if p.hasStyp {
+			newSegment = newSegment || frag.Segment != lastSegment
+			lastSegment = frag.Segment
+		} else {
+			for _, tf := range frag.Tracks {
+				if tf.TrackID == refTrack && len(tf.Samples) > 0 {
+					newSegment = newSegment || tf.Samples[0].IsSync()
+				}
+			}
+		}
+		if newSegment {
+			segments = append(segments, SegmentInfo{Index: len(segments)})
+		}
+		seg := &segments[len(segments)-1]
+
+		for _, tf := range frag.Tracks {
+			c := ChunkInfo{
+				SequenceNumber:      frag.SequenceNumber,
+				TrackID:             tf.TrackID,
+				Timescale:           timescales[tf.TrackID],
+				BaseMediaDecodeTime: tf.BaseMediaDecodeTime,
+				DurationTicks:       tf.Duration(),
+				SampleCount:         len(tf.Samples),
+				Bytes:               tf.Bytes(),
+				StartsWithSync:      len(tf.Samples) > 0 && tf.Samples[0].IsSync(),
+			}
+			if c.Timescale > 0 {
+				c.Duration = scaleToDuration(c.DurationTicks, c.Timescale)
+			}
+
+			if frag.PRFT != nil && frag.PRFT.ReferenceTrackID == tf.TrackID && c.Timescale > 0 {
+				c.PRFT = frag.PRFT
+				offset := frag.PRFT.Time().Sub(time.Unix(0, 0)) - scaleToDuration(frag.PRFT.MediaTime, c.Timescale)
+				a, ok := anchors[tf.TrackID]
+				if !ok {
+					a = &anchor{}
+					if math.Abs(offset.Hours()) > 24 {
+						a.offset, a.relative = offset, true
+					}
+					anchors[tf.TrackID] = a
+				}
+				c.Latency = offset - a.offset
+				c.HasLatency = true
+				c.LatencyRelative = a.relative
+			}
+
+			seg.Chunks = append(seg.Chunks, c)
+			seg.SampleCount += c.SampleCount
+		}
+	}
+
+	// segment duration is the longest per track sum of chunk durations
+	for i := range segments {
+		perTrack := map[uint32]time.Duration{}
+		for _, c := range segments[i].Chunks {
+			perTrack[c.TrackID] += c.Duration
+			if perTrack[c.TrackID] > segments[i].Duration {
+				segments[i].Duration = perTrack[c.TrackID]
+			}
+		}
+	}
+
+	return segments
+}
@@ -0,0 +1,43 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseTrunCompositionOffsets(t *testing.T) {
+	h := &trackFragmentHeader{defaultSampleDuration: 1000, defaultSampleSize: 10}
+	for _, tt := range []struct {
+		version byte
+		want    int64
+	}{
+		{0, 0xfffffff6},
+		{1, -10},
+	} {
+		flags := uint32(trunDataOffsetPresent | trunSampleCompositionTimeOffsetPresent)
+		buf := binary.BigEndian.AppendUint32(nil, uint32(tt.version)<<24|flags)
+		buf = binary.BigEndian.AppendUint32(buf, 2) // sample_count
+		buf = binary.BigEndian.AppendUint32(buf, 8) // data_offset
+		buf = binary.BigEndian.AppendUint32(buf, 0)
+		buf = binary.BigEndian.AppendUint32(buf, 0xfffffff6)
+		samples, next, err := parseTrun(buf, h, 100, 0)
+		if err != nil {
+			t.Fatalf("version %d: %v", tt.version, err)
+		}
+		if len(samples) != 2 || samples[0].Offset != 108 || samples[1].Offset != 118 || next != 128 {
+			t.Fatalf("version %d: samples %+v, next %d", tt.version, samples, next)
+		}
+		if got := samples[1].CompositionOffset; got != tt.want {
+			t.Errorf("version %d: composition offset = %d, want %d", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseTrunSampleCountPastBox(t *testing.T) {
+	buf := binary.BigEndian.AppendUint32(nil, trunSampleSizePresent)
+	buf = binary.BigEndian.AppendUint32(buf, 0x10000000)
+	buf = binary.BigEndian.AppendUint32(buf, 10)
+	if _, _, err := parseTrun(buf, &trackFragmentHeader{}, 0, 0); err == nil {
+		t.Error("trun with more samples than the box holds was accepted")
+	}
+}
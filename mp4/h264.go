@@ -0,0 +1,385 @@
+package mp4
+
+import (
+	"errors"
+	"fmt"
+)
+
+// H264SPS is a decoded H.264 sequence parameter set (ITU-T H.264 7.3.2.1).
+type H264SPS struct {
+	ProfileIdc              uint8
+	ConstraintFlags         uint8
+	LevelIdc                uint8
+	ID                      uint32
+	ChromaFormatIdc         uint32
+	SeparateColourPlane     bool
+	BitDepthLuma            uint32
+	BitDepthChroma          uint32
+	Log2MaxFrameNum         uint32
+	PicOrderCntType         uint32
+	MaxNumRefFrames         uint32
+	PicWidthInMbs           uint32
+	PicHeightInMapUnits     uint32
+	FrameMbsOnly            bool
+	MbAdaptiveFrameField    bool
+	Direct8x8Inference      bool
+	FrameCropping           bool
+	CropLeft, CropRight     uint32 // frame_crop_*_offset in crop units
+	CropTop, CropBottom     uint32
+	CodedWidth, CodedHeight uint32 // decoded picture size in luma samples
+	Width, Height           uint32 // size after the cropping window
+	VUI                     *H264VUI
+}
+
+// H264VUI holds the VUI parameters of an SPS (ITU-T H.264 E.1.1).
+type H264VUI struct {
+	AspectRatioIdc          uint8
+	SarWidth, SarHeight     uint16
+	VideoFormat             uint8
+	VideoFullRange          bool
+	ColourDescription       bool
+	ColourPrimaries         uint8
+	TransferCharacteristics uint8
+	MatrixCoefficients      uint8
+	TimingInfoPresent       bool
+	NumUnitsInTick          uint32
+	TimeScale               uint32
+	FixedFrameRate          bool
+	NalHRD, VclHRD          bool
+	PicStructPresent        bool
+	BitstreamRestriction    bool
+	MaxNumReorderFrames     uint32
+	MaxDecFrameBuffering    uint32
+}
+
+// H264PPS is a decoded H.264 picture parameter set (ITU-T H.264 7.3.2.2).
+type H264PPS struct {
+	ID                         uint32
+	SPSID                      uint32
+	EntropyCodingModeCABAC     bool
+	BottomFieldPicOrderPresent bool
+	NumSliceGroups             uint32
+	NumRefIdxL0DefaultActive   uint32
+	NumRefIdxL1DefaultActive   uint32
+	WeightedPred               bool
+	WeightedBipredIdc          uint8
+	PicInitQP                  int32
+	PicInitQS                  int32
+	ChromaQPIndexOffset        int32
+	DeblockingFilterControl    bool
+	ConstrainedIntraPred       bool
+	RedundantPicCntPresent     bool
+	Transform8x8Mode           bool
+	SecondChromaQPIndexOffset  int32
+}
+
+// sample aspect ratios of aspect_ratio_idc 1..16 (Table E-1)
+var h264SampleAspectRatios = [...][2]uint16{
+	{1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11}, {32, 11},
+	{80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+}
+
+// sampleAspectRatio maps an aspect_ratio_idc to its SAR. Extended_SAR (255)
+// returns the explicit values.
+func sampleAspectRatio(idc uint8, w, h uint16) (uint16, uint16) {
+	if idc == 255 {
+		return w, h
+	}
+	if idc >= 1 && int(idc) <= len(h264SampleAspectRatios) {
+		sar := h264SampleAspectRatios[idc-1]
+		return sar[0], sar[1]
+	}
+	return 0, 0
+}
+
+// SAR returns the sample aspect ratio, or 0:0 when unspecified.
+func (v *H264VUI) SAR() (uint16, uint16) {
+	return sampleAspectRatio(v.AspectRatioIdc, v.SarWidth, v.SarHeight)
+}
+
+// FrameRate returns the frame rate signalled by the VUI timing info, or 0.
+func (s *H264SPS) FrameRate() float64 {
+	if s.VUI == nil || !s.VUI.TimingInfoPresent || s.VUI.NumUnitsInTick == 0 {
+		return 0
+	}
+	return float64(s.VUI.TimeScale) / float64(2*s.VUI.NumUnitsInTick)
+}
+
+// hasH264ChromaInfo reports whether profile_idc carries chroma_format_idc
+// and bit depth fields in the SPS.
+func hasH264ChromaInfo(profile uint8) bool {
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		return true
+	}
+	return false
+}
+
+// parseH264SPS decodes an SPS NAL unit, including its one byte NAL header.
+func parseH264SPS(nal []byte) (*H264SPS, error) {
+	if len(nal) < 4 || nal[0]&0x1f != 7 {
+		return nil, errors.New("h264: not an SPS NAL unit")
+	}
+	br := newBitReader(unescapeRBSP(nal[1:]))
+	s := &H264SPS{}
+
+	s.ProfileIdc = uint8(br.readBits(8))
+	s.ConstraintFlags = uint8(br.readBits(8))
+	s.LevelIdc = uint8(br.readBits(8))
+	s.ID = br.readUE()
+
+	s.ChromaFormatIdc = 1
+	s.BitDepthLuma, s.BitDepthChroma = 8, 8
+	if hasH264ChromaInfo(s.ProfileIdc) {
+		s.ChromaFormatIdc = br.readUE()
+		if s.ChromaFormatIdc == 3 {
+			s.SeparateColourPlane = br.readFlag()
+		}
+		s.BitDepthLuma = br.readUE() + 8
+		s.BitDepthChroma = br.readUE() + 8
+		br.skipBits(1)     // qpprime_y_zero_transform_bypass_flag
+		if br.readFlag() { // seq_scaling_matrix_present_flag
+			n := 8
+			if s.ChromaFormatIdc == 3 {
+				n = 12
+			}
+			for i := 0; i < n; i++ {
+				if br.readFlag() {
+					if i < 6 {
+						skipH264ScalingList(br, 16)
+					} else {
+						skipH264ScalingList(br, 64)
+					}
+				}
+			}
+		}
+	}
+
+	s.Log2MaxFrameNum = br.readUE() + 4
+	s.PicOrderCntType = br.readUE()
+	switch s.PicOrderCntType {
+	case 0:
+		br.readUE() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		br.skipBits(1) // delta_pic_order_always_zero_flag
+		br.readSE()    // offset_for_non_ref_pic
+		br.readSE()    // offset_for_top_to_bottom_field
+		n := br.readUE()
+		for i := uint32(0); i < n && br.err == nil; i++ {
+			br.readSE() // offset_for_ref_frame
+		}
+	}
+	s.MaxNumRefFrames = br.readUE()
+	br.skipBits(1) // gaps_in_frame_num_value_allowed_flag
+	s.PicWidthInMbs = br.readUE() + 1
+	s.PicHeightInMapUnits = br.readUE() + 1
+	s.FrameMbsOnly = br.readFlag()
+	if !s.FrameMbsOnly {
+		s.MbAdaptiveFrameField = br.readFlag()
+	}
+	s.Direct8x8Inference = br.readFlag()
+	s.FrameCropping = br.readFlag()
+	if s.FrameCropping {
+		s.CropLeft = br.readUE()
+		s.CropRight = br.readUE()
+		s.CropTop = br.readUE()
+		s.CropBottom = br.readUE()
+	}
+	if br.readFlag() {
+		s.VUI = parseH264VUI(br)
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("h264 SPS: %w", br.err)
+	}
+
+	frameHeightFactor := uint32(1)
+	if !s.FrameMbsOnly {
+		frameHeightFactor = 2
+	}
+	s.CodedWidth = s.PicWidthInMbs * 16
+	s.CodedHeight = frameHeightFactor * s.PicHeightInMapUnits * 16
+
+	// crop units depend on the chroma subsampling (7.4.2.1.1)
+	cropUnitX, cropUnitY := uint32(1), frameHeightFactor
+	if !s.SeparateColourPlane {
+		switch s.ChromaFormatIdc {
+		case 1:
+			cropUnitX, cropUnitY = 2, 2*frameHeightFactor
+		case 2:
+			cropUnitX = 2
+		}
+	}
+	cropX := (s.CropLeft + s.CropRight) * cropUnitX
+	cropY := (s.CropTop + s.CropBottom) * cropUnitY
+	if cropX >= s.CodedWidth || cropY >= s.CodedHeight {
+		return nil, fmt.Errorf("h264 SPS: cropping window %dx%d exceeds coded size %dx%d", cropX, cropY, s.CodedWidth, s.CodedHeight)
+	}
+	s.Width = s.CodedWidth - cropX
+	s.Height = s.CodedHeight - cropY
+
+	return s, nil
+}
+
+func skipH264ScalingList(br *bitReader, size int) {
+	lastScale, nextScale := int32(8), int32(8)
+	for j := 0; j < size && br.err == nil; j++ {
+		if nextScale != 0 {
+			delta := br.readSE()
+			nextScale = (lastScale + delta + 256) % 256
+		}
+		if nextScale != 0 {
+			lastScale = nextScale
+		}
+	}
+}
+
+func parseH264VUI(br *bitReader) *H264VUI {
+	v := &H264VUI{}
+	if br.readFlag() { // aspect_ratio_info_present_flag
+		v.AspectRatioIdc = uint8(br.readBits(8))
+		if v.AspectRatioIdc == 255 {
+			v.SarWidth = uint16(br.readBits(16))
+			v.SarHeight = uint16(br.readBits(16))
+		}
+	}
+	if br.readFlag() { // overscan_info_present_flag
+		br.skipBits(1)
+	}
+	// unspecified unless signalled
+	v.VideoFormat = 5
+	v.ColourPrimaries, v.TransferCharacteristics, v.MatrixCoefficients = 2, 2, 2
+	if br.readFlag() { // video_signal_type_present_flag
+		v.VideoFormat = uint8(br.readBits(3))
+		v.VideoFullRange = br.readFlag()
+		v.ColourDescription = br.readFlag()
+		if v.ColourDescription {
+			v.ColourPrimaries = uint8(br.readBits(8))
+			v.TransferCharacteristics = uint8(br.readBits(8))
+			v.MatrixCoefficients = uint8(br.readBits(8))
+		}
+	}
+	if br.readFlag() { // chroma_loc_info_present_flag
+		br.readUE()
+		br.readUE()
+	}
+	v.TimingInfoPresent = br.readFlag()
+	if v.TimingInfoPresent {
+		v.NumUnitsInTick = uint32(br.readBits(32))
+		v.TimeScale = uint32(br.readBits(32))
+		v.FixedFrameRate = br.readFlag()
+	}
+	v.NalHRD = br.readFlag()
+	if v.NalHRD {
+		skipH264HRD(br)
+	}
+	v.VclHRD = br.readFlag()
+	if v.VclHRD {
+		skipH264HRD(br)
+	}
+	if v.NalHRD || v.VclHRD {
+		br.skipBits(1) // low_delay_hrd_flag
+	}
+	v.PicStructPresent = br.readFlag()
+	v.BitstreamRestriction = br.readFlag()
+	if v.BitstreamRestriction {
+		br.skipBits(1) // motion_vectors_over_pic_boundaries_flag
+		br.readUE()    // max_bytes_per_pic_denom
+		br.readUE()    // max_bits_per_mb_denom
+		br.readUE()    // log2_max_mv_length_horizontal
+		br.readUE()    // log2_max_mv_length_vertical
+		v.MaxNumReorderFrames = br.readUE()
+		v.MaxDecFrameBuffering = br.readUE()
+	}
+	return v
+}
+
+func skipH264HRD(br *bitReader) {
+	cpbCnt := br.readUE() + 1
+	br.skipBits(8) // bit_rate_scale, cpb_size_scale
+	for i := uint32(0); i < cpbCnt && br.err == nil; i++ {
+		br.readUE() // bit_rate_value_minus1
+		br.readUE() // cpb_size_value_minus1
+		br.skipBits(1)
+	}
+	br.skipBits(20) // delay and offset lengths
+}
+
+// parseH264PPS decodes a PPS NAL unit. The referenced SPS is needed for the
+// chroma format of the optional scaling matrices; it may be nil.
+func parseH264PPS(nal []byte, sps *H264SPS) (*H264PPS, error) {
+	if len(nal) < 2 || nal[0]&0x1f != 8 {
+		return nil, errors.New("h264: not a PPS NAL unit")
+	}
+	br := newBitReader(unescapeRBSP(nal[1:]))
+	p := &H264PPS{}
+
+	p.ID = br.readUE()
+	p.SPSID = br.readUE()
+	p.EntropyCodingModeCABAC = br.readFlag()
+	p.BottomFieldPicOrderPresent = br.readFlag()
+	p.NumSliceGroups = br.readUE() + 1
+	if p.NumSliceGroups > 1 {
+		mapType := br.readUE()
+		switch mapType {
+		case 0:
+			for i := uint32(0); i < p.NumSliceGroups && br.err == nil; i++ {
+				br.readUE() // run_length_minus1
+			}
+		case 2:
+			for i := uint32(0); i < p.NumSliceGroups-1 && br.err == nil; i++ {
+				br.readUE() // top_left
+				br.readUE() // bottom_right
+			}
+		case 3, 4, 5:
+			br.skipBits(1) // slice_group_change_direction_flag
+			br.readUE()    // slice_group_change_rate_minus1
+		case 6:
+			n := br.readUE() + 1
+			bits := 0
+			for (uint32(1) << uint(bits)) < p.NumSliceGroups {
+				bits++
+			}
+			br.skipBits(int(n) * bits)
+		}
+	}
+	p.NumRefIdxL0DefaultActive = br.readUE() + 1
+	p.NumRefIdxL1DefaultActive = br.readUE() + 1
+	p.WeightedPred = br.readFlag()
+	p.WeightedBipredIdc = uint8(br.readBits(2))
+	p.PicInitQP = br.readSE() + 26
+	p.PicInitQS = br.readSE() + 26
+	p.ChromaQPIndexOffset = br.readSE()
+	p.DeblockingFilterControl = br.readFlag()
+	p.ConstrainedIntraPred = br.readFlag()
+	p.RedundantPicCntPresent = br.readFlag()
+	p.SecondChromaQPIndexOffset = p.ChromaQPIndexOffset
+
+	if br.moreRBSPData() {
+		p.Transform8x8Mode = br.readFlag()
+		if br.readFlag() { // pic_scaling_matrix_present_flag
+			n := 6
+			if p.Transform8x8Mode {
+				if sps != nil && sps.ChromaFormatIdc == 3 {
+					n += 6
+				} else {
+					n += 2
+				}
+			}
+			for i := 0; i < n; i++ {
+				if br.readFlag() {
+					if i < 6 {
+						skipH264ScalingList(br, 16)
+					} else {
+						skipH264ScalingList(br, 64)
+					}
+				}
+			}
+		}
+		p.SecondChromaQPIndexOffset = br.readSE()
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("h264 PPS: %w", br.err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,646 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// HEVC NAL unit types carried in hvcC arrays
+const (
+	hevcNALVPS = 32
+	hevcNALSPS = 33
+	hevcNALPPS = 34
+)
+
+// HEVCDecoderConfig is a decoded hvcC box (ISO/IEC 14496-15 8.3.3.1).
+//
+//	aligned(8) class HEVCDecoderConfigurationRecord {
+//		unsigned int(8) configurationVersion = 1;
+//		unsigned int(2) general_profile_space;
+//		unsigned int(1) general_tier_flag;
+//		unsigned int(5) general_profile_idc;
+//		unsigned int(32) general_profile_compatibility_flags;
+//		unsigned int(48) general_constraint_indicator_flags;
+//		unsigned int(8) general_level_idc;
+//		bit(4) reserved = '1111'b;
+//		unsigned int(12) min_spatial_segmentation_idc;
+//		bit(6) reserved = '111111'b;
+//		unsigned int(2) parallelismType;
+//		bit(6) reserved = '111111'b;
+//		unsigned int(2) chroma_format_idc;
+//		bit(5) reserved = '11111'b;
+//		unsigned int(3) bit_depth_luma_minus8;
+//		bit(5) reserved = '11111'b;
+//		unsigned int(3) bit_depth_chroma_minus8;
+//		bit(16) avgFrameRate;
+//		bit(2) constantFrameRate;
+//		bit(3) numTemporalLayers;
+//		bit(1) temporalIdNested;
+//		unsigned int(2) lengthSizeMinusOne;
+//		unsigned int(8) numOfArrays;
+//		for (j=0; j < numOfArrays; j++) {
+//			bit(1) array_completeness;
+//			unsigned int(1) reserved = 0;
+//			unsigned int(6) NAL_unit_type;
+//			unsigned int(16) numNalus;
+//			for (i=0; i< numNalus; i++) {
+//				unsigned int(16) nalUnitLength;
+//				bit(8*nalUnitLength) nalUnit;
+//			}
+//		}
+//	}
+type HEVCDecoderConfig struct {
+	ConfigurationVersion      byte
+	ProfileSpace              byte
+	TierFlag                  bool
+	ProfileIdc                byte
+	ProfileCompatibilityFlags uint32
+	ConstraintIndicatorFlags  uint64 // 48 bits
+	LevelIdc                  byte
+	MinSpatialSegmentationIdc uint16
+	ParallelismType           byte
+	ChromaFormatIdc           byte
+	BitDepthLuma              byte
+	BitDepthChroma            byte
+	AvgFrameRate              uint16 // frames per 256 seconds
+	ConstantFrameRate         byte
+	NumTemporalLayers         byte
+	TemporalIDNested          bool
+	NALULengthSize            int
+	Arrays                    []HEVCNALArray
+}
+
+// HEVCNALArray is one parameter set array of an hvcC box.
+type HEVCNALArray struct {
+	Complete    bool
+	NALUnitType byte
+	NALUnits    [][]byte
+}
+
+// HEVCProfileTierLevel is the general part of profile_tier_level().
+type HEVCProfileTierLevel struct {
+	ProfileSpace              uint8
+	TierFlag                  bool
+	ProfileIdc                uint8
+	ProfileCompatibilityFlags uint32
+	ProgressiveSource         bool
+	InterlacedSource          bool
+	NonPackedConstraint       bool
+	FrameOnlyConstraint       bool
+	LevelIdc                  uint8
+}
+
+// HEVCVPS is a decoded video parameter set (ITU-T H.265 7.3.2.1).
+type HEVCVPS struct {
+	ID                uint8
+	MaxLayers         uint8
+	MaxSubLayers      uint8
+	TemporalIDNesting bool
+	PTL               HEVCProfileTierLevel
+	TimingInfoPresent bool
+	NumUnitsInTick    uint32
+	TimeScale         uint32
+}
+
+// HEVCSPS is a decoded sequence parameter set (ITU-T H.265 7.3.2.2).
+type HEVCSPS struct {
+	VPSID                   uint8
+	MaxSubLayers            uint8
+	TemporalIDNesting       bool
+	PTL                     HEVCProfileTierLevel
+	ID                      uint32
+	ChromaFormatIdc         uint32
+	SeparateColourPlane     bool
+	CodedWidth, CodedHeight uint32 // pic_width/height_in_luma_samples
+	ConformanceWindow       bool
+	ConfWinLeft             uint32 // conf_win_*_offset in chroma sample units
+	ConfWinRight            uint32
+	ConfWinTop              uint32
+	ConfWinBottom           uint32
+	Width, Height           uint32 // size after the conformance window
+	BitDepthLuma            uint32
+	BitDepthChroma          uint32
+	Log2MaxPicOrderCntLsb   uint32
+	MaxDecPicBuffering      uint32 // of the highest sub-layer
+	MaxNumReorderPics       uint32
+	MaxLatencyIncrease      uint32
+	AMPEnabled              bool
+	SAOEnabled              bool
+	PCMEnabled              bool
+	NumShortTermRefPicSets  uint32
+	LongTermRefPicsPresent  bool
+	TemporalMVPEnabled      bool
+	StrongIntraSmoothing    bool
+	VUI                     *HEVCVUI
+}
+
+// HEVCVUI holds the VUI parameters of an SPS up to the timing info
+// (ITU-T H.265 E.2.1); HRD parameters and what follows are not decoded.
+type HEVCVUI struct {
+	AspectRatioIdc          uint8
+	SarWidth, SarHeight     uint16
+	VideoFormat             uint8
+	VideoFullRange          bool
+	ColourDescription       bool
+	ColourPrimaries         uint8
+	TransferCharacteristics uint8
+	MatrixCoefficients      uint8
+	FieldSeq                bool
+	FrameFieldInfoPresent   bool
+	DefaultDisplayWindow    bool
+	DefDispWinLeft          uint32
+	DefDispWinRight         uint32
+	DefDispWinTop           uint32
+	DefDispWinBottom        uint32
+	TimingInfoPresent       bool
+	NumUnitsInTick          uint32
+	TimeScale               uint32
+}
+
+// SAR returns the sample aspect ratio, or 0:0 when unspecified.
+func (v *HEVCVUI) SAR() (uint16, uint16) {
+	return sampleAspectRatio(v.AspectRatioIdc, v.SarWidth, v.SarHeight)
+}
+
+// FrameRate returns the frame rate signalled by the VUI timing info, or 0.
+func (s *HEVCSPS) FrameRate() float64 {
+	if s.VUI == nil || !s.VUI.TimingInfoPresent || s.VUI.NumUnitsInTick == 0 {
+		return 0
+	}
+	return float64(s.VUI.TimeScale) / float64(s.VUI.NumUnitsInTick)
+}
+
+func parseHEVCDecoderConfig(buf []byte) (*HEVCDecoderConfig, error) {
+	if len(buf) < 23 {
+		return nil, fmt.Errorf("hvcC too short: %d bytes", len(buf))
+	}
+	c := &HEVCDecoderConfig{
+		ConfigurationVersion:      buf[0],
+		ProfileSpace:              buf[1] >> 6,
+		TierFlag:                  buf[1]&0x20 != 0,
+		ProfileIdc:                buf[1] & 0x1f,
+		ProfileCompatibilityFlags: binary.BigEndian.Uint32(buf[2:6]),
+		ConstraintIndicatorFlags:  uint64(binary.BigEndian.Uint16(buf[6:8]))<<32 | uint64(binary.BigEndian.Uint32(buf[8:12])),
+		LevelIdc:                  buf[12],
+		MinSpatialSegmentationIdc: binary.BigEndian.Uint16(buf[13:15]) & 0x0fff,
+		ParallelismType:           buf[15] & 0x03,
+		ChromaFormatIdc:           buf[16] & 0x03,
+		BitDepthLuma:              buf[17]&0x07 + 8,
+		BitDepthChroma:            buf[18]&0x07 + 8,
+		AvgFrameRate:              binary.BigEndian.Uint16(buf[19:21]),
+		ConstantFrameRate:         buf[21] >> 6,
+		NumTemporalLayers:         buf[21] >> 3 & 0x07,
+		TemporalIDNested:          buf[21]&0x04 != 0,
+		NALULengthSize:            int(buf[21]&0x03) + 1,
+	}
+
+	numArrays := int(buf[22])
+	pos := 23
+	for i := 0; i < numArrays; i++ {
+		if pos+3 > len(buf) {
+			return nil, fmt.Errorf("hvcC: truncated array %d", i)
+		}
+		a := HEVCNALArray{
+			Complete:    buf[pos]&0x80 != 0,
+			NALUnitType: buf[pos] & 0x3f,
+		}
+		numNalus := int(binary.BigEndian.Uint16(buf[pos+1:]))
+		pos += 3
+		for j := 0; j < numNalus; j++ {
+			if pos+2 > len(buf) {
+				return nil, fmt.Errorf("hvcC: truncated NAL unit %d of array %d", j, i)
+			}
+			n := int(binary.BigEndian.Uint16(buf[pos:]))
+			pos += 2
+			if pos+n > len(buf) {
+				return nil, fmt.Errorf("hvcC: NAL unit length %d exceeds box", n)
+			}
+			a.NALUnits = append(a.NALUnits, buf[pos:pos+n])
+			pos += n
+		}
+		c.Arrays = append(c.Arrays, a)
+	}
+
+	return c, nil
+}
+
+// NALUnits returns the NAL units of the given type from all arrays.
+func (c *HEVCDecoderConfig) NALUnits(nalType byte) [][]byte {
+	var units [][]byte
+	for _, a := range c.Arrays {
+		if a.NALUnitType == nalType {
+			units = append(units, a.NALUnits...)
+		}
+	}
+	return units
+}
+
+// applyHEVCConfig fills the track fields derived from hvcC and its VPS/SPS.
+// Parameter sets that fail to decode only produce a warning.
+func applyHEVCConfig(info *TrackInfo, cfg *HEVCDecoderConfig) {
+	info.HEVCConfig = cfg
+	info.Profile = HEVCProfileName(cfg.ProfileIdc, cfg.ProfileCompatibilityFlags)
+	info.Level = HEVCLevelName(cfg.LevelIdc, cfg.TierFlag)
+	info.ChromaFormat = ChromaFormatName(uint32(cfg.ChromaFormatIdc))
+	info.BitDepth = cfg.BitDepthLuma
+
+	for i, nal := range cfg.NALUnits(hevcNALVPS) {
+		vps, err := parseHEVCVPS(nal)
+		if err != nil {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("hvcC VPS %d: %v", i, err))
+			continue
+		}
+		if info.HEVCVPS == nil {
+			info.HEVCVPS = vps
+		}
+	}
+	for i, nal := range cfg.NALUnits(hevcNALSPS) {
+		sps, err := parseHEVCSPS(nal)
+		if err != nil {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("hvcC SPS %d: %v", i, err))
+			continue
+		}
+		if info.HEVCSPS == nil {
+			info.HEVCSPS = sps
+		}
+	}
+
+	sps := info.HEVCSPS
+	if sps == nil {
+		return
+	}
+	if sps.PTL.ProfileIdc != cfg.ProfileIdc || sps.PTL.LevelIdc != cfg.LevelIdc {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("hvcC profile/level %d/%d differ from SPS %d/%d",
+			cfg.ProfileIdc, cfg.LevelIdc, sps.PTL.ProfileIdc, sps.PTL.LevelIdc))
+	}
+	info.ChromaFormat = ChromaFormatName(sps.ChromaFormatIdc)
+	info.BitDepth = uint8(sps.BitDepthLuma)
+	setBitstreamSize(info, "SPS", sps.CodedWidth, sps.CodedHeight, sps.Width, sps.Height)
+
+	if vui := sps.VUI; vui != nil && (vui.ColourDescription || vui.VideoFullRange) {
+		info.Color = &ColorInfo{
+			Primaries: uint16(vui.ColourPrimaries),
+			Transfer:  uint16(vui.TransferCharacteristics),
+			Matrix:    uint16(vui.MatrixCoefficients),
+			FullRange: vui.VideoFullRange,
+			Source:    "HEVC VUI",
+		}
+	}
+}
+
+// hevcNALPayload strips the two byte NAL unit header after checking the type.
+func hevcNALPayload(nal []byte, nalType byte) ([]byte, error) {
+	if len(nal) < 3 || (nal[0]>>1)&0x3f != nalType {
+		return nil, fmt.Errorf("hevc: not a NAL unit of type %d", nalType)
+	}
+	return unescapeRBSP(nal[2:]), nil
+}
+
+// parseHEVCPTL decodes profile_tier_level(1, maxNumSubLayersMinus1) and
+// skips the sub-layer part.
+func parseHEVCPTL(br *bitReader, maxNumSubLayersMinus1 int) HEVCProfileTierLevel {
+	ptl := HEVCProfileTierLevel{
+		ProfileSpace:              uint8(br.readBits(2)),
+		TierFlag:                  br.readFlag(),
+		ProfileIdc:                uint8(br.readBits(5)),
+		ProfileCompatibilityFlags: uint32(br.readBits(32)),
+		ProgressiveSource:         br.readFlag(),
+		InterlacedSource:          br.readFlag(),
+		NonPackedConstraint:       br.readFlag(),
+		FrameOnlyConstraint:       br.readFlag(),
+	}
+	br.skipBits(44) // constraint flags up to general_inbld_flag/reserved bit
+	ptl.LevelIdc = uint8(br.readBits(8))
+
+	profilePresent := make([]bool, maxNumSubLayersMinus1)
+	levelPresent := make([]bool, maxNumSubLayersMinus1)
+	for i := 0; i < maxNumSubLayersMinus1; i++ {
+		profilePresent[i] = br.readFlag()
+		levelPresent[i] = br.readFlag()
+	}
+	if maxNumSubLayersMinus1 > 0 {
+		for i := maxNumSubLayersMinus1; i < 8; i++ {
+			br.skipBits(2) // reserved_zero_2bits
+		}
+	}
+	for i := 0; i < maxNumSubLayersMinus1; i++ {
+		if profilePresent[i] {
+			br.skipBits(88)
+		}
+		if levelPresent[i] {
+			br.skipBits(8)
+		}
+	}
+	return ptl
+}
+
+func parseHEVCVPS(nal []byte) (*HEVCVPS, error) {
+	rbsp, err := hevcNALPayload(nal, hevcNALVPS)
+	if err != nil {
+		return nil, err
+	}
+	br := newBitReader(rbsp)
+	v := &HEVCVPS{}
+	v.ID = uint8(br.readBits(4))
+	br.skipBits(2) // vps_base_layer_internal_flag, vps_base_layer_available_flag
+	v.MaxLayers = uint8(br.readBits(6)) + 1
+	v.MaxSubLayers = uint8(br.readBits(3)) + 1
+	v.TemporalIDNesting = br.readFlag()
+	br.skipBits(16) // vps_reserved_0xffff_16bits
+	v.PTL = parseHEVCPTL(br, int(v.MaxSubLayers)-1)
+
+	orderingInfoPresent := br.readFlag()
+	first := int(v.MaxSubLayers) - 1
+	if orderingInfoPresent {
+		first = 0
+	}
+	for i := first; i < int(v.MaxSubLayers) && br.err == nil; i++ {
+		br.readUE() // vps_max_dec_pic_buffering_minus1
+		br.readUE() // vps_max_num_reorder_pics
+		br.readUE() // vps_max_latency_increase_plus1
+	}
+	maxLayerID := int(br.readBits(6))
+	numLayerSets := int(br.readUE()) + 1
+	if numLayerSets > 1024 {
+		return nil, errors.New("hevc VPS: invalid vps_num_layer_sets_minus1")
+	}
+	br.skipBits((numLayerSets - 1) * (maxLayerID + 1)) // layer_id_included_flag
+	v.TimingInfoPresent = br.readFlag()
+	if v.TimingInfoPresent {
+		v.NumUnitsInTick = uint32(br.readBits(32))
+		v.TimeScale = uint32(br.readBits(32))
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("hevc VPS: %w", br.err)
+	}
+	return v, nil
+}
+
+func parseHEVCSPS(nal []byte) (*HEVCSPS, error) {
+	rbsp, err := hevcNALPayload(nal, hevcNALSPS)
+	if err != nil {
+		return nil, err
+	}
+	br := newBitReader(rbsp)
+	s := &HEVCSPS{}
+
+	s.VPSID = uint8(br.readBits(4))
+	s.MaxSubLayers = uint8(br.readBits(3)) + 1
+	s.TemporalIDNesting = br.readFlag()
+	s.PTL = parseHEVCPTL(br, int(s.MaxSubLayers)-1)
+	s.ID = br.readUE()
+	s.ChromaFormatIdc = br.readUE()
+	if s.ChromaFormatIdc == 3 {
+		s.SeparateColourPlane = br.readFlag()
+	}
+	s.CodedWidth = br.readUE()
+	s.CodedHeight = br.readUE()
+	s.ConformanceWindow = br.readFlag()
+	if s.ConformanceWindow {
+		s.ConfWinLeft = br.readUE()
+		s.ConfWinRight = br.readUE()
+		s.ConfWinTop = br.readUE()
+		s.ConfWinBottom = br.readUE()
+	}
+	s.BitDepthLuma = br.readUE() + 8
+	s.BitDepthChroma = br.readUE() + 8
+	s.Log2MaxPicOrderCntLsb = br.readUE() + 4
+
+	orderingInfoPresent := br.readFlag()
+	first := int(s.MaxSubLayers) - 1
+	if orderingInfoPresent {
+		first = 0
+	}
+	for i := first; i < int(s.MaxSubLayers) && br.err == nil; i++ {
+		s.MaxDecPicBuffering = br.readUE() + 1
+		s.MaxNumReorderPics = br.readUE()
+		s.MaxLatencyIncrease = br.readUE()
+	}
+
+	br.readUE()        // log2_min_luma_coding_block_size_minus3
+	br.readUE()        // log2_diff_max_min_luma_coding_block_size
+	br.readUE()        // log2_min_luma_transform_block_size_minus2
+	br.readUE()        // log2_diff_max_min_luma_transform_block_size
+	br.readUE()        // max_transform_hierarchy_depth_inter
+	br.readUE()        // max_transform_hierarchy_depth_intra
+	if br.readFlag() { // scaling_list_enabled_flag
+		if br.readFlag() { // sps_scaling_list_data_present_flag
+			skipHEVCScalingListData(br)
+		}
+	}
+	s.AMPEnabled = br.readFlag()
+	s.SAOEnabled = br.readFlag()
+	s.PCMEnabled = br.readFlag()
+	if s.PCMEnabled {
+		br.skipBits(8) // pcm_sample_bit_depth_luma/chroma_minus1
+		br.readUE()    // log2_min_pcm_luma_coding_block_size_minus3
+		br.readUE()    // log2_diff_max_min_pcm_luma_coding_block_size
+		br.skipBits(1) // pcm_loop_filter_disabled_flag
+	}
+	s.NumShortTermRefPicSets = br.readUE()
+	if s.NumShortTermRefPicSets > 64 {
+		return nil, errors.New("hevc SPS: invalid num_short_term_ref_pic_sets")
+	}
+	skipHEVCShortTermRefPicSets(br, int(s.NumShortTermRefPicSets))
+	s.LongTermRefPicsPresent = br.readFlag()
+	if s.LongTermRefPicsPresent {
+		n := br.readUE()
+		for i := uint32(0); i < n && br.err == nil; i++ {
+			br.skipBits(int(s.Log2MaxPicOrderCntLsb) + 1) // lt_ref_pic_poc_lsb_sps, used_by_curr_pic_lt_sps_flag
+		}
+	}
+	s.TemporalMVPEnabled = br.readFlag()
+	s.StrongIntraSmoothing = br.readFlag()
+	if br.readFlag() { // vui_parameters_present_flag
+		s.VUI = parseHEVCVUI(br)
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("hevc SPS: %w", br.err)
+	}
+
+	// conformance window offsets are in chroma sample units (7.4.3.2.1)
+	subWidthC, subHeightC := uint32(1), uint32(1)
+	if !s.SeparateColourPlane {
+		switch s.ChromaFormatIdc {
+		case 1:
+			subWidthC, subHeightC = 2, 2
+		case 2:
+			subWidthC = 2
+		}
+	}
+	cropX := (s.ConfWinLeft + s.ConfWinRight) * subWidthC
+	cropY := (s.ConfWinTop + s.ConfWinBottom) * subHeightC
+	if cropX >= s.CodedWidth || cropY >= s.CodedHeight {
+		return nil, fmt.Errorf("hevc SPS: conformance window %dx%d exceeds coded size %dx%d", cropX, cropY, s.CodedWidth, s.CodedHeight)
+	}
+	s.Width = s.CodedWidth - cropX
+	s.Height = s.CodedHeight - cropY
+
+	return s, nil
+}
+
+func skipHEVCScalingListData(br *bitReader) {
+	for sizeID := 0; sizeID < 4; sizeID++ {
+		step := 1
+		if sizeID == 3 {
+			step = 3
+		}
+		for matrixID := 0; matrixID < 6; matrixID += step {
+			if !br.readFlag() { // scaling_list_pred_mode_flag
+				br.readUE() // scaling_list_pred_matrix_id_delta
+				continue
+			}
+			coefNum := 64
+			if n := 1 << uint(4+sizeID<<1); n < coefNum {
+				coefNum = n
+			}
+			if sizeID > 1 {
+				br.readSE() // scaling_list_dc_coef_minus8
+			}
+			for i := 0; i < coefNum && br.err == nil; i++ {
+				br.readSE() // scaling_list_delta_coef
+			}
+		}
+	}
+}
+
+// skipHEVCShortTermRefPicSets skips the st_ref_pic_set(i) structures of an
+// SPS, tracking NumDeltaPocs as inter RPS prediction depends on it.
+func skipHEVCShortTermRefPicSets(br *bitReader, num int) {
+	numDeltaPocs := make([]int, num)
+	for idx := 0; idx < num && br.err == nil; idx++ {
+		interRPSPred := false
+		if idx != 0 {
+			interRPSPred = br.readFlag()
+		}
+		if interRPSPred {
+			br.skipBits(1) // delta_rps_sign
+			br.readUE()    // abs_delta_rps_minus1
+			ref := idx - 1 // delta_idx_minus1 is only present in slice headers
+			count := 0
+			for j := 0; j <= numDeltaPocs[ref] && br.err == nil; j++ {
+				used := br.readFlag()
+				useDelta := true
+				if !used {
+					useDelta = br.readFlag()
+				}
+				if used || useDelta {
+					count++
+				}
+			}
+			numDeltaPocs[idx] = count
+			continue
+		}
+		negative := int(br.readUE())
+		positive := int(br.readUE())
+		if negative > 16 || positive > 16 {
+			br.err = errors.New("hevc SPS: invalid short term ref pic set")
+			return
+		}
+		for i := 0; i < negative+positive && br.err == nil; i++ {
+			br.readUE()    // delta_poc_sX_minus1
+			br.skipBits(1) // used_by_curr_pic_sX_flag
+		}
+		numDeltaPocs[idx] = negative + positive
+	}
+}
+
+func parseHEVCVUI(br *bitReader) *HEVCVUI {
+	v := &HEVCVUI{}
+	if br.readFlag() { // aspect_ratio_info_present_flag
+		v.AspectRatioIdc = uint8(br.readBits(8))
+		if v.AspectRatioIdc == 255 {
+			v.SarWidth = uint16(br.readBits(16))
+			v.SarHeight = uint16(br.readBits(16))
+		}
+	}
+	if br.readFlag() { // overscan_info_present_flag
+		br.skipBits(1)
+	}
+	v.VideoFormat = 5
+	v.ColourPrimaries, v.TransferCharacteristics, v.MatrixCoefficients = 2, 2, 2
+	if br.readFlag() { // video_signal_type_present_flag
+		v.VideoFormat = uint8(br.readBits(3))
+		v.VideoFullRange = br.readFlag()
+		v.ColourDescription = br.readFlag()
+		if v.ColourDescription {
+			v.ColourPrimaries = uint8(br.readBits(8))
+			v.TransferCharacteristics = uint8(br.readBits(8))
+			v.MatrixCoefficients = uint8(br.readBits(8))
+		}
+	}
+	if br.readFlag() { // chroma_loc_info_present_flag
+		br.readUE()
+		br.readUE()
+	}
+	br.skipBits(1) // neutral_chroma_indication_flag
+	v.FieldSeq = br.readFlag()
+	v.FrameFieldInfoPresent = br.readFlag()
+	v.DefaultDisplayWindow = br.readFlag()
+	if v.DefaultDisplayWindow {
+		v.DefDispWinLeft = br.readUE()
+		v.DefDispWinRight = br.readUE()
+		v.DefDispWinTop = br.readUE()
+		v.DefDispWinBottom = br.readUE()
+	}
+	v.TimingInfoPresent = br.readFlag()
+	if v.TimingInfoPresent {
+		v.NumUnitsInTick = uint32(br.readBits(32))
+		v.TimeScale = uint32(br.readBits(32))
+	}
+	return v
+}
+
+// HEVCProfileName names general_profile_idc, falling back to the first
+// profile signalled in the compatibility flags when the idc is 0.
+func HEVCProfileName(idc byte, compatibility uint32) string {
+	if idc == 0 {
+		for j := byte(1); j < 32; j++ {
+			if compatibility&(1<<(31-j)) != 0 {
+				idc = j
+				break
+			}
+		}
+	}
+	switch idc {
+	case 1:
+		return "Main"
+	case 2:
+		return "Main 10"
+	case 3:
+		return "Main Still Picture"
+	case 4:
+		return "Format Range Extensions"
+	case 5:
+		return "High Throughput"
+	case 6:
+		return "Multiview Main"
+	case 7:
+		return "Scalable Main"
+	case 8:
+		return "3D Main"
+	case 9:
+		return "Screen Content Coding"
+	case 10:
+		return "Scalable Format Range Extensions"
+	case 11:
+		return "High Throughput Screen Content Coding"
+	default:
+		return fmt.Sprintf("Unknown (%d)", idc)
+	}
+}
+
+// HEVCLevelName formats general_level_idc (30 times the level number) with
+// the tier, e.g. 123 as "4.1 Main tier".
+func HEVCLevelName(idc byte, highTier bool) string {
+	tier := "Main"
+	if highTier {
+		tier = "High"
+	}
+	major, minor := idc/30, idc%30/3
+	if minor == 0 {
+		return fmt.Sprintf("%d %s tier", major, tier)
+	}
+	return fmt.Sprintf("%d.%d %s tier", major, minor, tier)
+}
@@ -0,0 +1,219 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// ID3Tag is a decoded ID3v2 tag, as carried by timed metadata emsg boxes.
+type ID3Tag struct {
+	Version  uint8 // major version: 2, 3 or 4
+	Revision uint8
+	Flags    uint8
+	Frames   []ID3Frame
+}
+
+// ID3Frame is one frame of an ID3v2 tag. Text is filled for text (T***),
+// URL (W***), comment and lyrics frames; Owner for PRIV and UFID frames;
+// Description for TXXX, WXXX, COMM and USLT frames. Data holds the raw
+// frame body, or the private data for PRIV/UFID.
+type ID3Frame struct {
+	ID          string
+	Flags       uint16
+	Text        string
+	Description string
+	Owner       string
+	Data        []byte
+}
+
+// parseID3 decodes an ID3v2.2/2.3/2.4 tag.
+func parseID3(buf []byte) (*ID3Tag, error) {
+	if len(buf) < 10 || string(buf[0:3]) != "ID3" {
+		return nil, errors.New("id3: missing ID3 header")
+	}
+	tag := &ID3Tag{Version: buf[3], Revision: buf[4], Flags: buf[5]}
+	if tag.Version < 2 || tag.Version > 4 {
+		return nil, fmt.Errorf("id3: unsupported version 2.%d", tag.Version)
+	}
+	size := int(syncsafe(buf[6:10]))
+	if 10+size > len(buf) {
+		return nil, fmt.Errorf("id3: tag size %d exceeds payload", size)
+	}
+	body := buf[10 : 10+size]
+
+	// tag level unsynchronisation (2.2/2.3; 2.4 signals it per frame)
+	if tag.Flags&0x80 != 0 && tag.Version < 4 {
+		body = unsynchronise(body)
+	}
+	// skip extended header
+	if tag.Flags&0x40 != 0 && tag.Version > 2 && len(body) >= 4 {
+		var extSize int
+		if tag.Version == 4 {
+			extSize = int(syncsafe(body[0:4]))
+		} else {
+			extSize = int(binary.BigEndian.Uint32(body[0:4])) + 4
+		}
+		if extSize > len(body) {
+			return nil, errors.New("id3: extended header exceeds tag")
+		}
+		body = body[extSize:]
+	}
+
+	idLen, headerLen := 4, 10
+	if tag.Version == 2 {
+		idLen, headerLen = 3, 6
+	}
+	for len(body) >= headerLen {
+		if body[0] == 0 {
+			// padding
+			break
+		}
+		f := ID3Frame{ID: string(body[:idLen])}
+		var frameSize int
+		switch tag.Version {
+		case 2:
+			frameSize = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+			f.Flags = uint16(body[8])<<8 | uint16(body[9])
+		case 4:
+			frameSize = int(syncsafe(body[4:8]))
+			f.Flags = uint16(body[8])<<8 | uint16(body[9])
+		}
+		if headerLen+frameSize > len(body) {
+			return nil, fmt.Errorf("id3: frame %s size %d exceeds tag", f.ID, frameSize)
+		}
+		data := body[headerLen : headerLen+frameSize]
+		body = body[headerLen+frameSize:]
+
+		if tag.Version == 4 {
+			if f.Flags&0x0002 != 0 {
+				data = unsynchronise(data)
+			}
+			// data length indicator
+			if f.Flags&0x0001 != 0 && len(data) >= 4 {
+				data = data[4:]
+			}
+		}
+		decodeID3Frame(&f, data)
+		tag.Frames = append(tag.Frames, f)
+	}
+
+	return tag, nil
+}
+
+func decodeID3Frame(f *ID3Frame, data []byte) {
+	f.Data = data
+	if len(f.ID) == 0 {
+		return
+	}
+	switch {
+	case f.ID == "TXXX" || f.ID == "TXX" || f.ID == "WXXX" || f.ID == "WXX":
+		if len(data) < 1 {
+			return
+		}
+		desc, rest := splitID3String(data[0], data[1:])
+		f.Description = desc
+		if f.ID[0] == 'W' {
+			f.Text = decodeID3String(0, rest)
+		} else {
+			f.Text = decodeID3String(data[0], rest)
+		}
+	case f.ID[0] == 'T':
+		if len(data) < 1 {
+			return
+		}
+		// 2.4 separates multiple values with a terminator
+		text := decodeID3String(data[0], data[1:])
+		f.Text = strings.Join(strings.FieldsFunc(text, func(r rune) bool { return r == 0 }), " / ")
+	case f.ID[0] == 'W':
+		f.Text = decodeID3String(0, data)
+	case f.ID == "COMM" || f.ID == "COM" || f.ID == "USLT" || f.ID == "ULT":
+		if len(data) < 4 {
+			return
+		}
+		desc, rest := splitID3String(data[0], data[4:])
+		f.Description = desc
+		f.Text = decodeID3String(data[0], rest)
+	case f.ID == "PRIV" || f.ID == "UFID" || f.ID == "UFI":
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			f.Owner = decodeID3String(0, data[:i])
+			f.Data = data[i+1:]
+		}
+	}
+}
+
+// splitID3String splits a terminated string in the given encoding off the
+// front of data.
+func splitID3String(enc byte, data []byte) (string, []byte) {
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				return decodeID3String(enc, data[:i]), data[i+2:]
+			}
+		}
+		return decodeID3String(enc, data), nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return decodeID3String(enc, data[:i]), data[i+1:]
+	}
+	return decodeID3String(enc, data), nil
+}
+
+// decodeID3String decodes text in one of the ID3 encodings:
+// 0 ISO-8859-1, 1 UTF-16 with BOM, 2 UTF-16BE, 3 UTF-8.
+func decodeID3String(enc byte, data []byte) string {
+	switch enc {
+	case 1, 2:
+		bigEndian := true
+		if enc == 1 && len(data) >= 2 {
+			if data[0] == 0xff && data[1] == 0xfe {
+				bigEndian = false
+				data = data[2:]
+			} else if data[0] == 0xfe && data[1] == 0xff {
+				data = data[2:]
+			}
+		}
+		return decodeUTF16(data, bigEndian)
+	case 3:
+		return strings.TrimRight(string(data), "\x00")
+	default:
+		runes := make([]rune, 0, len(data))
+		for _, b := range data {
+			runes = append(runes, rune(b))
+		}
+		return strings.TrimRight(string(runes), "\x00")
+	}
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7f)<<21 | uint32(b[1]&0x7f)<<14 | uint32(b[2]&0x7f)<<7 | uint32(b[3]&0x7f)
+}
+
+// unsynchronise reverses the ID3 unsynchronisation scheme (0xFF 0x00 -> 0xFF).
+func unsynchronise(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xff && i+1 < len(data) && data[i+1] == 0 {
+			i++
+		}
+	}
+	return out
+}
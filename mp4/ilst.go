@@ -0,0 +1,408 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Well-known data types of ilst data boxes (QuickTime File Format,
+// Metadata, Well-known types).
+const (
+	DataTypeImplicit    = 0
+	DataTypeUTF8        = 1
+	DataTypeUTF16       = 2
+	DataTypeJPEG        = 13
+	DataTypePNG         = 14
+	DataTypeSignedInt   = 21 // big endian, 1 to 8 bytes
+	DataTypeUnsignedInt = 22 // big endian, 1 to 8 bytes
+	DataTypeFloat32     = 23
+	DataTypeFloat64     = 24
+	DataTypeBMP         = 27
+	DataTypeInt8        = 65
+	DataTypeInt16       = 66
+	DataTypeInt32       = 67
+	DataTypeInt64       = 74
+	DataTypeUint8       = 75
+	DataTypeUint16      = 76
+	DataTypeUint32      = 77
+	DataTypeUint64      = 78
+)
+
+// MetadataItem is one data box of an ilst item.
+//
+//	aligned(8) class DataBox extends Box('data') {
+//		unsigned int(8) type_set;     // 0
+//		unsigned int(24) type;
+//		unsigned int(32) locale;
+//		unsigned int(8) value[];
+//	}
+type MetadataItem struct {
+	Key       string // e.g. "©nam", "trkn", "----:com.apple.iTunes:iTunSMPB" or "com.apple.quicktime.make"
+	Namespace string // key namespace of items named through a keys box, e.g. "mdta"
+	Type      uint32
+	Locale    uint32
+	Value     []byte
+}
+
+// Artwork is a cover image of a covr item.
+type Artwork struct {
+	Format string // "jpeg", "png" or "bmp"
+	Data   []byte
+}
+
+// Tags is the metadata of a meta box: iTunes style items of
+// moov/udta/meta/ilst and QuickTime mdta items named through a keys box, as
+// written by phones in moov/meta and trak/meta. Raw holds every item
+// rendered as a string, by key.
+type Tags struct {
+	Title       string // ©nam
+	Artist      string // ©ART
+	AlbumArtist string // aART
+	Album       string // ©alb
+	Genre       string // ©gen, or the ID3v1 genre of gnre
+	Composer    string // ©wrt
+	Comment     string // ©cmt
+	Encoder     string // ©too
+	EncodedBy   string // ©enc
+	Date        string // ©day
+	Grouping    string // ©grp
+	Lyrics      string // ©lyr
+	Description string // desc
+	Copyright   string // cprt
+	TrackNumber uint16 // trkn
+	TrackTotal  uint16
+	DiscNumber  uint16 // disk
+	DiscTotal   uint16
+	Compilation bool   // cpil
+	Tempo       uint16 // tmpo, beats per minute
+	Cover       []Artwork
+	Items       []MetadataItem
+	Raw         map[string]string
+}
+
+// metadataKey is an entry of a keys box.
+type metadataKey struct {
+	Namespace string
+	Value     string
+}
+
+// parseMetaBox adds the ilst items of a meta box to tags. QuickTime writes
+// meta as a plain box, ISO files as a FullBox, so the version and flags are
+// only skipped when present: a child box cannot start with a zero size.
+func parseMetaBox(buf []byte, tags *Tags) error {
+	if len(buf) >= 4 && binary.BigEndian.Uint32(buf) == 0 {
+		buf = buf[4:]
+	}
+	var keys []metadataKey
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			return fmt.Errorf("meta: %w", err)
+		}
+		switch boxType {
+		case "keys":
+			if keys, err = parseKeys(body); err != nil {
+				return err
+			}
+		case "ilst":
+			// keys precedes ilst in the files written by QuickTime
+			if err := parseIlst(body, keys, tags); err != nil {
+				return err
+			}
+		}
+		buf = rest
+	}
+	return nil
+}
+
+// parseKeys decodes a QuickTime metadata keys box.
+//
+//	FullBox header
+//	entry_count        4 bytes
+//	{ key_size         4 bytes
+//	  key_namespace    4 bytes, e.g. 'mdta'
+//	  key_value        key_size - 8 bytes }
+func parseKeys(buf []byte) ([]metadataKey, error) {
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("keys too short: %d bytes", len(buf))
+	}
+	count := int(binary.BigEndian.Uint32(buf[4:]))
+	buf = buf[8:]
+	if count > len(buf)/8 {
+		return nil, fmt.Errorf("keys: %d entries exceed box", count)
+	}
+	keys := make([]metadataKey, 0, count)
+	for i := 0; i < count; i++ {
+		if len(buf) < 8 {
+			return nil, fmt.Errorf("keys: entry %d exceeds box", i+1)
+		}
+		size := int(binary.BigEndian.Uint32(buf))
+		if size < 8 || size > len(buf) {
+			return nil, fmt.Errorf("keys: invalid key size %d", size)
+		}
+		keys = append(keys, metadataKey{Namespace: string(buf[4:8]), Value: string(buf[8:size])})
+		buf = buf[size:]
+	}
+	return keys, nil
+}
+
+// parseIlst decodes the items of an ilst box. With keys, item types are
+// 1-based indexes into the keys box; items with an index outside the keys
+// are skipped and reported in the returned error once the others are added.
+func parseIlst(buf []byte, keys []metadataKey, tags *Tags) error {
+	var badIndex error
+	for len(buf) >= 8 {
+		itemType, body, rest, err := nextBox(buf)
+		if err != nil {
+			return fmt.Errorf("ilst: %w", err)
+		}
+		key, namespace := latin1(itemType), ""
+		if keys != nil {
+			i := int(binary.BigEndian.Uint32([]byte(itemType)))
+			if i < 1 || i > len(keys) {
+				if badIndex == nil {
+					badIndex = fmt.Errorf("ilst: key index %d out of %d keys", i, len(keys))
+				}
+				buf = rest
+				continue
+			}
+			key, namespace = keys[i-1].Value, keys[i-1].Namespace
+		}
+		var mean, name string
+		for len(body) >= 8 {
+			childType, child, childRest, err := nextBox(body)
+			if err != nil {
+				return fmt.Errorf("ilst %s: %w", key, err)
+			}
+			switch childType {
+			case "mean":
+				if len(child) >= 4 {
+					mean = string(child[4:])
+				}
+			case "name":
+				if len(child) >= 4 {
+					name = string(child[4:])
+				}
+			case "data":
+				if len(child) < 8 {
+					return fmt.Errorf("ilst %s: data box too short", key)
+				}
+				item := MetadataItem{
+					Key:       key,
+					Namespace: namespace,
+					Type:      binary.BigEndian.Uint32(child) & 0xffffff,
+					Locale:    binary.BigEndian.Uint32(child[4:]),
+					Value:     child[8:],
+				}
+				if itemType == "----" {
+					item.Key = "----:" + mean + ":" + name
+				}
+				tags.add(item)
+			}
+			body = childRest
+		}
+		buf = rest
+	}
+	return badIndex
+}
+
+// add records an item and fills the typed field it maps to.
+func (t *Tags) add(item MetadataItem) {
+	t.Items = append(t.Items, item)
+	s := item.String()
+	if prev, ok := t.Raw[item.Key]; ok {
+		s = prev + "; " + s
+	}
+	t.Raw[item.Key] = s
+
+	text := map[string]*string{
+		"©nam": &t.Title, "©ART": &t.Artist, "aART": &t.AlbumArtist,
+		"©alb": &t.Album, "©gen": &t.Genre, "©wrt": &t.Composer,
+		"©cmt": &t.Comment, "©too": &t.Encoder, "©enc": &t.EncodedBy,
+		"©day": &t.Date, "©grp": &t.Grouping, "©lyr": &t.Lyrics,
+		"desc": &t.Description, "cprt": &t.Copyright, "©cpy": &t.Copyright,
+	}
+	if f, ok := text[item.Key]; ok {
+		*f = item.String()
+		return
+	}
+	v := item.Value
+	switch item.Key {
+	case "trkn", "disk":
+		if len(v) >= 6 {
+			number, total := binary.BigEndian.Uint16(v[2:]), binary.BigEndian.Uint16(v[4:])
+			if item.Key == "trkn" {
+				t.TrackNumber, t.TrackTotal = number, total
+			} else {
+				t.DiscNumber, t.DiscTotal = number, total
+			}
+		}
+	case "gnre":
+		if t.Genre == "" {
+			t.Genre = item.String()
+		}
+	case "cpil":
+		t.Compilation = len(v) > 0 && v[len(v)-1] != 0
+	case "tmpo":
+		if n, ok := item.Int(); ok {
+			t.Tempo = uint16(n)
+		}
+	case "covr":
+		if f := imageFormat(item.Type, v); f != "" {
+			t.Cover = append(t.Cover, Artwork{Format: f, Data: v})
+		}
+	}
+}
+
+// imageFormat names the format of a covr image, sniffing the data when the
+// type is implicit.
+func imageFormat(dataType uint32, data []byte) string {
+	switch {
+	case dataType == DataTypeJPEG:
+		return "jpeg"
+	case dataType == DataTypePNG:
+		return "png"
+	case dataType == DataTypeBMP:
+		return "bmp"
+	case len(data) >= 3 && data[0] == 0xff && data[1] == 0xd8 && data[2] == 0xff:
+		return "jpeg"
+	case len(data) >= 8 && string(data[:8]) == "\x89PNG\r\n\x1a\n":
+		return "png"
+	}
+	return ""
+}
+
+// Float returns the value of a float or integer item.
+func (m MetadataItem) Float() (float64, bool) {
+	v := m.Value
+	switch m.Type {
+	case DataTypeFloat32:
+		if len(v) == 4 {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(v))), true
+		}
+		return 0, false
+	case DataTypeFloat64:
+		if len(v) == 8 {
+			return math.Float64frombits(binary.BigEndian.Uint64(v)), true
+		}
+		return 0, false
+	case DataTypeUTF8:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+		return f, err == nil
+	}
+	n, ok := m.Int()
+	return float64(n), ok
+}
+
+// Int returns the value of an integer item. Implicit values, as written
+// for tmpo and cpil by older encoders, are read as unsigned integers.
+func (m MetadataItem) Int() (int64, bool) {
+	v := m.Value
+	switch m.Type {
+	case DataTypeSignedInt, DataTypeInt8, DataTypeInt16, DataTypeInt32, DataTypeInt64:
+		if len(v) == 0 || len(v) > 8 {
+			return 0, false
+		}
+		n := int64(int8(v[0]))
+		for _, b := range v[1:] {
+			n = n<<8 | int64(b)
+		}
+		return n, true
+	case DataTypeImplicit, DataTypeUnsignedInt, DataTypeUint8, DataTypeUint16, DataTypeUint32, DataTypeUint64:
+		if len(v) == 0 || len(v) > 8 {
+			return 0, false
+		}
+		var n uint64
+		for _, b := range v {
+			n = n<<8 | uint64(b)
+		}
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// String renders the value according to its data type.
+func (m MetadataItem) String() string {
+	v := m.Value
+	switch m.Type {
+	case DataTypeUTF8:
+		return string(v)
+	case DataTypeUTF16:
+		return decodeUTF16(v, true)
+	case DataTypeJPEG, DataTypePNG, DataTypeBMP:
+		return fmt.Sprintf("<%s image, %d bytes>", imageFormat(m.Type, v), len(v))
+	case DataTypeFloat32:
+		if len(v) == 4 {
+			return strconv.FormatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(v))), 'g', -1, 32)
+		}
+	case DataTypeFloat64:
+		if len(v) == 8 {
+			return strconv.FormatFloat(math.Float64frombits(binary.BigEndian.Uint64(v)), 'g', -1, 64)
+		}
+	case DataTypeImplicit:
+		switch m.Key {
+		case "trkn", "disk":
+			if len(v) >= 6 {
+				return fmt.Sprintf("%d/%d", binary.BigEndian.Uint16(v[2:]), binary.BigEndian.Uint16(v[4:]))
+			}
+		case "gnre":
+			if len(v) == 2 {
+				return id3v1Genre(int(binary.BigEndian.Uint16(v)) - 1)
+			}
+		case "covr":
+			if f := imageFormat(m.Type, v); f != "" {
+				return fmt.Sprintf("<%s image, %d bytes>", f, len(v))
+			}
+		case "tmpo", "cpil":
+			n, _ := m.Int()
+			return strconv.FormatInt(n, 10)
+		}
+		return fmt.Sprintf("%x", v)
+	}
+	if n, ok := m.Int(); ok {
+		if m.Type == DataTypeUnsignedInt || m.Type >= DataTypeUint8 {
+			return strconv.FormatUint(uint64(n), 10)
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	return fmt.Sprintf("%x", v)
+}
+
+// latin1 decodes an item type, whose © is the byte 0xa9.
+func latin1(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		b.WriteRune(rune(s[i]))
+	}
+	return b.String()
+}
+
+// ID3v1 genres with the Winamp extensions, as referenced by gnre.
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+	"Folk", "Folk-Rock", "National Folk", "Swing", "Fast Fusion", "Bebob", "Latin", "Revival",
+	"Celtic", "Bluegrass", "Avantgarde", "Gothic Rock", "Progressive Rock", "Psychedelic Rock", "Symphonic Rock", "Slow Rock",
+	"Big Band", "Chorus", "Easy Listening", "Acoustic", "Humour", "Speech", "Chanson", "Opera",
+	"Chamber Music", "Sonata", "Symphony", "Booty Bass", "Primus", "Porn Groove", "Satire", "Slow Jam",
+	"Club", "Tango", "Samba", "Folklore", "Ballad", "Power Ballad", "Rhythmic Soul", "Freestyle",
+	"Duet", "Punk Rock", "Drum Solo", "A capella", "Euro-House", "Dance Hall",
+}
+
+func id3v1Genre(i int) string {
+	if i < 0 || i >= len(id3v1Genres) {
+		return fmt.Sprintf("genre %d", i+1)
+	}
+	return id3v1Genres[i]
+}
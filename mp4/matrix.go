@@ -0,0 +1,150 @@
+package mp4
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Matrix is the transformation matrix of mvhd and tkhd, stored row by row
+// as { a, b, u, c, d, v, x, y, w }. All values are 16.16 fixed point
+// except u, v and w, which are 2.30. A point (p, q) is displayed at
+// (a*p + c*q + x, b*p + d*q + y).
+type Matrix [9]int32
+
+// identityMatrix is the unity matrix of ISO/IEC 14496-12 8.2.2.
+var identityMatrix = Matrix{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000}
+
+// Orientation classifies a Matrix. Rotation is clockwise and applied
+// before the flips.
+type Orientation struct {
+	Rotation  int // 0, 90, 180 or 270
+	FlipH     bool
+	FlipV     bool
+	Arbitrary bool // the matrix is not a multiple of 90° rotation or flip
+}
+
+func (o Orientation) String() string {
+	if o.Arbitrary {
+		return "arbitrary transform"
+	}
+	var parts []string
+	if o.Rotation != 0 || (!o.FlipH && !o.FlipV) {
+		parts = append(parts, fmt.Sprintf("rotate %d°", o.Rotation))
+	}
+	if o.FlipH {
+		parts = append(parts, "flip horizontal")
+	}
+	if o.FlipV {
+		parts = append(parts, "flip vertical")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func readMatrix(f *os.File) Matrix {
+	var m Matrix
+	for i := range m {
+		m[i] = int32(readU32(f))
+	}
+	return m
+}
+
+// IsIdentity reports whether m leaves the picture unchanged.
+func (m Matrix) IsIdentity() bool {
+	return m == identityMatrix
+}
+
+// float returns m as a 3x3 matrix of float64.
+func (m Matrix) float() [9]float64 {
+	var f [9]float64
+	for i, v := range m {
+		if i%3 == 2 {
+			f[i] = float64(v) / (1 << 30)
+		} else {
+			f[i] = float64(v) / (1 << 16)
+		}
+	}
+	return f
+}
+
+// Multiply returns the matrix that applies m first and then n, as the
+// track matrix is applied before the movie matrix.
+func (m Matrix) Multiply(n Matrix) Matrix {
+	a, b := m.float(), n.float()
+	var r Matrix
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			var v float64
+			for k := 0; k < 3; k++ {
+				v += a[3*i+k] * b[3*k+j]
+			}
+			if j == 2 {
+				r[3*i+j] = int32(math.Round(v * (1 << 30)))
+			} else {
+				r[3*i+j] = int32(math.Round(v * (1 << 16)))
+			}
+		}
+	}
+	return r
+}
+
+// Orientation classifies the 2x2 part of m, ignoring scaling and
+// translation.
+func (m Matrix) Orientation() Orientation {
+	sign := func(v int32) int {
+		switch {
+		case v > 0:
+			return 1
+		case v < 0:
+			return -1
+		default:
+			return 0
+		}
+	}
+	a, b, c, d := sign(m[0]), sign(m[1]), sign(m[3]), sign(m[4])
+	switch {
+	case b == 0 && c == 0 && a != 0 && d != 0:
+		switch {
+		case a > 0 && d > 0:
+			return Orientation{}
+		case a < 0 && d < 0:
+			return Orientation{Rotation: 180}
+		case a < 0:
+			return Orientation{FlipH: true}
+		default:
+			return Orientation{FlipV: true}
+		}
+	case a == 0 && d == 0 && b != 0 && c != 0:
+		switch {
+		case b > 0 && c < 0:
+			return Orientation{Rotation: 90}
+		case b < 0 && c > 0:
+			return Orientation{Rotation: 270}
+		case b > 0:
+			// transpose
+			return Orientation{Rotation: 90, FlipH: true}
+		default:
+			// transverse
+			return Orientation{Rotation: 270, FlipH: true}
+		}
+	}
+	return Orientation{Arbitrary: true}
+}
+
+// applyTrackTransform combines the track matrix with the movie matrix and
+// swaps the display size of tracks rotated by 90 or 270 degrees.
+func applyTrackTransform(info *TrackInfo, movie Matrix) {
+	info.DisplayMatrix = info.Matrix.Multiply(movie)
+	info.Orientation = info.DisplayMatrix.Orientation()
+	if info.Orientation.Arbitrary {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("unsupported transformation matrix %v", info.DisplayMatrix))
+		return
+	}
+	if info.Orientation.Rotation == 90 || info.Orientation.Rotation == 270 {
+		info.DisplayWidth, info.DisplayHeight = info.DisplayHeight, info.DisplayWidth
+		if info.DisplayAspect > 0 {
+			info.DisplayAspect = 1 / info.DisplayAspect
+		}
+	}
+}
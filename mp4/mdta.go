@@ -0,0 +1,193 @@
+package mp4
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuickTime metadata keys written by cameras and phones.
+const (
+	KeyLocationISO6709   = "com.apple.quicktime.location.ISO6709"
+	KeyMake              = "com.apple.quicktime.make"
+	KeyModel             = "com.apple.quicktime.model"
+	KeySoftware          = "com.apple.quicktime.software"
+	KeyCreationDate      = "com.apple.quicktime.creationdate"
+	KeyAndroidMake       = "com.android.manufacturer"
+	KeyAndroidModel      = "com.android.model"
+	KeyAndroidVersion    = "com.android.version"
+	KeyAndroidCaptureFPS = "com.android.capture.fps"
+)
+
+// Location is a point parsed from an ISO 6709 string.
+type Location struct {
+	Latitude    float64
+	Longitude   float64
+	Altitude    float64 // metres
+	HasAltitude bool
+}
+
+func (l Location) String() string {
+	s := fmt.Sprintf("%.6f, %.6f", l.Latitude, l.Longitude)
+	if l.HasAltitude {
+		s += fmt.Sprintf(", %.1f m", l.Altitude)
+	}
+	return s
+}
+
+func newTags() *Tags {
+	return &Tags{Raw: map[string]string{}}
+}
+
+// item returns the first item with one of the keys.
+func (t *Tags) item(keys ...string) (MetadataItem, bool) {
+	for _, k := range keys {
+		for _, item := range t.Items {
+			if item.Key == k {
+				return item, true
+			}
+		}
+	}
+	return MetadataItem{}, false
+}
+
+// text returns the string value of the first item with one of the keys.
+func (t *Tags) text(keys ...string) string {
+	if item, ok := t.item(keys...); ok {
+		return strings.TrimSpace(item.String())
+	}
+	return ""
+}
+
+// Device returns the make and model of the recording device, e.g.
+// "Apple iPhone 12 Pro".
+func (t *Tags) Device() string {
+	maker := t.text(KeyMake, KeyAndroidMake)
+	model := t.text(KeyModel, KeyAndroidModel)
+	if maker != "" && strings.HasPrefix(model, maker) {
+		return model
+	}
+	return strings.TrimSpace(maker + " " + model)
+}
+
+// Software returns the software or OS version of the recording device.
+func (t *Tags) Software() string {
+	if s := t.text(KeySoftware); s != "" {
+		return s
+	}
+	if v := t.text(KeyAndroidVersion); v != "" {
+		return "Android " + v
+	}
+	return ""
+}
+
+// CaptureTime returns the recording time from the creation date key,
+// falling back to ©day.
+func (t *Tags) CaptureTime() (time.Time, bool) {
+	s := t.text(KeyCreationDate, "©day")
+	if s == "" {
+		return time.Time{}, false
+	}
+	layouts := []string{
+		"2006-01-02T15:04:05-0700",
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if tm, err := time.Parse(layout, s); err == nil {
+			return tm, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Location returns the recording location from the ISO 6709 key, or the
+// ©xyz item written by Android and older QuickTime.
+func (t *Tags) Location() (Location, bool) {
+	s := t.text(KeyLocationISO6709, "©xyz")
+	if s == "" {
+		return Location{}, false
+	}
+	return parseISO6709(s)
+}
+
+// CaptureFPS returns the frame rate the video was captured at, which
+// differs from the playback rate of slow motion recordings.
+func (t *Tags) CaptureFPS() (float64, bool) {
+	item, ok := t.item(KeyAndroidCaptureFPS)
+	if !ok {
+		return 0, false
+	}
+	return item.Float()
+}
+
+// parseISO6709 parses a point such as "+37.3318-122.0312+012.345/". Each
+// coordinate is signed and in degrees, degrees and minutes or degrees,
+// minutes and seconds, telling by the digits before the decimal point.
+func parseISO6709(s string) (Location, bool) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "/")
+	if i := strings.Index(s, "CRS"); i >= 0 {
+		s = s[:i]
+	}
+	var parts []string
+	for len(s) > 0 {
+		if s[0] != '+' && s[0] != '-' {
+			return Location{}, false
+		}
+		end := strings.IndexAny(s[1:], "+-")
+		if end < 0 {
+			parts = append(parts, s)
+			break
+		}
+		parts = append(parts, s[:end+1])
+		s = s[end+1:]
+	}
+	if len(parts) < 2 {
+		return Location{}, false
+	}
+	lat, ok1 := parseISO6709Angle(parts[0], 2)
+	lon, ok2 := parseISO6709Angle(parts[1], 3)
+	if !ok1 || !ok2 {
+		return Location{}, false
+	}
+	loc := Location{Latitude: lat, Longitude: lon}
+	if len(parts) > 2 {
+		if alt, err := strconv.ParseFloat(parts[2], 64); err == nil {
+			loc.Altitude, loc.HasAltitude = alt, true
+		}
+	}
+	return loc, true
+}
+
+// parseISO6709Angle parses a signed angle whose degrees have degDigits
+// digits.
+func parseISO6709Angle(s string, degDigits int) (float64, bool) {
+	sign := 1.0
+	if s[0] == '-' {
+		sign = -1
+	}
+	s = s[1:]
+	intDigits := strings.IndexByte(s, '.')
+	if intDigits < 0 {
+		intDigits = len(s)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch intDigits - degDigits {
+	case 0: // degrees
+	case 2: // degrees and minutes
+		deg := float64(int(v / 100))
+		v = deg + (v-deg*100)/60
+	case 4: // degrees, minutes and seconds
+		deg := float64(int(v / 10000))
+		mins := float64(int(v/100)) - deg*100
+		v = deg + mins/60 + (v-deg*10000-mins*100)/3600
+	default:
+		return 0, false
+	}
+	return sign * v, true
+}
@@ -0,0 +1,66 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MPEGHConfig is a decoded mhaC box (ISO/IEC 23008-3 20.5.2).
+//
+//	class MHADecoderConfigurationRecord() {
+//		unsigned int(8) configurationVersion;
+//		unsigned int(8) mpegh3daProfileLevelIndication;
+//		unsigned int(8) referenceChannelLayout;
+//		unsigned int(16) mpegh3daConfigLength;
+//		bit(8*mpegh3daConfigLength) mpegh3daConfig();
+//	}
+type MPEGHConfig struct {
+	ConfigurationVersion   byte
+	ProfileLevelIndication byte
+	ReferenceChannelLayout byte // CICP ChannelConfiguration
+	Config                 []byte
+}
+
+func parseMPEGHConfig(buf []byte) (*MPEGHConfig, error) {
+	if len(buf) < 5 {
+		return nil, fmt.Errorf("mhaC too short: %d bytes", len(buf))
+	}
+	c := &MPEGHConfig{
+		ConfigurationVersion:   buf[0],
+		ProfileLevelIndication: buf[1],
+		ReferenceChannelLayout: buf[2],
+	}
+	n := int(binary.BigEndian.Uint16(buf[3:]))
+	if 5+n > len(buf) {
+		return nil, fmt.Errorf("mhaC: config length %d exceeds box", n)
+	}
+	c.Config = buf[5 : 5+n]
+	return c, nil
+}
+
+func applyMPEGHConfig(info *TrackInfo, cfg *MPEGHConfig) {
+	info.MPEGH = cfg
+	info.CodecName = "MPEG-H 3D Audio"
+	info.Profile, info.Level = MPEGHProfileLevel(cfg.ProfileLevelIndication)
+	applyCICPLayout(info, cfg.ReferenceChannelLayout)
+}
+
+// MPEGHProfileLevel splits mpegh3daProfileLevelIndication into a profile
+// name and level (ISO/IEC 23008-3 Table 67).
+func MPEGHProfileLevel(pli byte) (string, string) {
+	var profile string
+	var first byte
+	switch {
+	case pli >= 0x01 && pli <= 0x05:
+		profile, first = "Main", 0x01
+	case pli >= 0x06 && pli <= 0x0a:
+		profile, first = "High", 0x06
+	case pli >= 0x0b && pli <= 0x0f:
+		profile, first = "Low Complexity", 0x0b
+	case pli >= 0x10 && pli <= 0x14:
+		profile, first = "Baseline", 0x10
+	default:
+		return fmt.Sprintf("Unknown (0x%02x)", pli), ""
+	}
+	return profile, fmt.Sprintf("%d", pli-first+1)
+}
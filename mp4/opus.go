@@ -0,0 +1,70 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// OpusConfig is a decoded dOps box (Encapsulation of Opus in ISO Base Media
+// File Format 4.3.2).
+//
+//	aligned(8) class OpusSpecificBox extends Box('dOps') {
+//		unsigned int(8) Version;
+//		unsigned int(8) OutputChannelCount;
+//		unsigned int(16) PreSkip;
+//		unsigned int(32) InputSampleRate;
+//		signed int(16) OutputGain;
+//		unsigned int(8) ChannelMappingFamily;
+//		if (ChannelMappingFamily != 0) {
+//			unsigned int(8) StreamCount;
+//			unsigned int(8) CoupledCount;
+//			unsigned int(8 * OutputChannelCount) ChannelMapping;
+//		}
+//	}
+type OpusConfig struct {
+	Version              byte
+	OutputChannelCount   byte
+	PreSkip              uint16 // samples at 48 kHz to discard at the start
+	InputSampleRate      uint32 // informational, Opus always decodes at 48 kHz
+	OutputGain           int16  // Q7.8 dB
+	ChannelMappingFamily byte
+	StreamCount          byte
+	CoupledCount         byte
+	ChannelMapping       []byte
+}
+
+// OutputGainDB returns the output gain in dB.
+func (c *OpusConfig) OutputGainDB() float64 {
+	return float64(c.OutputGain) / 256
+}
+
+func parseOpusConfig(buf []byte) (*OpusConfig, error) {
+	if len(buf) < 11 {
+		return nil, fmt.Errorf("dOps too short: %d bytes", len(buf))
+	}
+	c := &OpusConfig{
+		Version:              buf[0],
+		OutputChannelCount:   buf[1],
+		PreSkip:              binary.BigEndian.Uint16(buf[2:]),
+		InputSampleRate:      binary.BigEndian.Uint32(buf[4:]),
+		OutputGain:           int16(binary.BigEndian.Uint16(buf[8:])),
+		ChannelMappingFamily: buf[10],
+	}
+	if c.ChannelMappingFamily != 0 {
+		n := int(c.OutputChannelCount)
+		if len(buf) < 13+n {
+			return nil, fmt.Errorf("dOps: channel mapping table truncated")
+		}
+		c.StreamCount = buf[11]
+		c.CoupledCount = buf[12]
+		c.ChannelMapping = buf[13 : 13+n]
+	}
+	return c, nil
+}
+
+func applyOpusConfig(info *TrackInfo, cfg *OpusConfig) {
+	info.Opus = cfg
+	info.CodecName = "Opus"
+	info.Channels = uint16(cfg.OutputChannelCount)
+	info.SampleRate = 48000
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"time"
 )
@@ -13,6 +14,19 @@ type MP4Parser struct {
 	file     *os.File
 	metadata MP4Metadata
 	tracks   []TrackInfo
+	events   []EventMessage
+	mvhd     MVHDBox
+	chapters []Chapter
+	tags     *Tags
+	warnings []string // problems outside the tracks that did not stop parsing
+
+	// fragmented files
+	trex        map[uint32]*TrackExtends
+	fragments   []Fragment
+	prfts       []ProducerReferenceTime
+	pendingPRFT *ProducerReferenceTime // prft waiting for its moof
+	segment     int                    // number of styp boxes seen so far
+	hasStyp     bool
 }
 
 // Create new MP4 parser
@@ -26,34 +40,157 @@ func NewParser(filename string) (*MP4Parser, error) {
 		file:     file,
 		metadata: MP4Metadata{},
 		tracks:   []TrackInfo{},
+		mvhd:     MVHDBox{Matrix: identityMatrix},
+		trex:     map[uint32]*TrackExtends{},
 	}, nil
 }
 
 // Parser MP4 file
+//
+// All top level boxes are visited so that boxes following moov, such as the
+// emsg, prft and moof boxes of fragmented segments, are picked up as well.
 func (p *MP4Parser) Parse() (*MP4Metadata, error) {
 	defer p.file.Close()
 
+	foundMoov := false
 	for {
+		boxStart := cur(p.file)
 		size, boxType, err := readHeader(p.file)
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
 			return nil, err
 		}
 
-		fmt.Printf("DEBUG: parsing atom: %s, size: %d\n", boxType, size)
-
 		switch boxType {
 		case "moov":
 			if err := p.parseMoovAtom(size - 8); err != nil {
 				return nil, err
 			}
-			if err := p.calculateMetadata(); err != nil {
+			foundMoov = true
+		case BoxTypeEMSG, BoxTypePRFT, BoxTypeMOOF:
+			dataSize, err := boxPayloadSize(p.file, size)
+			if err != nil {
 				return nil, err
 			}
-
-			return &p.metadata, nil
+			switch boxType {
+			case BoxTypeEMSG:
+				err = p.parseEmsgAtom(boxStart, dataSize)
+			case BoxTypePRFT:
+				err = p.parsePrftAtom(boxStart, dataSize)
+			default:
+				err = p.parseMoofAtom(boxStart, dataSize)
+			}
+			if err != nil {
+				return nil, err
+			}
+		case BoxTypeSTYP:
+			if p.hasStyp {
+				p.segment++
+			}
+			p.hasStyp = true
+			if err := skipBox(p.file, size); err != nil {
+				return nil, err
+			}
+		case BoxTypeMDAT:
+			if err := skipBox(p.file, size); err != nil {
+				return nil, err
+			}
+			if n := len(p.fragments); n > 0 && p.fragments[n-1].MdatOffset == 0 {
+				p.fragments[n-1].MdatOffset = boxStart
+				p.fragments[n-1].MdatSize = uint64(cur(p.file) - boxStart)
+			}
 		default:
-			p.file.Seek(int64(size-8), io.SeekCurrent)
+			if err := skipBox(p.file, size); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !foundMoov && len(p.events) == 0 && len(p.fragments) == 0 {
+		return nil, fmt.Errorf("moov atom not found")
+	}
+	if foundMoov {
+		p.decodeCues()
+		p.decodeTimecodes()
+		p.resolveChapters()
+		if err := p.calculateMetadata(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p.metadata, nil
+}
+
+// skipBox skips the payload of a top level box whose 8-byte header has just
+// been read, handling 64-bit largesize and the size 0 "to end of file" form.
+func skipBox(f *os.File, size uint32) error {
+	dataSize, err := boxPayloadSize(f, size)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(dataSize, io.SeekCurrent)
+	return err
+}
+
+// readPayload reads a box payload of dataSize bytes. The buffer grows with
+// the data actually read, so a corrupt size cannot force a huge allocation.
+func readPayload(f *os.File, dataSize int64) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(f, dataSize))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) < dataSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf, nil
+}
+
+// readChildPayload reads the payload of a child box whose header has just
+// been read, after checking that the box lies within its parent, which
+// ends at end.
+func readChildPayload(f *os.File, boxType string, size uint32, end int64) ([]byte, error) {
+	if err := checkChildSize(f, boxType, size, end); err != nil {
+		return nil, err
+	}
+	return readPayload(f, int64(size-8))
+}
+
+// checkChildSize checks that a child box whose header has just been read
+// lies within its parent, which ends at end.
+func checkChildSize(f *os.File, boxType string, size uint32, end int64) error {
+	if size < 8 || int64(size-8) > end-cur(f) {
+		return fmt.Errorf("%s: invalid box size %d", boxType, size)
+	}
+	return nil
+}
+
+// boxPayloadSize returns the payload size of a top level box whose 8-byte
+// header has just been read. For size 1 it reads the 64-bit largesize, for
+// size 0 the box runs to the end of the file.
+func boxPayloadSize(f *os.File, size uint32) (int64, error) {
+	switch size {
+	case 0:
+		fi, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size() - cur(f), nil
+	case 1:
+		var largeSize uint64
+		if err := binary.Read(f, binary.BigEndian, &largeSize); err != nil {
+			return 0, err
+		}
+		if largeSize < 16 || largeSize > math.MaxInt64 {
+			return 0, fmt.Errorf("invalid largesize %d", largeSize)
 		}
+		return int64(largeSize - 16), nil
+	default:
+		if size < 8 {
+			return 0, fmt.Errorf("invalid box size %d", size)
+		}
+		return int64(size - 8), nil
 	}
 }
 
@@ -66,10 +203,6 @@ func readHeader(f *os.File) (uint32, string, error) {
 	if n != 8 {
 		return 0, "", fmt.Errorf("read atom header failed")
 	}
-	for i := 0; i < 8; i++ {
-		fmt.Printf("%02x ", buf[i])
-	}
-	fmt.Printf("\n")
 	size := binary.BigEndian.Uint32(buf[0:4])
 	boxType := string(buf[4:8])
 	return size, boxType, nil
@@ -86,10 +219,8 @@ func (p *MP4Parser) parseMoovAtom(dataSize uint32) error {
 	for cur(p.file) < end {
 		atomSize, atomType, err := readHeader(p.file)
 		if err != nil {
-			fmt.Printf("error reading atom header: %v", err)
 			return err
 		}
-		fmt.Printf("parsing atom type: %s, size: %d\n", atomType, atomSize)
 		switch atomType {
 		case BoxTypeMVHD:
 			if err := p.parseMvhdAtom(); err != nil {
@@ -99,17 +230,110 @@ func (p *MP4Parser) parseMoovAtom(dataSize uint32) error {
 			if err := p.parseTrakAtom(atomSize - 8); err != nil {
 				return err
 			}
+		case BoxTypeMVEX:
+			if err := p.parseMvexAtom(atomSize - 8); err != nil {
+				return err
+			}
+		case BoxTypeUDTA:
+			buf, err := readChildPayload(p.file, atomType, atomSize, end)
+			if err != nil {
+				return err
+			}
+			p.parseUdtaAtom(buf)
+		case "meta":
+			buf, err := readChildPayload(p.file, atomType, atomSize, end)
+			if err != nil {
+				return err
+			}
+			if err := p.parseMetaAtom(buf); err != nil {
+				p.warnings = append(p.warnings, err.Error())
+			}
 		default:
 			p.file.Seek(int64(atomSize-8), io.SeekCurrent)
 		}
 	}
 	return nil
 }
+
+// parse moov level udta atom. User data is optional, so boxes that fail to
+// decode only produce a warning.
+func (p *MP4Parser) parseUdtaAtom(buf []byte) {
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			p.warnings = append(p.warnings, fmt.Sprintf("udta: %v", err))
+			return
+		}
+		switch boxType {
+		case "chpl":
+			// a truncated list keeps the chapters before the damage
+			chapters, err := parseChpl(body)
+			if err != nil {
+				p.warnings = append(p.warnings, err.Error())
+			}
+			p.chapters = chapters
+		case "meta":
+			if err := p.parseMetaAtom(body); err != nil {
+				p.warnings = append(p.warnings, err.Error())
+			}
+		case "\xa9xyz":
+			// QuickTime user data text: 16 bit length, 16 bit language
+			if len(body) >= 4 {
+				if p.tags == nil {
+					p.tags = newTags()
+				}
+				p.tags.add(MetadataItem{Key: "©xyz", Type: DataTypeUTF8, Value: body[4:]})
+			}
+		}
+		buf = rest
+	}
+}
+
+// parse moov level meta atom, adding its items to the file tags
+func (p *MP4Parser) parseMetaAtom(buf []byte) error {
+	if p.tags == nil {
+		p.tags = newTags()
+	}
+	return parseMetaBox(buf, p.tags)
+}
+
+// parse trak level meta and udta/meta atoms into the track tags. Boxes that
+// fail to decode only produce a track warning.
+func parseTrackMeta(boxType string, buf []byte, info *TrackInfo) {
+	if info.Tags == nil {
+		info.Tags = newTags()
+	}
+	if boxType == "meta" {
+		if err := parseMetaBox(buf, info.Tags); err != nil {
+			info.Warnings = append(info.Warnings, err.Error())
+		}
+		return
+	}
+	for len(buf) >= 8 {
+		childType, body, rest, err := nextBox(buf)
+		if err != nil {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("udta: %v", err))
+			return
+		}
+		if childType == "meta" {
+			if err := parseMetaBox(body, info.Tags); err != nil {
+				info.Warnings = append(info.Warnings, err.Error())
+			}
+		}
+		buf = rest
+	}
+}
+
 func readByte(f *os.File) byte {
 	buf := []byte{0}
 	f.Read(buf)
 	return buf[0]
 }
+func readU16(f *os.File) uint16 {
+	buf := make([]byte, 2)
+	f.Read(buf)
+	return binary.BigEndian.Uint16(buf)
+}
 func readU32(f *os.File) uint32 {
 	buf := make([]byte, 4)
 	f.Read(buf)
@@ -147,56 +371,54 @@ func readU64(f *os.File) uint64 {
 //		unsigned int(32) next_track_ID;
 //		}
 func (p *MP4Parser) parseMvhdAtom() error {
+	mvhd := &p.mvhd
 	// read version
 	version := readByte(p.file)
 	// skip flags
 	p.file.Seek(3, io.SeekCurrent)
 
-	var creationTime, modificationTime, duration uint64
-	var timescale uint32
 	if version == 1 {
-		creationTime = readU64(p.file)
-		modificationTime = readU64(p.file)
-		timescale = readU32(p.file)
-		duration = readU64(p.file)
+		mvhd.CreationTime = readU64(p.file)
+		mvhd.ModificationTime = readU64(p.file)
+		mvhd.Timescale = readU32(p.file)
+		mvhd.Duration = readU64(p.file)
 	} else {
-		creationTime = uint64(readU32(p.file))
-		modificationTime = uint64(readU32(p.file))
-		timescale = readU32(p.file)
-		duration = uint64(readU32(p.file))
+		mvhd.CreationTime = uint64(readU32(p.file))
+		mvhd.ModificationTime = uint64(readU32(p.file))
+		mvhd.Timescale = readU32(p.file)
+		mvhd.Duration = uint64(readU32(p.file))
 	}
-	fmt.Printf("DEBUG: mvhd, creationTime: %d, modificationTime: %d, timescale: %d, duration: %d\n",
-		creationTime, modificationTime, timescale, duration)
 
 	// set timescale
-	if timescale > 0 {
-		durationSeconds := float64(duration) / float64(timescale)
+	if mvhd.Timescale > 0 {
+		durationSeconds := float64(mvhd.Duration) / float64(mvhd.Timescale)
 		p.metadata.Duration = time.Duration(durationSeconds * float64(time.Second))
 	}
 
-	// 1904-01-01 00:00:00 +0000 UTC
-	macEpoch := time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
-	p.metadata.CreationTime = macEpoch.Add(time.Duration(creationTime) * time.Second)
-	p.metadata.ModificationTime = macEpoch.Add(time.Duration(modificationTime) * time.Second)
+	p.metadata.CreationTime = mp4Time(mvhd.CreationTime)
+	p.metadata.ModificationTime = mp4Time(mvhd.ModificationTime)
 
-	// 4 + 2 + 2 + 8 + 36 + 24 + 4
-	// skip rate, volume, reserved, matrix, pre_defined, next_track_ID
-	p.file.Seek(80, io.SeekCurrent)
+	mvhd.Rate = readU32(p.file)
+	mvhd.Volume = readU16(p.file)
+	// skip reserved
+	p.file.Seek(10, io.SeekCurrent)
+	mvhd.Matrix = readMatrix(p.file)
+	// skip pre_defined
+	p.file.Seek(24, io.SeekCurrent)
+	mvhd.NextTrackID = readU32(p.file)
 
 	return nil
 }
 
 // parse trak atom
 func (p *MP4Parser) parseTrakAtom(size uint32) error {
-	fmt.Printf("DEBUG: parsing trak atom\n")
 	end := cur(p.file) + int64(size)
 
-	trackInfo := TrackInfo{}
+	trackInfo := TrackInfo{Matrix: identityMatrix}
 
 	for cur(p.file) < end {
 		atomSize, atomType, err := readHeader(p.file)
 		if err != nil {
-			fmt.Printf("error reading atom header: %v", err)
 			return err
 		}
 
@@ -209,11 +431,40 @@ func (p *MP4Parser) parseTrakAtom(size uint32) error {
 			if err := p.parseMdiaAtom(atomSize-8, &trackInfo); err != nil {
 				return err
 			}
+		case "tref":
+			buf, err := readChildPayload(p.file, atomType, atomSize, end)
+			if err != nil {
+				return err
+			}
+			// references are optional, a broken tref leaves them empty
+			refs, err := parseTrackReferences(buf)
+			if err != nil {
+				trackInfo.Warnings = append(trackInfo.Warnings, boxWarning(atomType, err))
+			} else {
+				trackInfo.References = refs
+			}
+		case "edts":
+			buf, err := readChildPayload(p.file, atomType, atomSize, end)
+			if err != nil {
+				return err
+			}
+			edits, err := parseEdts(buf)
+			if err != nil {
+				return err
+			}
+			trackInfo.EditList = edits
+		case "meta", BoxTypeUDTA:
+			buf, err := readChildPayload(p.file, atomType, atomSize, end)
+			if err != nil {
+				return err
+			}
+			parseTrackMeta(atomType, buf, &trackInfo)
 		default:
 			p.file.Seek(int64(atomSize-8), io.SeekCurrent)
 		}
 	}
 
+	applyTrackTransform(&trackInfo, p.mvhd.Matrix)
 	p.tracks = append(p.tracks, trackInfo)
 
 	return nil
@@ -245,61 +496,57 @@ func (p *MP4Parser) parseTrakAtom(size uint32) error {
 //	    height                  32-bit fixed-point 16.16
 //	}
 func (p *MP4Parser) parseTkhdAtom(track *TrackInfo) error {
-	fmt.Printf("DEBUG: parsing tkhd atom\n")
-
+	tkhd := &track.Header
 	version := readByte(p.file)
-	// skip flags
-	p.file.Seek(3, io.SeekCurrent)
+	var flags [3]byte
+	p.file.Read(flags[:])
+	tkhd.Flags = uint32(flags[0])<<16 | uint32(flags[1])<<8 | uint32(flags[2])
 
-	var trackID uint32
-	var duration uint64
 	if version == 1 {
-		// skip creationTime, modificationTime
-		p.file.Seek(16, io.SeekCurrent)
-		trackID = readU32(p.file)
+		tkhd.CreationTime = readU64(p.file)
+		tkhd.ModificationTime = readU64(p.file)
+		tkhd.TrackID = readU32(p.file)
 		// skip reserved
 		p.file.Seek(4, io.SeekCurrent)
-		duration = readU64(p.file)
+		tkhd.Duration = readU64(p.file)
 	} else {
-		// skip creationTime, modificationTime
-		p.file.Seek(8, io.SeekCurrent)
-		trackID = readU32(p.file)
+		tkhd.CreationTime = uint64(readU32(p.file))
+		tkhd.ModificationTime = uint64(readU32(p.file))
+		tkhd.TrackID = readU32(p.file)
 		// skip reserved
 		p.file.Seek(4, io.SeekCurrent)
-		duration = uint64(readU32(p.file))
+		tkhd.Duration = uint64(readU32(p.file))
 	}
-	// skip reserved, layer, alternate_group, volume, reserved
-	p.file.Seek(16, io.SeekCurrent)
-	// matrix (36 byte)
-	p.file.Seek(36, io.SeekCurrent)
+	// skip reserved
+	p.file.Seek(8, io.SeekCurrent)
+	tkhd.Layer = int16(readU16(p.file))
+	tkhd.AlternateGroup = int16(readU16(p.file))
+	tkhd.Volume = int16(readU16(p.file))
+	// skip reserved
+	p.file.Seek(2, io.SeekCurrent)
+	track.Matrix = readMatrix(p.file)
 
-	width := readU32(p.file) >> 16
-	height := readU32(p.file) >> 16
+	tkhd.Width = readU32(p.file) >> 16
+	tkhd.Height = readU32(p.file) >> 16
 
-	track.TrackID = trackID
-	track.Width = width
-	track.Height = height
-	track.Duration = duration
-	fmt.Printf("DEBUG tkhd, trackID: %d, duration: %d, width: %d, height: %d\n",
-		track.TrackID, track.Duration, track.Width, track.Height)
+	track.TrackID = tkhd.TrackID
+	track.Width = tkhd.Width
+	track.Height = tkhd.Height
+	track.Duration = tkhd.Duration
 
 	return nil
 }
 
 // skip mdia atom
 func (p *MP4Parser) parseMdiaAtom(size uint32, track *TrackInfo) error {
-	fmt.Printf("parsing mdia atom\n")
 	end := cur(p.file) + int64(size)
 
 	for cur(p.file) < end {
 		atomSize, atomType, err := readHeader(p.file)
 		if err != nil {
-			fmt.Printf("error reading atom header: %v", err)
 			return err
 		}
 
-		fmt.Printf("DEBUG: parsing %s atom, size: %d\n", atomType, atomSize)
-
 		switch atomType {
 		case "mdhd":
 			if err := p.parseMdhdAtom(track); err != nil {
@@ -360,6 +607,40 @@ func readBytes(f *os.File, n int) []byte {
 	return buf
 }
 
+// readCString reads a null-terminated UTF-8 string.
+func readCString(r io.ByteReader) (string, error) {
+	var buf []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == 0 {
+			return string(buf), nil
+		}
+		buf = append(buf, b)
+	}
+}
+
+// parse emsg atom
+func (p *MP4Parser) parseEmsgAtom(offset int64, dataSize int64) error {
+	buf, err := readPayload(p.file, dataSize)
+	if err != nil {
+		return fmt.Errorf("read emsg: %w", err)
+	}
+	// an event box the parser cannot decode, e.g. of a later version, is
+	// skipped so the rest of the stream is still parsed
+	event, err := parseEmsg(buf)
+	if err != nil {
+		p.warnings = append(p.warnings, fmt.Sprintf("emsg at %d: %v", offset, err))
+		return nil
+	}
+	event.Offset = offset
+	p.events = append(p.events, *event)
+
+	return nil
+}
+
 // parse hdlr atom
 func (p *MP4Parser) parseHdlrAtom(track *TrackInfo, dataSize uint32) error {
 	// skip version and flags
@@ -395,13 +676,12 @@ func (p *MP4Parser) parseMinfAtom(size uint32, track *TrackInfo) error {
 	for cur(p.file) < end {
 		atomSize, atomType, err := readHeader(p.file)
 		if err != nil {
-			fmt.Printf("error reading atom header: %v", err)
 			return err
 		}
 
 		switch atomType {
 		case "stbl":
-			if err := p.parseStblAtom(size, track); err != nil {
+			if err := p.parseStblAtom(atomSize-8, track); err != nil {
 				return err
 			}
 		default:
@@ -419,21 +699,35 @@ func (p *MP4Parser) parseStblAtom(size uint32, track *TrackInfo) error {
 	for cur(p.file) < end {
 		atomSize, atomType, err := readHeader(p.file)
 		if err != nil {
-			fmt.Printf("error reading atom header: %v\n", err)
 			return err
 		}
-		fmt.Printf("DEBUG: parsing %s atom, size: %d\n", atomType, atomSize)
 		switch atomType {
 		case "stsd":
 			if err := parseStsd(p.file, atomSize, track); err != nil {
 				return err
 			}
 		case "stts":
-			if err := parseStts(p.file, track); err != nil {
+			if err := parseStts(p.file, int64(atomSize)-8, track); err != nil {
 				return err
 			}
 		case "ctts":
-			if err := parseCtts(p.file, track); err != nil {
+			if err := parseCtts(p.file, int64(atomSize)-8, track); err != nil {
+				return err
+			}
+		case "stsz":
+			if err := parseStsz(p.file, int64(atomSize)-8, track); err != nil {
+				return err
+			}
+		case "stz2":
+			if err := parseStz2(p.file, int64(atomSize)-8, track); err != nil {
+				return err
+			}
+		case "stsc":
+			if err := parseStsc(p.file, int64(atomSize)-8, track); err != nil {
+				return err
+			}
+		case "stco", "co64":
+			if err := parseStco(p.file, int64(atomSize)-8, track, atomType == "co64"); err != nil {
 				return err
 			}
 		default:
@@ -446,6 +740,9 @@ func (p *MP4Parser) parseStblAtom(size uint32, track *TrackInfo) error {
 
 // calculate metadata
 func (p *MP4Parser) calculateMetadata() error {
+	p.addFragmentTotals()
+	checkTrackReferences(p.tracks)
+
 	for _, track := range p.tracks {
 		switch track.HandlerType {
 		case "vide":
@@ -453,6 +750,10 @@ func (p *MP4Parser) calculateMetadata() error {
 			p.metadata.Width = track.Width
 			p.metadata.Height = track.Height
 			p.metadata.VideoCodec = track.Codec
+			p.metadata.VideoProfile = track.Profile
+			p.metadata.VideoLevel = track.AVCLevel
+			p.metadata.VideoHDRFormat = track.HDRFormat
+			p.metadata.Rotation = track.Orientation.Rotation
 
 			// calculate fps
 			if track.Timescale > 0 && track.FrameCount > 0 {
@@ -464,8 +765,17 @@ func (p *MP4Parser) calculateMetadata() error {
 		case "soun":
 			p.metadata.HasAudio = true
 			p.metadata.AudioCodec = track.Codec
+			p.metadata.AudioCodecName = track.CodecName
+			p.metadata.AudioBitrate = track.Bitrate
+			p.metadata.AudioSampleRate = track.SampleRate
+			p.metadata.AudioChannels = track.Channels
+			p.metadata.AudioSampleSize = track.SampleSize
 		}
-		if len(track.SttsBox.Entries) > 0 {
+		if track.Timecode != nil && p.metadata.Timecode == "" {
+			p.metadata.Timecode = track.Timecode.Start
+			p.metadata.ReelName = track.Timecode.ReelName
+		}
+		if track.SttsBox != nil && len(track.SttsBox.Entries) > 0 {
 			// dtsLines := buildDTSTimeline(track.SttsBox.Entries)
 			// ptsLines := buildPTSTimeline(dtsLines, track.CttsBox.Entries)
 
@@ -479,7 +789,162 @@ func (p *MP4Parser) calculateMetadata() error {
 	return nil
 }
 
+// addFragmentTotals accounts the samples of movie fragments to tracks whose
+// sample tables in moov are empty, as in fragmented files.
+func (p *MP4Parser) addFragmentTotals() {
+	for i := range p.tracks {
+		track := &p.tracks[i]
+		if track.SampleCount > 0 {
+			continue
+		}
+		var samples uint32
+		var duration uint64
+		for _, frag := range p.fragments {
+			for _, tf := range frag.Tracks {
+				if tf.TrackID == track.TrackID {
+					samples += uint32(len(tf.Samples))
+					duration += tf.Duration()
+				}
+			}
+		}
+		if samples == 0 {
+			continue
+		}
+		track.SampleCount = samples
+		track.FrameCount = samples
+		if track.Duration == 0 {
+			track.Duration = duration
+		}
+	}
+
+	if p.metadata.Duration == 0 {
+		for _, track := range p.tracks {
+			if track.Timescale == 0 {
+				continue
+			}
+			if d := scaleToDuration(track.Duration, track.Timescale); d > p.metadata.Duration {
+				p.metadata.Duration = d
+			}
+		}
+	}
+}
+
 // get tracks
 func (p *MP4Parser) GetTracks() []TrackInfo {
 	return p.tracks
 }
+
+// get the alternate groups in track order; tracks in group 0 are not
+// alternatives of any other track and are left out
+func (p *MP4Parser) GetAlternateGroups() []AlternateGroup {
+	var groups []AlternateGroup
+	index := map[int16]int{}
+	for _, t := range p.tracks {
+		id := t.Header.AlternateGroup
+		if id == 0 {
+			continue
+		}
+		i, ok := index[id]
+		if !ok {
+			i = len(groups)
+			index[id] = i
+			groups = append(groups, AlternateGroup{ID: id, HandlerType: t.HandlerType})
+		}
+		groups[i].TrackIDs = append(groups[i].TrackIDs, t.TrackID)
+	}
+	return groups
+}
+
+// get the IDs of the tracks that can replace the given track
+func (p *MP4Parser) GetAlternates(trackID uint32) []uint32 {
+	for _, g := range p.GetAlternateGroups() {
+		for _, id := range g.TrackIDs {
+			if id != trackID {
+				continue
+			}
+			var others []uint32
+			for _, other := range g.TrackIDs {
+				if other != trackID {
+					others = append(others, other)
+				}
+			}
+			return others
+		}
+	}
+	return nil
+}
+
+// get every track reference as an edge from the referencing track to the
+// referenced one, in track order
+func (p *MP4Parser) GetTrackRelations() []TrackRelation {
+	var relations []TrackRelation
+	for _, t := range p.tracks {
+		for _, ref := range t.References {
+			for _, id := range ref.TrackIDs {
+				if id != 0 {
+					relations = append(relations, TrackRelation{From: t.TrackID, To: id, Type: ref.Type})
+				}
+			}
+		}
+	}
+	return relations
+}
+
+// get the tracks that trackID references with refType, e.g. the chapter
+// tracks ("chap") of a video track
+func (p *MP4Parser) GetReferencedTracks(trackID uint32, refType string) []uint32 {
+	var ids []uint32
+	for _, r := range p.GetTrackRelations() {
+		if r.From == trackID && r.Type == refType {
+			ids = append(ids, r.To)
+		}
+	}
+	return ids
+}
+
+// get the tracks that reference trackID with refType, e.g. the metadata
+// tracks describing ("cdsc") a video track
+func (p *MP4Parser) GetReferencingTracks(trackID uint32, refType string) []uint32 {
+	var ids []uint32
+	for _, r := range p.GetTrackRelations() {
+		if r.To == trackID && r.Type == refType {
+			ids = append(ids, r.From)
+		}
+	}
+	return ids
+}
+
+// get the movie header (mvhd)
+func (p *MP4Parser) GetMovieHeader() MVHDBox {
+	return p.mvhd
+}
+
+// get chapters, from a QuickTime chapter track or a Nero chapter list
+func (p *MP4Parser) GetChapters() []Chapter {
+	return p.chapters
+}
+
+// get warnings about movie level boxes that were skipped
+func (p *MP4Parser) GetWarnings() []string {
+	return p.warnings
+}
+
+// get iTunes style metadata (udta/meta/ilst), nil if the file has none
+func (p *MP4Parser) GetTags() *Tags {
+	return p.tags
+}
+
+// get timed events (emsg) in file order
+func (p *MP4Parser) GetEvents() []EventMessage {
+	return p.events
+}
+
+// get movie fragments (moof) in file order
+func (p *MP4Parser) GetFragments() []Fragment {
+	return p.fragments
+}
+
+// get producer reference times (prft) in file order
+func (p *MP4Parser) GetProducerReferenceTimes() []ProducerReferenceTime {
+	return p.prfts
+}
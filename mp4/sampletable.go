@@ -0,0 +1,301 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Sample is one media sample located through the sample table or a track
+// fragment. Times are in the media timescale.
+type Sample struct {
+	Offset            int64 // absolute file offset of the sample data
+	Size              uint32
+	DecodeTime        uint64
+	Duration          uint32
+	CompositionOffset int64
+	DescriptionIndex  uint32 // 1-based index into stsd
+}
+
+// PresentationTime returns the composition time of the sample.
+func (s Sample) PresentationTime() int64 {
+	return int64(s.DecodeTime) + s.CompositionOffset
+}
+
+// Parse stsz box (or stz2 with 4, 8 or 16 bit sizes)
+type stszBox struct {
+	SampleSize  uint32 // constant size, 0 if the sizes are listed
+	SampleCount uint32
+	Sizes       []uint32 // only set when SampleSize is 0
+}
+
+// Size returns the size of the 0-based sample i.
+func (b *stszBox) Size(i int) uint32 {
+	if b.SampleSize != 0 {
+		return b.SampleSize
+	}
+	if i < len(b.Sizes) {
+		return b.Sizes[i]
+	}
+	return 0
+}
+
+type SampleToChunkEntry struct {
+	FirstChunk             uint32
+	SamplesPerChunk        uint32
+	SampleDescriptionIndex uint32
+}
+
+// Parse stsc box
+type stscBox struct {
+	Entries []SampleToChunkEntry
+}
+
+// Parse stco / co64 box
+type stcoBox struct {
+	Offsets []uint64
+}
+
+// parseStsz reads an stsz box with a payload of size bytes.
+func parseStsz(r io.Reader, size int64, info *TrackInfo) error {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return fmt.Errorf("read stsz: %w", err)
+	}
+	b := &stszBox{
+		SampleSize:  binary.BigEndian.Uint32(hdr[4:]),
+		SampleCount: binary.BigEndian.Uint32(hdr[8:]),
+	}
+	if b.SampleSize == 0 {
+		if int64(b.SampleCount) > (size-12)/4 {
+			return fmt.Errorf("stsz: %d entries exceed box", b.SampleCount)
+		}
+		buf := make([]byte, 4*int64(b.SampleCount))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return fmt.Errorf("read stsz entries: %w", err)
+		}
+		b.Sizes = make([]uint32, b.SampleCount)
+		for i := range b.Sizes {
+			b.Sizes[i] = binary.BigEndian.Uint32(buf[4*i:])
+		}
+	}
+	info.StszBox = b
+	return nil
+}
+
+// compact sample size box (ISO/IEC 14496-12 8.7.3.3)
+func parseStz2(r io.Reader, size int64, info *TrackInfo) error {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return fmt.Errorf("read stz2: %w", err)
+	}
+	fieldSize := int(hdr[7])
+	count := binary.BigEndian.Uint32(hdr[8:])
+	if fieldSize != 4 && fieldSize != 8 && fieldSize != 16 {
+		return fmt.Errorf("stz2: invalid field size %d", fieldSize)
+	}
+	if (int64(count)*int64(fieldSize)+7)/8 > size-12 {
+		return fmt.Errorf("stz2: %d entries exceed box", count)
+	}
+	buf := make([]byte, (int64(count)*int64(fieldSize)+7)/8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fmt.Errorf("read stz2 entries: %w", err)
+	}
+	br := newBitReader(buf)
+	b := &stszBox{SampleCount: count, Sizes: make([]uint32, count)}
+	for i := range b.Sizes {
+		b.Sizes[i] = uint32(br.readBits(fieldSize))
+	}
+	info.StszBox = b
+	return nil
+}
+
+func parseStsc(r io.Reader, size int64, info *TrackInfo) error {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return fmt.Errorf("read stsc: %w", err)
+	}
+	count := binary.BigEndian.Uint32(hdr[4:])
+	if int64(count) > (size-8)/12 {
+		return fmt.Errorf("stsc: %d entries exceed box", count)
+	}
+	buf := make([]byte, 12*int64(count))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fmt.Errorf("read stsc entries: %w", err)
+	}
+	b := &stscBox{Entries: make([]SampleToChunkEntry, count)}
+	for i := range b.Entries {
+		b.Entries[i] = SampleToChunkEntry{
+			FirstChunk:             binary.BigEndian.Uint32(buf[12*i:]),
+			SamplesPerChunk:        binary.BigEndian.Uint32(buf[12*i+4:]),
+			SampleDescriptionIndex: binary.BigEndian.Uint32(buf[12*i+8:]),
+		}
+	}
+	info.StscBox = b
+	return nil
+}
+
+// parseStco reads stco (32 bit offsets) or, when large is set, co64, with a
+// payload of size bytes.
+func parseStco(r io.Reader, size int64, info *TrackInfo, large bool) error {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return fmt.Errorf("read stco: %w", err)
+	}
+	count := binary.BigEndian.Uint32(hdr[4:])
+	width := int64(4)
+	if large {
+		width = 8
+	}
+	if int64(count) > (size-8)/width {
+		return fmt.Errorf("stco: %d entries exceed box", count)
+	}
+	buf := make([]byte, width*int64(count))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fmt.Errorf("read stco entries: %w", err)
+	}
+	b := &stcoBox{Offsets: make([]uint64, count)}
+	for i := range b.Offsets {
+		if large {
+			b.Offsets[i] = binary.BigEndian.Uint64(buf[8*i:])
+		} else {
+			b.Offsets[i] = uint64(binary.BigEndian.Uint32(buf[4*i:]))
+		}
+	}
+	info.StcoBox = b
+	return nil
+}
+
+// buildSampleTable resolves the stts, ctts, stsz, stsc and stco boxes of a
+// track into a list of samples.
+func buildSampleTable(info *TrackInfo) ([]Sample, error) {
+	if info.StszBox == nil || info.StscBox == nil || info.StcoBox == nil {
+		return nil, nil
+	}
+	count := int(info.StszBox.SampleCount)
+
+	// sample to chunk: each entry runs until the first chunk of the next
+	chunks := info.StcoBox.Offsets
+	stsc := info.StscBox.Entries
+
+	// a constant size stsz has no table to bound its sample count, so the
+	// preallocation is capped at what the chunks can hold
+	samples := make([]Sample, 0, min(count, chunkCapacity(stsc, len(chunks))))
+	for e, entry := range stsc {
+		lastChunk := uint32(len(chunks))
+		if e+1 < len(stsc) {
+			lastChunk = stsc[e+1].FirstChunk - 1
+		}
+		if entry.FirstChunk == 0 || lastChunk > uint32(len(chunks)) {
+			return nil, fmt.Errorf("stsc entry %d references chunks %d-%d of %d", e, entry.FirstChunk, lastChunk, len(chunks))
+		}
+		for c := entry.FirstChunk; c <= lastChunk; c++ {
+			offset := int64(chunks[c-1])
+			for i := uint32(0); i < entry.SamplesPerChunk && len(samples) < count; i++ {
+				size := info.StszBox.Size(len(samples))
+				samples = append(samples, Sample{
+					Offset:           offset,
+					Size:             size,
+					DescriptionIndex: entry.SampleDescriptionIndex,
+				})
+				offset += int64(size)
+			}
+		}
+	}
+	if len(samples) < count {
+		return samples, fmt.Errorf("chunks hold %d of %d samples", len(samples), count)
+	}
+
+	if info.SttsBox != nil {
+		i, t := 0, uint64(0)
+		for _, e := range info.SttsBox.Entries {
+			for j := uint32(0); j < e.Count && i < len(samples); j++ {
+				samples[i].DecodeTime = t
+				samples[i].Duration = e.Delta
+				t += uint64(e.Delta)
+				i++
+			}
+		}
+	}
+	if info.CttsBox != nil {
+		i := 0
+		for _, e := range info.CttsBox.Entries {
+			for j := uint32(0); j < e.Count && i < len(samples); j++ {
+				samples[i].CompositionOffset = int64(e.Offset)
+				i++
+			}
+		}
+	}
+	return samples, nil
+}
+
+// chunkCapacity returns the number of samples the chunks of an stsc hold,
+// saturating at math.MaxInt32.
+func chunkCapacity(stsc []SampleToChunkEntry, numChunks int) int {
+	total := uint64(0)
+	for e, entry := range stsc {
+		lastChunk := uint64(numChunks)
+		if e+1 < len(stsc) {
+			lastChunk = uint64(stsc[e+1].FirstChunk) - 1
+		}
+		if entry.FirstChunk == 0 || uint64(entry.FirstChunk) > lastChunk {
+			continue
+		}
+		total += (lastChunk - uint64(entry.FirstChunk) + 1) * uint64(entry.SamplesPerChunk)
+		if total > math.MaxInt32 {
+			return math.MaxInt32
+		}
+	}
+	return int(total)
+}
+
+// fragmentSamples returns the samples of a track from its track fragments,
+// continuing the decode times when a traf has no tfdt.
+func fragmentSamples(fragments []Fragment, trackID uint32, start uint64) []Sample {
+	var samples []Sample
+	t := start
+	for _, f := range fragments {
+		for _, tf := range f.Tracks {
+			if tf.TrackID != trackID {
+				continue
+			}
+			if tf.HasDecodeTime {
+				t = tf.BaseMediaDecodeTime
+			}
+			for _, fs := range tf.Samples {
+				samples = append(samples, Sample{
+					Offset:            fs.Offset,
+					Size:              fs.Size,
+					DecodeTime:        t,
+					Duration:          fs.Duration,
+					CompositionOffset: fs.CompositionOffset,
+					DescriptionIndex:  tf.SampleDescriptionIndex,
+				})
+				t += uint64(fs.Duration)
+			}
+		}
+	}
+	return samples
+}
+
+// GetSamples returns the samples of a track from its sample table followed
+// by those of its track fragments.
+func (p *MP4Parser) GetSamples(trackID uint32) ([]Sample, error) {
+	for i := range p.tracks {
+		t := &p.tracks[i]
+		if t.TrackID != trackID {
+			continue
+		}
+		samples, err := buildSampleTable(t)
+		if err != nil {
+			return samples, err
+		}
+		var end uint64
+		if n := len(samples); n > 0 {
+			end = samples[n-1].DecodeTime + uint64(samples[n-1].Duration)
+		}
+		return append(samples, fragmentSamples(p.fragments, trackID, end)...), nil
+	}
+	return nil, fmt.Errorf("track %d not found", trackID)
+}
@@ -0,0 +1,99 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestBuildSampleTable(t *testing.T) {
+	info := &TrackInfo{
+		SttsBox: &sttsBox{Entries: []TimeToSampleEntry{{Count: 3, Delta: 10}, {Count: 2, Delta: 20}}},
+		CttsBox: &cttsBox{Entries: []CompositionOffsetEntry{{Count: 1, Offset: 20}, {Count: 4, Offset: -10}}},
+		StszBox: &stszBox{SampleCount: 5, Sizes: []uint32{100, 200, 300, 400, 500}},
+		StscBox: &stscBox{Entries: []SampleToChunkEntry{
+			{FirstChunk: 1, SamplesPerChunk: 2, SampleDescriptionIndex: 1},
+			{FirstChunk: 2, SamplesPerChunk: 3, SampleDescriptionIndex: 2},
+		}},
+		StcoBox: &stcoBox{Offsets: []uint64{1000, 5000}},
+	}
+	samples, err := buildSampleTable(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Sample{
+		{Offset: 1000, Size: 100, DecodeTime: 0, Duration: 10, CompositionOffset: 20, DescriptionIndex: 1},
+		{Offset: 1100, Size: 200, DecodeTime: 10, Duration: 10, CompositionOffset: -10, DescriptionIndex: 1},
+		{Offset: 5000, Size: 300, DecodeTime: 20, Duration: 10, CompositionOffset: -10, DescriptionIndex: 2},
+		{Offset: 5300, Size: 400, DecodeTime: 30, Duration: 20, CompositionOffset: -10, DescriptionIndex: 2},
+		{Offset: 5700, Size: 500, DecodeTime: 50, Duration: 20, CompositionOffset: -10, DescriptionIndex: 2},
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(samples), len(want))
+	}
+	for i := range want {
+		if samples[i] != want[i] {
+			t.Errorf("sample %d = %+v, want %+v", i, samples[i], want[i])
+		}
+	}
+	if got := samples[1].PresentationTime(); got != 0 {
+		t.Errorf("PresentationTime() = %d, want 0", got)
+	}
+}
+
+func TestBuildSampleTableConstantSizeCount(t *testing.T) {
+	// a constant size stsz claiming far more samples than the chunks hold
+	info := &TrackInfo{
+		StszBox: &stszBox{SampleSize: 10, SampleCount: 0xffffffff},
+		StscBox: &stscBox{Entries: []SampleToChunkEntry{{FirstChunk: 1, SamplesPerChunk: 2, SampleDescriptionIndex: 1}}},
+		StcoBox: &stcoBox{Offsets: []uint64{0, 100}},
+	}
+	samples, err := buildSampleTable(info)
+	if err == nil {
+		t.Error("missing error for samples the chunks do not hold")
+	}
+	if len(samples) != 4 || cap(samples) != 4 {
+		t.Errorf("got %d samples with capacity %d, want 4", len(samples), cap(samples))
+	}
+}
+
+func TestBuildSampleTableBadChunkReference(t *testing.T) {
+	info := &TrackInfo{
+		StszBox: &stszBox{SampleSize: 10, SampleCount: 2},
+		StscBox: &stscBox{Entries: []SampleToChunkEntry{{FirstChunk: 0, SamplesPerChunk: 1}}},
+		StcoBox: &stcoBox{Offsets: []uint64{0, 100}},
+	}
+	if _, err := buildSampleTable(info); err == nil {
+		t.Error("stsc entry with first chunk 0 was accepted")
+	}
+}
+
+func TestParseSampleTableCounts(t *testing.T) {
+	fullBox := func(fields ...uint32) []byte {
+		var b []byte
+		for _, f := range fields {
+			b = binary.BigEndian.AppendUint32(b, f)
+		}
+		return b
+	}
+	tests := []struct {
+		name  string
+		parse func(buf []byte) error
+		buf   []byte
+		ok    bool
+	}{
+		{"stsz", func(b []byte) error { return parseStsz(bytes.NewReader(b), int64(len(b)), &TrackInfo{}) }, fullBox(0, 0, 2, 7, 9), true},
+		{"stsz count past box", func(b []byte) error { return parseStsz(bytes.NewReader(b), int64(len(b)), &TrackInfo{}) }, fullBox(0, 0, 0x40000000, 7), false},
+		{"stsz constant size", func(b []byte) error { return parseStsz(bytes.NewReader(b), int64(len(b)), &TrackInfo{}) }, fullBox(0, 4, 0xffffffff), true},
+		{"stz2 count past box", func(b []byte) error { return parseStz2(bytes.NewReader(b), int64(len(b)), &TrackInfo{}) }, fullBox(0, 8, 100, 0), false},
+		{"stsc count past box", func(b []byte) error { return parseStsc(bytes.NewReader(b), int64(len(b)), &TrackInfo{}) }, fullBox(0, 2, 1, 1, 1), false},
+		{"stco", func(b []byte) error { return parseStco(bytes.NewReader(b), int64(len(b)), &TrackInfo{}, false) }, fullBox(0, 2, 8, 16), true},
+		{"co64 count past box", func(b []byte) error { return parseStco(bytes.NewReader(b), int64(len(b)), &TrackInfo{}, true) }, fullBox(0, 2, 0, 8, 0), false},
+		{"stts count past box", func(b []byte) error { return parseStts(bytes.NewReader(b), int64(len(b)), &TrackInfo{}) }, fullBox(0, 0x10000000, 1, 1), false},
+	}
+	for _, tt := range tests {
+		if err := tt.parse(tt.buf); (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
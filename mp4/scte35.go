@@ -0,0 +1,384 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SCTE-35 splice_command_type values.
+const (
+	SpliceNull                 = 0x00
+	SpliceSchedule             = 0x04
+	SpliceInsertCommand        = 0x05
+	TimeSignalCommand          = 0x06
+	BandwidthReservation       = 0x07
+	PrivateCommand             = 0xff
+	SegmentationDescriptorTag  = 0x02
+	scte35PTSClock             = 90000
+	scte35DescriptorIdentifier = 0x43554549 // "CUEI"
+)
+
+// SpliceInfoSection is a decoded SCTE-35 splice_info_section.
+type SpliceInfoSection struct {
+	TableID             uint8
+	SAPType             uint8
+	ProtocolVersion     uint8
+	EncryptedPacket     bool
+	EncryptionAlgorithm uint8
+	PTSAdjustment       uint64
+	CWIndex             uint8
+	Tier                uint16
+	SpliceCommandType   uint8
+	SpliceInsert        *SpliceInsert // splice_insert()
+	TimeSignal          *SpliceTime   // time_signal()
+	PrivateIdentifier   uint32        // private_command() identifier
+	CommandData         []byte        // raw splice command bytes
+	Descriptors         []SpliceDescriptor
+	CRC32               uint32
+}
+
+// SpliceTime is splice_time(). PTSTime is in 90 kHz ticks.
+type SpliceTime struct {
+	TimeSpecified bool
+	PTSTime       uint64
+}
+
+// BreakDuration is break_duration(). Duration is in 90 kHz ticks.
+type BreakDuration struct {
+	AutoReturn bool
+	Duration   uint64
+}
+
+type SpliceComponent struct {
+	Tag        uint8
+	SpliceTime *SpliceTime
+}
+
+// SpliceInsert is splice_insert().
+type SpliceInsert struct {
+	EventID         uint32
+	EventCancel     bool
+	OutOfNetwork    bool
+	ProgramSplice   bool
+	SpliceImmediate bool
+	SpliceTime      *SpliceTime
+	Components      []SpliceComponent
+	BreakDuration   *BreakDuration
+	UniqueProgramID uint16
+	AvailNum        uint8
+	AvailsExpected  uint8
+}
+
+// SpliceDescriptor is one entry of the descriptor loop. Segmentation is set
+// for segmentation_descriptor() carrying the "CUEI" identifier.
+type SpliceDescriptor struct {
+	Tag          uint8
+	Identifier   uint32
+	Data         []byte
+	Segmentation *SegmentationDescriptor
+}
+
+// SegmentationDescriptor is segmentation_descriptor(). Duration is in 90 kHz
+// ticks and only meaningful when HasDuration is set.
+type SegmentationDescriptor struct {
+	EventID               uint32
+	EventCancel           bool
+	ProgramSegmentation   bool
+	HasDuration           bool
+	DeliveryNotRestricted bool
+	WebDeliveryAllowed    bool
+	NoRegionalBlackout    bool
+	ArchiveAllowed        bool
+	DeviceRestrictions    uint8
+	Duration              uint64
+	UPIDType              uint8
+	UPID                  []byte
+	TypeID                uint8
+	SegmentNum            uint8
+	SegmentsExpected      uint8
+	SubSegmentNum         uint8
+	SubSegmentsExpected   uint8
+}
+
+// parseSpliceInfoSection decodes an SCTE-35 splice_info_section as carried
+// in the message_data of a urn:scte:scte35:2013:bin emsg.
+func parseSpliceInfoSection(buf []byte) (*SpliceInfoSection, error) {
+	br := newBitReader(buf)
+	s := &SpliceInfoSection{}
+
+	s.TableID = uint8(br.readBits(8))
+	if br.err == nil && s.TableID != 0xfc {
+		return nil, fmt.Errorf("scte35: unexpected table_id 0x%02x", s.TableID)
+	}
+	br.skipBits(2) // section_syntax_indicator, private_indicator
+	s.SAPType = uint8(br.readBits(2))
+	sectionLength := int(br.readBits(12))
+	if br.err == nil && sectionLength > br.bitsLeft()/8 {
+		return nil, fmt.Errorf("scte35: section_length %d exceeds payload", sectionLength)
+	}
+	s.ProtocolVersion = uint8(br.readBits(8))
+	s.EncryptedPacket = br.readFlag()
+	s.EncryptionAlgorithm = uint8(br.readBits(6))
+	s.PTSAdjustment = br.readBits(33)
+	s.CWIndex = uint8(br.readBits(8))
+	s.Tier = uint16(br.readBits(12))
+	commandLength := int(br.readBits(12))
+	s.SpliceCommandType = uint8(br.readBits(8))
+	if br.err != nil {
+		return nil, fmt.Errorf("scte35: %w", br.err)
+	}
+	if s.EncryptedPacket {
+		// the command and descriptors can't be decoded without the key
+		return s, nil
+	}
+
+	cmdStart := br.pos / 8
+	switch s.SpliceCommandType {
+	case SpliceNull, BandwidthReservation:
+	case SpliceInsertCommand:
+		s.SpliceInsert = readSpliceInsert(br)
+	case TimeSignalCommand:
+		s.TimeSignal = readSpliceTime(br)
+	case PrivateCommand:
+		s.PrivateIdentifier = uint32(br.readBits(32))
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("scte35: splice command: %w", br.err)
+	}
+
+	// splice_command_length 0xfff is legacy "unknown"; fall back to what
+	// the command decoder consumed
+	if commandLength == 0xfff {
+		commandLength = br.pos/8 - cmdStart
+	}
+	if cmdStart+commandLength > len(buf) {
+		return nil, errors.New("scte35: splice_command_length exceeds payload")
+	}
+	s.CommandData = buf[cmdStart : cmdStart+commandLength]
+	br.pos = (cmdStart + commandLength) * 8
+
+	loopLength := int(br.readBits(16))
+	loopEnd := br.pos/8 + loopLength
+	if br.err != nil || loopEnd > len(buf) {
+		return nil, errors.New("scte35: descriptor_loop_length exceeds payload")
+	}
+	for br.pos/8+2 <= loopEnd {
+		d := SpliceDescriptor{Tag: uint8(br.readBits(8))}
+		length := int(br.readBits(8))
+		start := br.pos / 8
+		if start+length > loopEnd {
+			return nil, errors.New("scte35: splice descriptor exceeds descriptor loop")
+		}
+		if length >= 4 {
+			d.Identifier = uint32(br.readBits(32))
+			d.Data = buf[start+4 : start+length]
+		} else {
+			d.Data = buf[start : start+length]
+		}
+		if d.Tag == SegmentationDescriptorTag && d.Identifier == scte35DescriptorIdentifier {
+			seg, err := parseSegmentationDescriptor(d.Data)
+			if err != nil {
+				return nil, err
+			}
+			d.Segmentation = seg
+		}
+		s.Descriptors = append(s.Descriptors, d)
+		br.pos = (start + length) * 8
+	}
+
+	if len(buf) >= 4 {
+		s.CRC32 = binary.BigEndian.Uint32(buf[len(buf)-4:])
+	}
+
+	return s, nil
+}
+
+func readSpliceTime(br *bitReader) *SpliceTime {
+	t := &SpliceTime{TimeSpecified: br.readFlag()}
+	if t.TimeSpecified {
+		br.skipBits(6)
+		t.PTSTime = br.readBits(33)
+	} else {
+		br.skipBits(7)
+	}
+	return t
+}
+
+func readSpliceInsert(br *bitReader) *SpliceInsert {
+	si := &SpliceInsert{EventID: uint32(br.readBits(32))}
+	si.EventCancel = br.readFlag()
+	br.skipBits(7)
+	if si.EventCancel {
+		return si
+	}
+
+	si.OutOfNetwork = br.readFlag()
+	si.ProgramSplice = br.readFlag()
+	durationFlag := br.readFlag()
+	si.SpliceImmediate = br.readFlag()
+	br.skipBits(4)
+
+	if si.ProgramSplice && !si.SpliceImmediate {
+		si.SpliceTime = readSpliceTime(br)
+	}
+	if !si.ProgramSplice {
+		count := int(br.readBits(8))
+		for i := 0; i < count && br.err == nil; i++ {
+			c := SpliceComponent{Tag: uint8(br.readBits(8))}
+			if !si.SpliceImmediate {
+				c.SpliceTime = readSpliceTime(br)
+			}
+			si.Components = append(si.Components, c)
+		}
+	}
+	if durationFlag {
+		bd := &BreakDuration{AutoReturn: br.readFlag()}
+		br.skipBits(6)
+		bd.Duration = br.readBits(33)
+		si.BreakDuration = bd
+	}
+	si.UniqueProgramID = uint16(br.readBits(16))
+	si.AvailNum = uint8(br.readBits(8))
+	si.AvailsExpected = uint8(br.readBits(8))
+
+	return si
+}
+
+// parseSegmentationDescriptor decodes the bytes following the "CUEI"
+// identifier of a segmentation_descriptor().
+func parseSegmentationDescriptor(buf []byte) (*SegmentationDescriptor, error) {
+	br := newBitReader(buf)
+	sd := &SegmentationDescriptor{EventID: uint32(br.readBits(32))}
+	sd.EventCancel = br.readFlag()
+	br.skipBits(7)
+	if sd.EventCancel {
+		if br.err != nil {
+			return nil, fmt.Errorf("scte35: segmentation_descriptor: %w", br.err)
+		}
+		return sd, nil
+	}
+
+	sd.ProgramSegmentation = br.readFlag()
+	sd.HasDuration = br.readFlag()
+	sd.DeliveryNotRestricted = br.readFlag()
+	if !sd.DeliveryNotRestricted {
+		sd.WebDeliveryAllowed = br.readFlag()
+		sd.NoRegionalBlackout = br.readFlag()
+		sd.ArchiveAllowed = br.readFlag()
+		sd.DeviceRestrictions = uint8(br.readBits(2))
+	} else {
+		br.skipBits(5)
+	}
+	if !sd.ProgramSegmentation {
+		count := int(br.readBits(8))
+		// component_tag(8), reserved(7), pts_offset(33)
+		br.skipBits(count * 48)
+	}
+	if sd.HasDuration {
+		sd.Duration = br.readBits(40)
+	}
+	sd.UPIDType = uint8(br.readBits(8))
+	upidLength := int(br.readBits(8))
+	sd.UPID = br.readBytes(upidLength)
+	sd.TypeID = uint8(br.readBits(8))
+	sd.SegmentNum = uint8(br.readBits(8))
+	sd.SegmentsExpected = uint8(br.readBits(8))
+	if br.err != nil {
+		return nil, fmt.Errorf("scte35: segmentation_descriptor: %w", br.err)
+	}
+
+	// sub_segment_num and sub_segments_expected were added in SCTE-35 2016
+	// for placement opportunities and are absent in older encoders
+	switch sd.TypeID {
+	case 0x34, 0x36, 0x38, 0x3a, 0x44, 0x46:
+		if br.bitsLeft() >= 16 {
+			sd.SubSegmentNum = uint8(br.readBits(8))
+			sd.SubSegmentsExpected = uint8(br.readBits(8))
+		}
+	}
+
+	return sd, nil
+}
+
+// PTSToDuration converts a 90 kHz SCTE-35 tick count to a time.Duration.
+func PTSToDuration(pts uint64) time.Duration {
+	return scaleToDuration(pts, scte35PTSClock)
+}
+
+// SpliceCommandName returns the name of a splice_command_type.
+func SpliceCommandName(t uint8) string {
+	switch t {
+	case SpliceNull:
+		return "splice_null"
+	case SpliceSchedule:
+		return "splice_schedule"
+	case SpliceInsertCommand:
+		return "splice_insert"
+	case TimeSignalCommand:
+		return "time_signal"
+	case BandwidthReservation:
+		return "bandwidth_reservation"
+	case PrivateCommand:
+		return "private_command"
+	default:
+		return fmt.Sprintf("reserved(0x%02x)", t)
+	}
+}
+
+// SegmentationTypeName returns the name of a segmentation_type_id.
+func SegmentationTypeName(id uint8) string {
+	names := map[uint8]string{
+		0x00: "Not Indicated",
+		0x01: "Content Identification",
+		0x10: "Program Start",
+		0x11: "Program End",
+		0x12: "Program Early Termination",
+		0x13: "Program Breakaway",
+		0x14: "Program Resumption",
+		0x15: "Program Runover Planned",
+		0x16: "Program Runover Unplanned",
+		0x17: "Program Overlap Start",
+		0x18: "Program Blackout Override",
+		0x19: "Program Start - In Progress",
+		0x20: "Chapter Start",
+		0x21: "Chapter End",
+		0x22: "Break Start",
+		0x23: "Break End",
+		0x24: "Opening Credit Start",
+		0x25: "Opening Credit End",
+		0x26: "Closing Credit Start",
+		0x27: "Closing Credit End",
+		0x30: "Provider Advertisement Start",
+		0x31: "Provider Advertisement End",
+		0x32: "Distributor Advertisement Start",
+		0x33: "Distributor Advertisement End",
+		0x34: "Provider Placement Opportunity Start",
+		0x35: "Provider Placement Opportunity End",
+		0x36: "Distributor Placement Opportunity Start",
+		0x37: "Distributor Placement Opportunity End",
+		0x38: "Provider Overlay Placement Opportunity Start",
+		0x39: "Provider Overlay Placement Opportunity End",
+		0x3a: "Distributor Overlay Placement Opportunity Start",
+		0x3b: "Distributor Overlay Placement Opportunity End",
+		0x3c: "Provider Promo Start",
+		0x3d: "Provider Promo End",
+		0x3e: "Distributor Promo Start",
+		0x3f: "Distributor Promo End",
+		0x40: "Unscheduled Event Start",
+		0x41: "Unscheduled Event End",
+		0x42: "Alternate Content Opportunity Start",
+		0x43: "Alternate Content Opportunity End",
+		0x44: "Provider Ad Block Start",
+		0x45: "Provider Ad Block End",
+		0x46: "Distributor Ad Block Start",
+		0x47: "Distributor Ad Block End",
+		0x50: "Network Start",
+		0x51: "Network End",
+	}
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("reserved(0x%02x)", id)
+}
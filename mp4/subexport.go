@@ -0,0 +1,231 @@
+package mp4
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Subtitle export formats accepted by ExtractSubtitles.
+const (
+	SubtitleSRT    = "srt"
+	SubtitleWebVTT = "vtt"
+	SubtitleTTML   = "ttml"
+)
+
+// ExtractSubtitles writes the cues of a subtitle track as an SRT, WebVTT or
+// TTML document. The cue times have the edit list applied. Bold, italic and
+// underline are kept in all three formats; WebVTT also keeps cue
+// identifiers, settings and the other cue text tags.
+func (p *MP4Parser) ExtractSubtitles(trackID uint32, format string, w io.Writer) error {
+	for i := range p.tracks {
+		t := &p.tracks[i]
+		if t.TrackID != trackID {
+			continue
+		}
+		if !isSubtitleTrack(t) {
+			return fmt.Errorf("track %d is not a subtitle track (%s)", trackID, t.Codec)
+		}
+		switch format {
+		case SubtitleSRT:
+			return WriteSRT(w, t.Cues)
+		case SubtitleWebVTT, "webvtt":
+			header := ""
+			if t.WebVTT != nil {
+				header = t.WebVTT.Config
+			}
+			return WriteWebVTT(w, t.Cues, header)
+		case SubtitleTTML:
+			lang := t.Language
+			if lang == "und" {
+				lang = ""
+			}
+			return WriteTTML(w, t.Cues, lang)
+		default:
+			return fmt.Errorf("unknown subtitle format %q", format)
+		}
+	}
+	return fmt.Errorf("track %d not found", trackID)
+}
+
+// WriteSRT writes cues as SubRip text.
+func WriteSRT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n", i+1, formatCueTime(c.Start, ','), formatCueTime(c.End, ','))
+		var b strings.Builder
+		for _, tok := range cueTokens(c.Text) {
+			switch {
+			case tok.tag == "":
+				b.WriteString(tok.text)
+			case tok.tag == "b" || tok.tag == "i" || tok.tag == "u":
+				if tok.end {
+					b.WriteString("</" + tok.tag + ">")
+				} else {
+					b.WriteString("<" + tok.tag + ">")
+				}
+			}
+		}
+		fmt.Fprintf(bw, "%s\n\n", cueLines(b.String()))
+	}
+	return bw.Flush()
+}
+
+// WriteWebVTT writes cues as a WebVTT file. header is the file header from
+// the vttC box; "WEBVTT" is used when it is empty.
+func WriteWebVTT(w io.Writer, cues []Cue, header string) error {
+	bw := bufio.NewWriter(w)
+	header = strings.TrimSpace(header)
+	if !strings.HasPrefix(header, "WEBVTT") {
+		header = "WEBVTT"
+	}
+	fmt.Fprintf(bw, "%s\n\n", header)
+	for _, c := range cues {
+		if c.ID != "" {
+			fmt.Fprintf(bw, "%s\n", c.ID)
+		}
+		fmt.Fprintf(bw, "%s --> %s", formatCueTime(c.Start, '.'), formatCueTime(c.End, '.'))
+		if c.Settings != "" {
+			fmt.Fprintf(bw, " %s", c.Settings)
+		}
+		fmt.Fprintf(bw, "\n%s\n\n", cueLines(c.Text))
+	}
+	return bw.Flush()
+}
+
+// WriteTTML writes cues as a TTML document, with styled spans for bold,
+// italic and underlined text.
+func WriteTTML(w io.Writer, cues []Cue, lang string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	fmt.Fprintf(bw, "<tt xmlns=\"http://www.w3.org/ns/ttml\" xmlns:tts=\"http://www.w3.org/ns/ttml#styling\" xml:lang=\"%s\">\n", xmlEscape(lang))
+	bw.WriteString("  <body>\n    <div>\n")
+	for _, c := range cues {
+		fmt.Fprintf(bw, "      <p begin=\"%s\" end=\"%s\"", formatCueTime(c.Start, '.'), formatCueTime(c.End, '.'))
+		if c.ID != "" {
+			fmt.Fprintf(bw, " xml:id=\"%s\"", xmlEscape(c.ID))
+		}
+		bw.WriteString(">")
+		var stack []string
+		for _, tok := range cueTokens(cueLines(c.Text)) {
+			if tok.tag == "" {
+				for n, line := range strings.Split(tok.text, "\n") {
+					if n > 0 {
+						bw.WriteString("<br/>")
+					}
+					bw.WriteString(xmlEscape(line))
+				}
+				continue
+			}
+			if tok.end {
+				// close the innermost element with this tag, and any
+				// left open inside it
+				for n := len(stack) - 1; n >= 0; n-- {
+					if stack[n] == tok.tag {
+						for ; len(stack) > n; stack = stack[:len(stack)-1] {
+							bw.WriteString("</span>")
+						}
+						break
+					}
+				}
+				continue
+			}
+			var style string
+			switch tok.tag {
+			case "b":
+				style = `tts:fontWeight="bold"`
+			case "i":
+				style = `tts:fontStyle="italic"`
+			case "u":
+				style = `tts:textDecoration="underline"`
+			default:
+				continue
+			}
+			fmt.Fprintf(bw, "<span %s>", style)
+			stack = append(stack, tok.tag)
+		}
+		for range stack {
+			bw.WriteString("</span>")
+		}
+		bw.WriteString("</p>\n")
+	}
+	bw.WriteString("    </div>\n  </body>\n</tt>\n")
+	return bw.Flush()
+}
+
+// formatCueTime formats a time as hh:mm:ss followed by sep and
+// milliseconds.
+func formatCueTime(d time.Duration, sep byte) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// cueLines drops the blank lines of a cue text, which would end the cue in
+// SRT and WebVTT.
+func cueLines(text string) string {
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) != "" {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cueToken is a tag or a run of unescaped text of a cue text.
+type cueToken struct {
+	tag  string // tag name without classes or annotation, "" for text
+	end  bool
+	text string
+}
+
+var cueUnescaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&nbsp;", "\u00a0", "&lrm;", "\u200e", "&rlm;", "\u200f")
+
+// cueTokens splits WebVTT cue text into tags and unescaped text.
+// Timestamp tags are returned with the tag name "timestamp".
+func cueTokens(text string) []cueToken {
+	var toks []cueToken
+	for text != "" {
+		i := strings.IndexByte(text, '<')
+		if i != 0 {
+			if i < 0 {
+				i = len(text)
+			}
+			toks = append(toks, cueToken{text: cueUnescaper.Replace(text[:i])})
+			text = text[i:]
+			continue
+		}
+		j := strings.IndexByte(text, '>')
+		if j < 0 {
+			// unterminated tag, ignored as the WebVTT parser would
+			break
+		}
+		tok := cueToken{tag: text[1:j]}
+		text = text[j+1:]
+		if strings.HasPrefix(tok.tag, "/") {
+			tok.tag, tok.end = tok.tag[1:], true
+		}
+		if k := strings.IndexAny(tok.tag, ". \t"); k >= 0 {
+			tok.tag = tok.tag[:k]
+		}
+		if tok.tag != "" && tok.tag[0] >= '0' && tok.tag[0] <= '9' {
+			tok.tag = "timestamp"
+		}
+		if tok.tag != "" {
+			toks = append(toks, tok)
+		}
+	}
+	return toks
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
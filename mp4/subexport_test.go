@@ -0,0 +1,76 @@
+package mp4
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var testCues = []Cue{
+	{Start: 1500 * time.Millisecond, End: 3 * time.Second, ID: "c1", Settings: "line:0", Text: "Hello <b>world</b>\n\nagain &amp; <c.red>more</c>"},
+	{Start: time.Hour + 2*time.Millisecond, End: time.Hour + time.Second, Text: "<i>A &lt; B</i>"},
+}
+
+func TestWriteSRT(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteSRT(&b, testCues); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:01,500 --> 00:00:03,000\nHello <b>world</b>\nagain & more\n\n" +
+		"2\n01:00:00,002 --> 01:00:01,000\n<i>A < B</i>\n\n"
+	if b.String() != want {
+		t.Errorf("WriteSRT() =\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestWriteWebVTT(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteWebVTT(&b, testCues, "WEBVTT - from vttC\n"); err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT - from vttC\n\n" +
+		"c1\n00:00:01.500 --> 00:00:03.000 line:0\nHello <b>world</b>\nagain &amp; <c.red>more</c>\n\n" +
+		"01:00:00.002 --> 01:00:01.000\n<i>A &lt; B</i>\n\n"
+	if b.String() != want {
+		t.Errorf("WriteWebVTT() =\n%q\nwant\n%q", b.String(), want)
+	}
+
+	b.Reset()
+	if err := WriteWebVTT(&b, nil, "not a header"); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "WEBVTT\n\n" {
+		t.Errorf("WriteWebVTT() with an invalid header = %q", b.String())
+	}
+}
+
+func TestWriteTTML(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteTTML(&b, testCues[:1], "en"); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:tts="http://www.w3.org/ns/ttml#styling" xml:lang="en">
+  <body>
+    <div>
+      <p begin="00:00:01.500" end="00:00:03.000" xml:id="c1">Hello <span tts:fontWeight="bold">world</span><br/>again &amp; more</p>
+    </div>
+  </body>
+</tt>
+`
+	if b.String() != want {
+		t.Errorf("WriteTTML() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteTTMLClosesUnbalancedTags(t *testing.T) {
+	var b bytes.Buffer
+	cues := []Cue{{End: time.Second, Text: "<b><i>x</b> y"}}
+	if err := WriteTTML(&b, cues, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := `<p begin="00:00:00.000" end="00:00:01.000"><span tts:fontWeight="bold"><span tts:fontStyle="italic">x</span></span> y</p>`
+	if !bytes.Contains(b.Bytes(), []byte(want)) {
+		t.Errorf("WriteTTML() =\n%s\nwant it to contain\n%s", b.String(), want)
+	}
+}
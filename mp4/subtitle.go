@@ -0,0 +1,206 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// TimedTextStyle is a StyleRecord of 3GPP timed text (3GPP TS 26.245 5.16).
+type TimedTextStyle struct {
+	StartChar uint16
+	EndChar   uint16
+	FontID    uint16
+	FaceStyle uint8 // bold 1, italic 2, underline 4
+	FontSize  uint8
+	TextColor [4]uint8 // RGBA
+}
+
+// TimedTextConfig is a decoded tx3g sample entry (3GPP TS 26.245 5.16) or
+// QuickTime text sample description.
+//
+//	class TextSampleEntry() extends SampleEntry ('tx3g') {
+//		unsigned int(32) displayFlags;
+//		signed int(8) horizontal-justification;
+//		signed int(8) vertical-justification;
+//		unsigned int(8) background-color-rgba[4];
+//		BoxRecord default-text-box;
+//		StyleRecord default-style;
+//		FontTableBox font-table;
+//	}
+type TimedTextConfig struct {
+	DisplayFlags            uint32
+	HorizontalJustification int8
+	VerticalJustification   int8
+	BackgroundColor         [4]uint8 // RGBA, QuickTime colours are reduced to 8 bits
+	DefaultTextBox          [4]int16 // top, left, bottom, right
+	DefaultStyle            TimedTextStyle
+	Fonts                   map[uint16]string // ftab, by font ID
+	FontName                string            // QuickTime text only
+}
+
+// WebVTTConfig is a decoded wvtt sample entry (ISO/IEC 14496-30 7.5).
+type WebVTTConfig struct {
+	Config      string // vttC, the WebVTT file header
+	SourceLabel string // vlab
+}
+
+// XMLSubtitleConfig is a decoded stpp sample entry (ISO/IEC 14496-30 6.3),
+// carrying TTML or IMSC documents.
+type XMLSubtitleConfig struct {
+	Namespace          string
+	SchemaLocation     string
+	AuxiliaryMIMETypes string
+}
+
+// parseSubtitleSampleEntry decodes the fields after the data reference
+// index of a text, subtitle or caption sample entry.
+func parseSubtitleSampleEntry(entryType string, buf []byte, info *TrackInfo) error {
+	switch entryType {
+	case "tx3g":
+		cfg, err := parseTX3GSampleEntry(buf)
+		if err != nil {
+			return err
+		}
+		info.TimedText = cfg
+	case "text":
+		cfg, err := parseQTTextSampleEntry(buf)
+		if err != nil {
+			return err
+		}
+		info.TimedText = cfg
+	case "wvtt":
+		cfg := &WebVTTConfig{}
+		for len(buf) >= 8 {
+			boxType, body, rest, err := nextBox(buf)
+			if err != nil {
+				return fmt.Errorf("wvtt: %w", err)
+			}
+			switch boxType {
+			case "vttC":
+				cfg.Config = string(body)
+			case "vlab":
+				cfg.SourceLabel = string(body)
+			}
+			buf = rest
+		}
+		info.WebVTT = cfg
+	case "stpp":
+		cfg := &XMLSubtitleConfig{}
+		fields := []*string{&cfg.Namespace, &cfg.SchemaLocation, &cfg.AuxiliaryMIMETypes}
+		for _, f := range fields {
+			i := bytes.IndexByte(buf, 0)
+			if i < 0 {
+				break
+			}
+			*f = string(buf[:i])
+			buf = buf[i+1:]
+		}
+		info.XMLSubtitle = cfg
+	case "c608":
+		// CEA-608 sample entries have no fields
+	}
+	return nil
+}
+
+func parseTX3GSampleEntry(buf []byte) (*TimedTextConfig, error) {
+	if len(buf) < 30 {
+		return nil, fmt.Errorf("tx3g too short: %d bytes", len(buf))
+	}
+	c := &TimedTextConfig{
+		DisplayFlags:            binary.BigEndian.Uint32(buf),
+		HorizontalJustification: int8(buf[4]),
+		VerticalJustification:   int8(buf[5]),
+	}
+	copy(c.BackgroundColor[:], buf[6:10])
+	for i := range c.DefaultTextBox {
+		c.DefaultTextBox[i] = int16(binary.BigEndian.Uint16(buf[10+2*i:]))
+	}
+	c.DefaultStyle = parseTimedTextStyle(buf[18:30])
+
+	buf = buf[30:]
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			return nil, fmt.Errorf("tx3g: %w", err)
+		}
+		if boxType == "ftab" && len(body) >= 2 {
+			c.Fonts = map[uint16]string{}
+			n := int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+			for i := 0; i < n && len(body) >= 3; i++ {
+				id, l := binary.BigEndian.Uint16(body), int(body[2])
+				if len(body) < 3+l {
+					return nil, fmt.Errorf("ftab: font name exceeds box")
+				}
+				c.Fonts[id] = string(body[3 : 3+l])
+				body = body[3+l:]
+			}
+		}
+		buf = rest
+	}
+	return c, nil
+}
+
+func parseTimedTextStyle(b []byte) TimedTextStyle {
+	s := TimedTextStyle{
+		StartChar: binary.BigEndian.Uint16(b),
+		EndChar:   binary.BigEndian.Uint16(b[2:]),
+		FontID:    binary.BigEndian.Uint16(b[4:]),
+		FaceStyle: b[6],
+		FontSize:  b[7],
+	}
+	copy(s.TextColor[:], b[8:12])
+	return s
+}
+
+// parseQTTextSampleEntry decodes a QuickTime text sample description:
+// displayFlags(4), textJustification(4), bgColor(6), defaultTextBox(8),
+// reserved(8), fontNumber(2), fontFace(2), reserved(3), foreColor(6) and
+// a Pascal string font name.
+func parseQTTextSampleEntry(buf []byte) (*TimedTextConfig, error) {
+	if len(buf) < 43 {
+		return nil, fmt.Errorf("text sample description too short: %d bytes", len(buf))
+	}
+	c := &TimedTextConfig{DisplayFlags: binary.BigEndian.Uint32(buf)}
+	// justification 0 left, 1 centre, -1 right, as in tx3g
+	c.HorizontalJustification = int8(int32(binary.BigEndian.Uint32(buf[4:])))
+	for i := 0; i < 3; i++ {
+		c.BackgroundColor[i] = buf[8+2*i] // high byte of the 16 bit component
+	}
+	c.BackgroundColor[3] = 0xff
+	for i := range c.DefaultTextBox {
+		c.DefaultTextBox[i] = int16(binary.BigEndian.Uint16(buf[14+2*i:]))
+	}
+	c.DefaultStyle.FontID = binary.BigEndian.Uint16(buf[30:])
+	c.DefaultStyle.FaceStyle = uint8(binary.BigEndian.Uint16(buf[32:]))
+	for i := 0; i < 3; i++ {
+		c.DefaultStyle.TextColor[i] = buf[37+2*i]
+	}
+	c.DefaultStyle.TextColor[3] = 0xff
+	if len(buf) > 43 {
+		n := int(buf[43])
+		if len(buf) >= 44+n {
+			c.FontName = string(buf[44 : 44+n])
+		}
+	}
+	return c, nil
+}
+
+// SubtitleFormatName names the subtitle sample entry types.
+func SubtitleFormatName(fourcc string) string {
+	switch fourcc {
+	case "tx3g":
+		return "3GPP Timed Text"
+	case "text":
+		return "QuickTime Text"
+	case "wvtt":
+		return "WebVTT"
+	case "stpp":
+		return "TTML"
+	case "c608":
+		return "CEA-608"
+	default:
+		return fourcc
+	}
+}
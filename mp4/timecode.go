@@ -0,0 +1,165 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// tmcd flags
+const (
+	TimecodeDropFrame   = 0x0001
+	TimecodeMax24Hour   = 0x0002
+	TimecodeNegativeOK  = 0x0004
+	TimecodeCounterMode = 0x0008
+)
+
+// TimecodeConfig is a decoded tmcd sample description (QuickTime File
+// Format, Timecode Sample Description) with the start of its first sample.
+//
+//	reserved         4 bytes
+//	flags            4 bytes
+//	time scale       4 bytes
+//	frame duration   4 bytes
+//	number of frames 1 byte
+//	reserved         1 byte
+//	source reference udta/name (optional)
+type TimecodeConfig struct {
+	Flags          uint32
+	Timescale      uint32
+	FrameDuration  uint32
+	NumberOfFrames uint8 // frames per second, rounded up for drop frame
+	ReelName       string
+	StartFrame     uint32 // frame number in the first sample
+	HasStart       bool
+	Start          string // SMPTE timecode of StartFrame, e.g. "01:00:00;00"
+}
+
+// DropFrame reports whether the timecode uses drop frame counting.
+func (c *TimecodeConfig) DropFrame() bool {
+	return c.Flags&TimecodeDropFrame != 0
+}
+
+// Wraps24Hours reports whether the timecode wraps after 24 hours.
+func (c *TimecodeConfig) Wraps24Hours() bool {
+	return c.Flags&TimecodeMax24Hour != 0
+}
+
+// CounterMode reports whether samples hold a tick counter instead of a
+// frame number.
+func (c *TimecodeConfig) CounterMode() bool {
+	return c.Flags&TimecodeCounterMode != 0
+}
+
+// FrameRate returns timescale / frame duration, e.g. 29.97.
+func (c *TimecodeConfig) FrameRate() float64 {
+	if c.FrameDuration == 0 {
+		return 0
+	}
+	return float64(c.Timescale) / float64(c.FrameDuration)
+}
+
+func parseTimecodeSampleEntry(buf []byte) (*TimecodeConfig, error) {
+	if len(buf) < 17 {
+		return nil, fmt.Errorf("tmcd too short: %d bytes", len(buf))
+	}
+	c := &TimecodeConfig{
+		Flags:          binary.BigEndian.Uint32(buf[4:]),
+		Timescale:      binary.BigEndian.Uint32(buf[8:]),
+		FrameDuration:  binary.BigEndian.Uint32(buf[12:]),
+		NumberOfFrames: buf[16],
+	}
+	if len(buf) > 18 {
+		c.ReelName = timecodeReelName(buf[18:])
+	}
+	return c, nil
+}
+
+// timecodeReelName finds the name box of the source reference. Writers
+// store it inside a udta box or directly in the sample entry.
+func timecodeReelName(buf []byte) string {
+	for len(buf) >= 8 {
+		boxType, body, rest, err := nextBox(buf)
+		if err != nil {
+			return ""
+		}
+		switch boxType {
+		case "udta":
+			if name := timecodeReelName(body); name != "" {
+				return name
+			}
+		case "name":
+			// QuickTime user data text: 16 bit length, 16 bit language
+			if len(body) >= 4 {
+				n := int(binary.BigEndian.Uint16(body))
+				if n > len(body)-4 {
+					n = len(body) - 4
+				}
+				return string(body[4 : 4+n])
+			}
+		}
+		buf = rest
+	}
+	return ""
+}
+
+// FormatTimecode formats a frame number as SMPTE timecode hh:mm:ss:ff, or
+// hh:mm:ss;ff with drop frame counting. fps is the nominal frame rate.
+func FormatTimecode(frame uint32, fps int, dropFrame, wrap24 bool) string {
+	if fps <= 0 {
+		return ""
+	}
+	n := int64(frame)
+	sep := ":"
+	if dropFrame {
+		// frame numbers 0 and 1 (0-3 at 60 fps) are skipped at the start
+		// of every minute except each tenth
+		drop := int64(fps / 15)
+		perMinute := int64(fps)*60 - drop
+		perTenMinutes := perMinute*10 + drop
+		d, m := n/perTenMinutes, n%perTenMinutes
+		n += 9 * drop * d
+		if m > drop {
+			n += drop * ((m - drop) / perMinute)
+		}
+		sep = ";"
+	}
+	f := int64(fps)
+	hours := n / (f * 3600)
+	if wrap24 {
+		hours %= 24
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%02d", hours, n/(f*60)%60, n/f%60, sep, n%f)
+}
+
+// decodeTimecodes reads the first sample of each timecode track, which
+// holds the frame number of the start of the track. It runs at the end of
+// Parse while the file is still open.
+func (p *MP4Parser) decodeTimecodes() {
+	for i := range p.tracks {
+		t := &p.tracks[i]
+		tc := t.Timecode
+		if tc == nil {
+			continue
+		}
+		samples, err := p.GetSamples(t.TrackID)
+		if err != nil || len(samples) == 0 {
+			if err != nil {
+				t.Warnings = append(t.Warnings, fmt.Sprintf("timecode samples: %v", err))
+			}
+			continue
+		}
+		var buf [4]byte
+		if n, err := p.file.ReadAt(buf[:], samples[0].Offset); n < len(buf) {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			t.Warnings = append(t.Warnings, fmt.Sprintf("read timecode sample: %v", err))
+			continue
+		}
+		tc.StartFrame, tc.HasStart = binary.BigEndian.Uint32(buf[:]), true
+		if !tc.CounterMode() {
+			tc.Start = FormatTimecode(tc.StartFrame, int(tc.NumberOfFrames), tc.DropFrame(), tc.Wraps24Hours())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// TrackReference is one typed reference box of a tref box
+// (ISO/IEC 14496-12 8.3.3).
+//
+//	aligned(8) class TrackReferenceTypeBox (unsigned int(32) reference_type) extends Box(reference_type) {
+//		unsigned int(32) track_IDs[];
+//	}
+type TrackReference struct {
+	Type     string
+	TrackIDs []uint32
+}
+
+// TrackRelation is an edge of the track reference graph: track From
+// references track To.
+type TrackRelation struct {
+	From uint32
+	To   uint32
+	Type string
+}
+
+func parseTrackReferences(buf []byte) ([]TrackReference, error) {
+	var refs []TrackReference
+	for len(buf) >= 8 {
+		refType, body, rest, err := nextBox(buf)
+		if err != nil {
+			return nil, fmt.Errorf("tref: %w", err)
+		}
+		ref := TrackReference{Type: refType}
+		for ; len(body) >= 4; body = body[4:] {
+			ref.TrackIDs = append(ref.TrackIDs, binary.BigEndian.Uint32(body))
+		}
+		refs = append(refs, ref)
+		buf = rest
+	}
+	return refs, nil
+}
+
+// TrackReferenceTypeName describes a tref reference type.
+func TrackReferenceTypeName(refType string) string {
+	switch refType {
+	case "chap":
+		return "chapter track"
+	case "tmcd":
+		return "timecode track"
+	case "hint":
+		return "hinted media track"
+	case "cdsc":
+		return "described track"
+	case "vdep":
+		return "auxiliary depth video"
+	case "vplx":
+		return "auxiliary parallax video"
+	case "sync":
+		return "synchronization track"
+	case "subt":
+		return "subtitle track"
+	case "font":
+		return "font track"
+	case "forc":
+		return "forced subtitle track"
+	case "hind":
+		return "hint dependency"
+	case "ipir":
+		return "IPI information"
+	case "mpod":
+		return "object descriptor elementary stream"
+	case "sbas":
+		return "base layer track"
+	case "scal":
+		return "extractor source track"
+	case "auxl":
+		return "auxiliary media for"
+	case "adda":
+		return "additional audio"
+	case "adrc":
+		return "audio DRC metadata"
+	default:
+		return refType
+	}
+}
+
+// checkTrackReferences warns about references to tracks that do not exist.
+func checkTrackReferences(tracks []TrackInfo) {
+	ids := map[uint32]bool{}
+	for _, t := range tracks {
+		ids[t.TrackID] = true
+	}
+	for i := range tracks {
+		t := &tracks[i]
+		for _, ref := range t.References {
+			for _, id := range ref.TrackIDs {
+				// track ID 0 is allowed as an empty entry
+				if id != 0 && !ids[id] {
+					t.Warnings = append(t.Warnings, fmt.Sprintf("tref %s references missing track %d", ref.Type, id))
+				}
+			}
+		}
+	}
+}
@@ -11,6 +11,7 @@ type MP4Metadata struct {
 	FPS              float64       // FPS
 	VideoCodec       string        // Video Codec
 	AudioCodec       string        // Audio Codec
+	AudioCodecName   string        // Descriptive Audio Codec Name, e.g. "HE-AAC"
 	CreationTime     time.Time     // Creation Time
 	ModificationTime time.Time     // Modification Time
 	HasVideo         bool          // Whether the file contains video tracks
@@ -28,27 +29,103 @@ type MP4Metadata struct {
 	AudioSampleSize  uint16        // Audio Sample Size
 	VideoProfile     string        // Video Encoding Configuration
 	VideoLevel       byte          // Video Encoding Level
+	VideoHDRFormat   string        // Video Dynamic Range, e.g. "HDR10"
+	Timecode         string        // Start Timecode of the first tmcd track, e.g. "01:00:00;00"
+	ReelName         string        // Source Reel Name of the timecode
+}
+
+// AlternateGroup is a set of tracks sharing a tkhd alternate_group, of which
+// only one should be played at a time, e.g. audio languages.
+type AlternateGroup struct {
+	ID          int16
+	HandlerType string // handler of the first track in the group
+	TrackIDs    []uint32
 }
 
 type TrackInfo struct {
-	TrackID       uint32
-	HandlerType   string
-	Width         uint32
-	Height        uint32
-	Codec         string
-	Duration      uint64
-	Timescale     uint32
-	SampleCount   uint32
-	FrameCount    uint32
-	Language      string
-	Bitrate       uint32
-	SampleRate    uint32
-	Channels      uint16
-	SampleSize    uint16
-	AVCProfile    byte
-	AVCLevel      byte
-	AudioCodecTag uint32
-	VideoCodecTag uint32
-	SttsBox       *sttsBox
-	CttsBox       *cttsBox
+	TrackID           uint32
+	HandlerType       string
+	Width             uint32
+	Height            uint32
+	Codec             string
+	CodecName         string // descriptive codec name, e.g. "AAC-LC"
+	Duration          uint64
+	Timescale         uint32
+	SampleCount       uint32
+	FrameCount        uint32
+	Language          string
+	Bitrate           uint32
+	MaxBitrate        uint32
+	SampleRate        uint32
+	Channels          uint16
+	SampleSize        uint16
+	SamplesPerPacket  uint32 // frames per packet, QuickTime sound description v1/v2
+	BytesPerFrame     uint32 // QuickTime sound description v1
+	BytesPerPacket    uint32 // constBytesPerAudioPacket, QuickTime sound description v2
+	AudioFormatFlags  uint32 // LPCM flags of a QuickTime sound description v2
+	AVCProfile        byte
+	AVCLevel          byte
+	Profile           string // human readable profile, e.g. "High"
+	Level             string // human readable level, e.g. "4.1"
+	AVCConfig         *AVCDecoderConfig
+	H264SPS           *H264SPS   // first SPS of avcC
+	H264PPS           []*H264PPS // PPS of avcC
+	HEVCConfig        *HEVCDecoderConfig
+	HEVCVPS           *HEVCVPS // first VPS of hvcC
+	HEVCSPS           *HEVCSPS // first SPS of hvcC
+	AV1Config         *AV1CodecConfig
+	AV1SequenceHeader *AV1SequenceHeader // sequence header OBU of av1C
+	VPConfig          *VPCodecConfig
+	DolbyVision       *DolbyVisionConfig
+	Colr              *ColourBox
+	MasteringDisplay  *MasteringDisplay
+	ContentLightLevel *ContentLightLevel
+	PixelAspect       *PixelAspectRatio
+	CleanAperture     *CleanAperture
+	FieldOrder        *FieldInfo
+	DisplayWidth      uint32 // after clean aperture and pixel aspect ratio
+	DisplayHeight     uint32
+	DisplayAspect     float64 // display aspect ratio, width / height
+	Header            TKHDBox
+	References        []TrackReference // tref
+	EditList          []EditListEntry  // edts/elst
+	Matrix            Matrix           // tkhd transformation matrix
+	DisplayMatrix     Matrix           // tkhd matrix combined with the mvhd matrix
+	Orientation       Orientation
+	HDRFormat         string // e.g. "SDR", "HDR10", "HLG", "Dolby Vision 8.1 (HDR10 compatible)"
+	VVCConfig         *VVCDecoderConfig
+	CodedWidth        uint32 // decoded picture size before cropping
+	CodedHeight       uint32
+	ChromaFormat      string // e.g. "4:2:0"
+	BitDepth          uint8  // luma bit depth
+	Color             *ColorInfo
+	ESDescriptor      *ESDescriptor
+	Opus              *OpusConfig
+	FLAC              *FLACConfig
+	ALAC              *ALACConfig
+	AC3               *AC3Config
+	EC3               *EC3Config
+	AC4               *AC4Config
+	MPEGH             *MPEGHConfig
+	DTS               *DTSConfig
+	DTSUHD            *DTSUHDConfig
+	Chnl              *ChannelLayoutConfig
+	Chan              *QTChannelLayout // QuickTime chan
+	ChannelLayout     string           // space separated speakers, e.g. "L R C LFE Ls Rs"
+	AudioObjects      uint8            // object count of an object based chnl stream
+	Atmos             bool             // Dolby Atmos (E-AC-3 JOC or object based AC-4)
+	TimedText         *TimedTextConfig // tx3g or QuickTime text
+	WebVTT            *WebVTTConfig
+	XMLSubtitle       *XMLSubtitleConfig // stpp
+	Cues              []Cue              // decoded subtitle samples
+	Timecode          *TimecodeConfig    // tmcd
+	Tags              *Tags              // trak/meta and trak/udta/meta
+	Warnings          []string           // inconsistencies found while parsing
+	AudioCodecTag     uint32
+	VideoCodecTag     uint32
+	SttsBox           *sttsBox
+	CttsBox           *cttsBox
+	StszBox           *stszBox
+	StscBox           *stscBox
+	StcoBox           *stcoBox
 }
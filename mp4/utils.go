@@ -2,6 +2,7 @@ package mp4
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -36,7 +37,21 @@ func PrintMetadata(metadata *MP4Metadata) {
 	fmt.Printf("Resolution: %d × %d\n", metadata.Width, metadata.Height)
 	fmt.Printf("FPS: %.2f fps\n", metadata.FPS)
 	fmt.Printf("Video Codec: %s\n", metadata.VideoCodec)
-	fmt.Printf("Audio Codec: %s\n", metadata.AudioCodec)
+	if metadata.VideoProfile != "" {
+		fmt.Printf("Video Profile: %s\n", metadata.VideoProfile)
+	}
+	if metadata.VideoHDRFormat != "" {
+		fmt.Printf("Video Dynamic Range: %s\n", metadata.VideoHDRFormat)
+	}
+	if metadata.AudioCodecName != "" {
+		fmt.Printf("Audio Codec: %s (%s)\n", metadata.AudioCodec, metadata.AudioCodecName)
+	} else {
+		fmt.Printf("Audio Codec: %s\n", metadata.AudioCodec)
+	}
+
+	if metadata.AudioSampleRate > 0 {
+		fmt.Printf("Audio Format: %d Hz, %d channels, %d bit\n", metadata.AudioSampleRate, metadata.AudioChannels, metadata.AudioSampleSize)
+	}
 
 	if metadata.HasVideo && metadata.HasAudio {
 		fmt.Println("Tracks: video + audio")
@@ -60,4 +75,205 @@ func PrintMetadata(metadata *MP4Metadata) {
 	if metadata.Rotation != 0 {
 		fmt.Printf("Rotation: %d°\n", metadata.Rotation)
 	}
+	if metadata.Timecode != "" {
+		if metadata.ReelName != "" {
+			fmt.Printf("Timecode: %s (reel %s)\n", metadata.Timecode, metadata.ReelName)
+		} else {
+			fmt.Printf("Timecode: %s\n", metadata.Timecode)
+		}
+	}
+}
+
+func PrintTags(tags *Tags) {
+	fmt.Println("=== tags ===")
+	fields := []struct {
+		name, value string
+	}{
+		{"Title", tags.Title}, {"Artist", tags.Artist}, {"Album Artist", tags.AlbumArtist},
+		{"Album", tags.Album}, {"Genre", tags.Genre}, {"Composer", tags.Composer},
+		{"Date", tags.Date}, {"Grouping", tags.Grouping}, {"Comment", tags.Comment},
+		{"Description", tags.Description}, {"Copyright", tags.Copyright},
+		{"Encoder", tags.Encoder}, {"Encoded By", tags.EncodedBy},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			fmt.Printf("%s: %s\n", f.name, f.value)
+		}
+	}
+	if tags.TrackNumber != 0 {
+		fmt.Printf("Track: %d/%d\n", tags.TrackNumber, tags.TrackTotal)
+	}
+	if tags.DiscNumber != 0 {
+		fmt.Printf("Disc: %d/%d\n", tags.DiscNumber, tags.DiscTotal)
+	}
+	if tags.Tempo != 0 {
+		fmt.Printf("Tempo: %d bpm\n", tags.Tempo)
+	}
+	if tags.Compilation {
+		fmt.Println("Compilation: yes")
+	}
+	for _, c := range tags.Cover {
+		fmt.Printf("Cover: %s, %d bytes\n", c.Format, len(c.Data))
+	}
+	if device := tags.Device(); device != "" {
+		fmt.Printf("Device: %s\n", device)
+	}
+	if software := tags.Software(); software != "" {
+		fmt.Printf("Software: %s\n", software)
+	}
+	if t, ok := tags.CaptureTime(); ok {
+		fmt.Printf("Capture Time: %s\n", t.Format("2006-01-02 15:04:05 -0700"))
+	}
+	if loc, ok := tags.Location(); ok {
+		fmt.Printf("Location: %s\n", loc)
+	}
+	if fps, ok := tags.CaptureFPS(); ok {
+		fmt.Printf("Capture FPS: %.2f\n", fps)
+	}
+	for _, item := range tags.Items {
+		switch {
+		case strings.HasPrefix(item.Key, "----:"):
+			fmt.Printf("%s: %s\n", strings.TrimPrefix(item.Key, "----:"), item)
+		case item.Namespace != "":
+			fmt.Printf("%s: %s\n", item.Key, item)
+		}
+	}
+}
+
+func PrintEvents(events []EventMessage) {
+	fmt.Println("=== timed events ===")
+	if len(events) == 0 {
+		fmt.Println("no emsg boxes found")
+		return
+	}
+	for i, e := range events {
+		timeLabel := "time"
+		if e.Version == 0 {
+			timeLabel = "time delta"
+		}
+		fmt.Printf("\nevent %d (emsg v%d @ %d):\n", i+1, e.Version, e.Offset)
+		fmt.Printf("  Scheme: %s\n", e.SchemeIDURI)
+		if e.Value != "" {
+			fmt.Printf("  Value: %s\n", e.Value)
+		}
+		fmt.Printf("  ID: %d\n", e.ID)
+		fmt.Printf("  Timescale: %d\n", e.Timescale)
+		fmt.Printf("  Presentation %s: %s\n", timeLabel, FormatDuration(e.Time()))
+		if d, ok := e.Duration(); ok {
+			fmt.Printf("  Duration: %s\n", FormatDuration(d))
+		} else {
+			fmt.Println("  Duration: unknown")
+		}
+		fmt.Printf("  Message: %d bytes\n", len(e.MessageData))
+
+		if e.Err != nil {
+			fmt.Printf("  Payload error: %v\n", e.Err)
+		}
+		if e.SCTE35 != nil {
+			printSpliceInfo(e.SCTE35)
+		}
+		if e.ID3 != nil {
+			fmt.Printf("  ID3v2.%d:\n", e.ID3.Version)
+			for _, f := range e.ID3.Frames {
+				switch {
+				case f.Owner != "":
+					fmt.Printf("    %s: %s (%d bytes)\n", f.ID, f.Owner, len(f.Data))
+				case f.Description != "":
+					fmt.Printf("    %s: %s = %s\n", f.ID, f.Description, f.Text)
+				case f.Text != "":
+					fmt.Printf("    %s: %s\n", f.ID, f.Text)
+				default:
+					fmt.Printf("    %s: %d bytes\n", f.ID, len(f.Data))
+				}
+			}
+		}
+	}
+}
+
+func printSpliceInfo(s *SpliceInfoSection) {
+	fmt.Printf("  SCTE-35 %s", SpliceCommandName(s.SpliceCommandType))
+	if s.PTSAdjustment > 0 {
+		fmt.Printf(", pts_adjustment %d", s.PTSAdjustment)
+	}
+	fmt.Println()
+	if s.EncryptedPacket {
+		fmt.Println("    encrypted")
+		return
+	}
+	if si := s.SpliceInsert; si != nil {
+		fmt.Printf("    Event ID: %d", si.EventID)
+		if si.EventCancel {
+			fmt.Println(" (cancelled)")
+		} else {
+			fmt.Printf(", out_of_network: %t, immediate: %t\n", si.OutOfNetwork, si.SpliceImmediate)
+		}
+		if si.SpliceTime != nil && si.SpliceTime.TimeSpecified {
+			fmt.Printf("    Splice time: %s (pts %d)\n", FormatDuration(PTSToDuration(si.SpliceTime.PTSTime)), si.SpliceTime.PTSTime)
+		}
+		if si.BreakDuration != nil {
+			fmt.Printf("    Break duration: %s, auto_return: %t\n", FormatDuration(PTSToDuration(si.BreakDuration.Duration)), si.BreakDuration.AutoReturn)
+		}
+	}
+	if t := s.TimeSignal; t != nil && t.TimeSpecified {
+		fmt.Printf("    Splice time: %s (pts %d)\n", FormatDuration(PTSToDuration(t.PTSTime)), t.PTSTime)
+	}
+	for _, d := range s.Descriptors {
+		sd := d.Segmentation
+		if sd == nil {
+			fmt.Printf("    Descriptor tag 0x%02x: %d bytes\n", d.Tag, len(d.Data))
+			continue
+		}
+		fmt.Printf("    Segmentation: %s, event ID %d", SegmentationTypeName(sd.TypeID), sd.EventID)
+		if sd.EventCancel {
+			fmt.Println(" (cancelled)")
+			continue
+		}
+		fmt.Printf(", segment %d/%d", sd.SegmentNum, sd.SegmentsExpected)
+		if sd.HasDuration {
+			fmt.Printf(", duration %s", FormatDuration(PTSToDuration(sd.Duration)))
+		}
+		if len(sd.UPID) > 0 {
+			fmt.Printf(", upid type 0x%02x %x", sd.UPIDType, sd.UPID)
+		}
+		fmt.Println()
+	}
+}
+
+func PrintChunks(segments []SegmentInfo, prfts []ProducerReferenceTime) {
+	fmt.Println("=== low-latency chunks ===")
+	if len(prfts) > 0 {
+		fmt.Printf("prft boxes: %d\n", len(prfts))
+		for _, t := range prfts {
+			fmt.Printf("  track %d: %s <-> media time %d (%s)\n",
+				t.ReferenceTrackID, t.Time().Format("2006-01-02 15:04:05.000"), t.MediaTime, t.FlagsName())
+		}
+	}
+	if len(segments) == 0 {
+		fmt.Println("no movie fragments found")
+		return
+	}
+	for _, seg := range segments {
+		fmt.Printf("\nsegment %d: %d chunks, %d samples, duration %s\n",
+			seg.Index+1, len(seg.Chunks), seg.SampleCount, FormatDuration(seg.Duration))
+		for _, c := range seg.Chunks {
+			fmt.Printf("  chunk seq %d track %d: %d samples, %s",
+				c.SequenceNumber, c.TrackID, c.SampleCount, FormatFileSize(int64(c.Bytes)))
+			if c.Timescale > 0 {
+				fmt.Printf(", duration %.3fs", c.Duration.Seconds())
+			} else {
+				fmt.Printf(", duration %d ticks", c.DurationTicks)
+			}
+			if c.StartsWithSync {
+				fmt.Print(", sync")
+			}
+			if c.HasLatency {
+				if c.LatencyRelative {
+					fmt.Printf(", latency %+.3fs (relative to first prft)", c.Latency.Seconds())
+				} else {
+					fmt.Printf(", latency %.3fs", c.Latency.Seconds())
+				}
+			}
+			fmt.Println()
+		}
+	}
 }
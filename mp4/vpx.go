@@ -0,0 +1,162 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// VPCodecConfig is a decoded vpcC box (VP Codec ISO Media File Format
+// Binding, version 1.0).
+//
+//	aligned (8) class VPCodecConfigurationRecord {
+//		unsigned int (8)  profile;
+//		unsigned int (8)  level;
+//		unsigned int (4)  bitDepth;
+//		unsigned int (3)  chromaSubsampling;
+//		unsigned int (1)  videoFullRangeFlag;
+//		unsigned int (8)  colourPrimaries;
+//		unsigned int (8)  transferCharacteristics;
+//		unsigned int (8)  matrixCoefficients;
+//		unsigned int (16) codecIntializationDataSize;
+//		unsigned int (8)[] codecIntializationData;
+//	}
+//
+// Version 0 boxes from the earlier draft pack colour information as
+// bitDepth(4) colorSpace(4) chromaSubsampling(4) transferFunction(3)
+// videoFullRangeFlag(1); they are mapped onto the same fields.
+type VPCodecConfig struct {
+	Version                 byte
+	Profile                 byte
+	Level                   byte
+	BitDepth                byte
+	ChromaSubsampling       byte // 0 4:2:0 vertical, 1 4:2:0 colocated, 2 4:2:2, 3 4:4:4, 4 4:4:0 (v0 only)
+	VideoFullRange          bool
+	ColourPrimaries         byte
+	TransferCharacteristics byte
+	MatrixCoefficients      byte
+	CodecInitializationData []byte
+}
+
+// vpcC version 0 colorSpace values mapped to matrix coefficients and, for
+// the BT.2020 and sRGB spaces, colour primaries.
+var vpcColorSpaces = [8]struct{ primaries, matrix byte }{
+	{2, 2},  // unknown
+	{6, 6},  // BT.601
+	{1, 1},  // BT.709
+	{6, 6},  // SMPTE-170
+	{7, 7},  // SMPTE-240
+	{9, 9},  // BT.2020 non-constant luminance
+	{9, 10}, // BT.2020 constant luminance
+	{1, 0},  // sRGB
+}
+
+func parseVPCodecConfig(buf []byte) (*VPCodecConfig, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("vpcC too short: %d bytes", len(buf))
+	}
+	c := &VPCodecConfig{Version: buf[0]}
+	body := buf[4:]
+
+	switch c.Version {
+	case 0:
+		if len(body) < 6 {
+			return nil, fmt.Errorf("vpcC v0 too short: %d bytes", len(buf))
+		}
+		c.Profile = body[0]
+		c.Level = body[1]
+		c.BitDepth = body[2] >> 4
+		cs := vpcColorSpaces[0]
+		if i := body[2] & 0x0f; int(i) < len(vpcColorSpaces) {
+			cs = vpcColorSpaces[i]
+		}
+		c.ColourPrimaries, c.MatrixCoefficients = cs.primaries, cs.matrix
+		// 4:2:0, 4:2:2, 4:4:0 and 4:4:4 in the draft; 4:4:0 has no
+		// version 1 code and is stored as 4
+		switch body[3] >> 4 {
+		case 0:
+			c.ChromaSubsampling = 0
+		case 1:
+			c.ChromaSubsampling = 2
+		case 2:
+			c.ChromaSubsampling = 4
+		default:
+			c.ChromaSubsampling = 3
+		}
+		switch body[3] >> 1 & 0x07 {
+		case 0:
+			c.TransferCharacteristics = 1
+		case 1:
+			c.TransferCharacteristics = 16
+		default:
+			c.TransferCharacteristics = 2
+		}
+		c.VideoFullRange = body[3]&0x01 != 0
+		body = body[4:]
+	case 1:
+		if len(body) < 8 {
+			return nil, fmt.Errorf("vpcC too short: %d bytes", len(buf))
+		}
+		c.Profile = body[0]
+		c.Level = body[1]
+		c.BitDepth = body[2] >> 4
+		c.ChromaSubsampling = body[2] >> 1 & 0x07
+		c.VideoFullRange = body[2]&0x01 != 0
+		c.ColourPrimaries = body[3]
+		c.TransferCharacteristics = body[4]
+		c.MatrixCoefficients = body[5]
+		body = body[6:]
+	default:
+		return nil, fmt.Errorf("vpcC: unsupported version %d", c.Version)
+	}
+
+	n := int(binary.BigEndian.Uint16(body))
+	if 2+n > len(body) {
+		return nil, fmt.Errorf("vpcC: codec initialization data size %d exceeds box", n)
+	}
+	c.CodecInitializationData = body[2 : 2+n]
+	return c, nil
+}
+
+// applyVPCodecConfig fills the track fields derived from vpcC.
+func applyVPCodecConfig(info *TrackInfo, cfg *VPCodecConfig) {
+	info.VPConfig = cfg
+	info.Profile = fmt.Sprintf("Profile %d", cfg.Profile)
+	if cfg.Level > 0 {
+		info.Level = VPLevelName(cfg.Level)
+	}
+	info.ChromaFormat = VPChromaSubsamplingName(cfg.ChromaSubsampling)
+	info.BitDepth = cfg.BitDepth
+	info.Color = &ColorInfo{
+		Primaries: uint16(cfg.ColourPrimaries),
+		Transfer:  uint16(cfg.TransferCharacteristics),
+		Matrix:    uint16(cfg.MatrixCoefficients),
+		FullRange: cfg.VideoFullRange,
+		Source:    "vpcC",
+	}
+}
+
+// VPLevelName formats a VP9 level, e.g. 41 as "4.1".
+func VPLevelName(level byte) string {
+	if level%10 == 0 {
+		return fmt.Sprintf("%d", level/10)
+	}
+	return fmt.Sprintf("%d.%d", level/10, level%10)
+}
+
+// VPChromaSubsamplingName names a vpcC chromaSubsampling value.
+func VPChromaSubsamplingName(v byte) string {
+	switch v {
+	case 0:
+		return "4:2:0 (vertical)"
+	case 1:
+		return "4:2:0 (colocated)"
+	case 2:
+		return "4:2:2"
+	case 3:
+		return "4:4:4"
+	case 4:
+		return "4:4:0"
+	default:
+		return fmt.Sprintf("unknown(%d)", v)
+	}
+}
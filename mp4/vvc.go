@@ -0,0 +1,278 @@
+package mp4
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VVC NAL unit types that may appear in vvcC arrays
+const (
+	vvcNALOPI = 12
+	vvcNALDCI = 13
+	vvcNALVPS = 14
+	vvcNALSPS = 15
+	vvcNALPPS = 16
+)
+
+// VVCDecoderConfig is a decoded vvcC box (ISO/IEC 14496-15 11.2.4.2).
+//
+//	aligned(8) class VvcDecoderConfigurationRecord {
+//		bit(5) reserved = '11111'b;
+//		unsigned int(2) LengthSizeMinusOne;
+//		unsigned int(1) ptl_present_flag;
+//		if (ptl_present_flag) {
+//			unsigned int(9) ols_idx;
+//			unsigned int(3) num_sublayers;
+//			unsigned int(2) constant_frame_rate;
+//			unsigned int(2) chroma_format_idc;
+//			unsigned int(3) bit_depth_minus8;
+//			bit(5) reserved = '11111'b;
+//			VvcPTLRecord(num_sublayers) native_ptl;
+//			unsigned int(16) max_picture_width;
+//			unsigned int(16) max_picture_height;
+//			unsigned int(16) avg_frame_rate;
+//		}
+//		unsigned int(8) num_of_arrays;
+//		for (j=0; j < num_of_arrays; j++) {
+//			unsigned int(1) array_completeness;
+//			bit(2) reserved = 0;
+//			unsigned int(5) NAL_unit_type;
+//			if (NAL_unit_type != DCI_NUT && NAL_unit_type != OPI_NUT)
+//				unsigned int(16) num_nalus;
+//			for (i=0; i< num_nalus; i++) {
+//				unsigned int(16) nal_unit_length;
+//				bit(8*nal_unit_length) nal_unit;
+//			}
+//		}
+//	}
+type VVCDecoderConfig struct {
+	NALULengthSize    int
+	PTLPresent        bool
+	OLSIdx            uint16
+	NumSublayers      uint8
+	ConstantFrameRate uint8
+	ChromaFormatIdc   uint8
+	BitDepth          uint8
+	PTL               VVCProfileTierLevel
+	MaxPictureWidth   uint16
+	MaxPictureHeight  uint16
+	AvgFrameRate      uint16 // frames per 256 seconds
+	Arrays            []VVCNALArray
+}
+
+// VVCProfileTierLevel is a decoded VvcPTLRecord.
+//
+//	aligned(8) class VvcPTLRecord(num_sublayers) {
+//		bit(2) reserved = 0;
+//		unsigned int(6) num_bytes_constraint_info;
+//		unsigned int(7) general_profile_idc;
+//		unsigned int(1) general_tier_flag;
+//		unsigned int(8) general_level_idc;
+//		unsigned int(1) ptl_frame_only_constraint_flag;
+//		unsigned int(1) ptl_multi_layer_enabled_flag;
+//		unsigned int(8*num_bytes_constraint_info - 2) general_constraint_info;
+//		for (i=num_sublayers - 2; i >= 0; i--)
+//			unsigned int(1) ptl_sublayer_level_present_flag[i];
+//		for (j=num_sublayers; j<=8 && num_sublayers > 1; j++)
+//			bit(1) ptl_reserved_zero_bit = 0;
+//		for (i=num_sublayers-2; i >= 0; i--)
+//			if (ptl_sublayer_level_present_flag[i])
+//				unsigned int(8) sublayer_level_idc[i];
+//		unsigned int(8) ptl_num_sub_profiles;
+//		for (j=0; j < ptl_num_sub_profiles; j++)
+//			unsigned int(32) general_sub_profile_idc[j];
+//	}
+type VVCProfileTierLevel struct {
+	ProfileIdc          uint8
+	TierFlag            bool
+	LevelIdc            uint8
+	FrameOnlyConstraint bool
+	MultiLayerEnabled   bool
+	ConstraintInfo      []byte  // general_constraint_info with the two leading flags
+	SublayerLevelIdc    []uint8 // by sub-layer, 0 when not present
+	SubProfileIdc       []uint32
+}
+
+// VVCNALArray is one parameter set array of a vvcC box.
+type VVCNALArray struct {
+	Complete    bool
+	NALUnitType byte
+	NALUnits    [][]byte
+}
+
+// NALUnits returns the NAL units of the given type from all arrays.
+func (c *VVCDecoderConfig) NALUnits(nalType byte) [][]byte {
+	var units [][]byte
+	for _, a := range c.Arrays {
+		if a.NALUnitType == nalType {
+			units = append(units, a.NALUnits...)
+		}
+	}
+	return units
+}
+
+func parseVVCDecoderConfig(buf []byte) (*VVCDecoderConfig, error) {
+	if len(buf) < 5 {
+		return nil, fmt.Errorf("vvcC too short: %d bytes", len(buf))
+	}
+	if buf[0] != 0 {
+		return nil, fmt.Errorf("vvcC: unsupported version %d", buf[0])
+	}
+
+	br := newBitReader(buf[4:])
+	c := &VVCDecoderConfig{}
+	br.skipBits(5)
+	c.NALULengthSize = int(br.readBits(2)) + 1
+	c.PTLPresent = br.readFlag()
+	if c.PTLPresent {
+		c.OLSIdx = uint16(br.readBits(9))
+		c.NumSublayers = uint8(br.readBits(3))
+		c.ConstantFrameRate = uint8(br.readBits(2))
+		c.ChromaFormatIdc = uint8(br.readBits(2))
+		c.BitDepth = uint8(br.readBits(3)) + 8
+		br.skipBits(5)
+		c.PTL = parseVVCPTLRecord(br, int(c.NumSublayers))
+		c.MaxPictureWidth = uint16(br.readBits(16))
+		c.MaxPictureHeight = uint16(br.readBits(16))
+		c.AvgFrameRate = uint16(br.readBits(16))
+	}
+
+	numArrays := int(br.readBits(8))
+	for i := 0; i < numArrays && br.err == nil; i++ {
+		a := VVCNALArray{Complete: br.readFlag()}
+		br.skipBits(2)
+		a.NALUnitType = byte(br.readBits(5))
+		numNalus := 1
+		if a.NALUnitType != vvcNALDCI && a.NALUnitType != vvcNALOPI {
+			numNalus = int(br.readBits(16))
+		}
+		for j := 0; j < numNalus && br.err == nil; j++ {
+			n := int(br.readBits(16))
+			if n > br.bitsLeft()/8 {
+				return nil, fmt.Errorf("vvcC: NAL unit length %d exceeds box", n)
+			}
+			a.NALUnits = append(a.NALUnits, br.readBytes(n))
+		}
+		c.Arrays = append(c.Arrays, a)
+	}
+	if br.err != nil {
+		return nil, fmt.Errorf("vvcC: %w", br.err)
+	}
+	return c, nil
+}
+
+func parseVVCPTLRecord(br *bitReader, numSublayers int) VVCProfileTierLevel {
+	var ptl VVCProfileTierLevel
+	br.skipBits(2)
+	numBytesConstraintInfo := int(br.readBits(6))
+	ptl.ProfileIdc = uint8(br.readBits(7))
+	ptl.TierFlag = br.readFlag()
+	ptl.LevelIdc = uint8(br.readBits(8))
+	if numBytesConstraintInfo == 0 {
+		br.err = errors.New("vvcC: num_bytes_constraint_info is 0")
+		return ptl
+	}
+	// the frame only and multi layer flags are the first two bits of the
+	// constraint info bytes
+	ptl.ConstraintInfo = br.readBytes(numBytesConstraintInfo)
+	if len(ptl.ConstraintInfo) > 0 {
+		ptl.FrameOnlyConstraint = ptl.ConstraintInfo[0]&0x80 != 0
+		ptl.MultiLayerEnabled = ptl.ConstraintInfo[0]&0x40 != 0
+	}
+
+	if numSublayers > 1 {
+		present := make([]bool, numSublayers-1)
+		for i := numSublayers - 2; i >= 0; i-- {
+			present[i] = br.readFlag()
+		}
+		br.skipBits(9 - numSublayers) // ptl_reserved_zero_bit
+		ptl.SublayerLevelIdc = make([]uint8, numSublayers-1)
+		for i := numSublayers - 2; i >= 0; i-- {
+			if present[i] {
+				ptl.SublayerLevelIdc[i] = uint8(br.readBits(8))
+			}
+		}
+	}
+	numSubProfiles := int(br.readBits(8))
+	for j := 0; j < numSubProfiles && br.err == nil; j++ {
+		ptl.SubProfileIdc = append(ptl.SubProfileIdc, uint32(br.readBits(32)))
+	}
+	return ptl
+}
+
+// applyVVCConfig fills the track fields derived from vvcC.
+func applyVVCConfig(info *TrackInfo, cfg *VVCDecoderConfig) {
+	info.VVCConfig = cfg
+	if !cfg.PTLPresent {
+		return
+	}
+	info.Profile = VVCProfileName(cfg.PTL.ProfileIdc)
+	info.Level = VVCLevelName(cfg.PTL.LevelIdc, cfg.PTL.TierFlag)
+	info.ChromaFormat = ChromaFormatName(uint32(cfg.ChromaFormatIdc))
+	info.BitDepth = cfg.BitDepth
+
+	// pictures may be smaller than the maximum, so only a larger sample
+	// entry size is suspicious
+	maxWidth, maxHeight := uint32(cfg.MaxPictureWidth), uint32(cfg.MaxPictureHeight)
+	info.CodedWidth, info.CodedHeight = maxWidth, maxHeight
+	if info.Width > maxWidth || info.Height > maxHeight {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("sample entry size %dx%d exceeds vvcC max picture size %dx%d",
+			info.Width, info.Height, maxWidth, maxHeight))
+	}
+}
+
+// VVCProfileName names a general_profile_idc (ITU-T H.266 Table A.1).
+func VVCProfileName(idc uint8) string {
+	switch idc {
+	case 1:
+		return "Main 10"
+	case 65:
+		return "Main 10 Still Picture"
+	case 2:
+		return "Main 12"
+	case 10:
+		return "Main 12 Intra"
+	case 66:
+		return "Main 12 Still Picture"
+	case 17:
+		return "Multilayer Main 10"
+	case 81:
+		return "Multilayer Main 10 Still Picture"
+	case 33:
+		return "Main 10 4:4:4"
+	case 97:
+		return "Main 10 4:4:4 Still Picture"
+	case 34:
+		return "Main 12 4:4:4"
+	case 42:
+		return "Main 12 4:4:4 Intra"
+	case 98:
+		return "Main 12 4:4:4 Still Picture"
+	case 35:
+		return "Main 16 4:4:4"
+	case 43:
+		return "Main 16 4:4:4 Intra"
+	case 99:
+		return "Main 16 4:4:4 Still Picture"
+	case 49:
+		return "Multilayer Main 10 4:4:4"
+	case 113:
+		return "Multilayer Main 10 4:4:4 Still Picture"
+	default:
+		return fmt.Sprintf("Unknown (%d)", idc)
+	}
+}
+
+// VVCLevelName formats general_level_idc (major * 16 + minor * 3) with the
+// tier, e.g. 67 as "4.1 Main tier".
+func VVCLevelName(idc uint8, highTier bool) string {
+	tier := "Main"
+	if highTier {
+		tier = "High"
+	}
+	major, minor := idc/16, idc%16/3
+	if minor == 0 {
+		return fmt.Sprintf("%d %s tier", major, tier)
+	}
+	return fmt.Sprintf("%d.%d %s tier", major, minor, tier)
+}
@@ -10,12 +10,25 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "extract-subs" {
+		extractSubs(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "extract-chapters" {
+		extractChapters(os.Args[2:])
+		return
+	}
+
 	filename := flag.String("f", "", "MP4 file path")
 	verbose := flag.Bool("v", false, "Display detailed track information")
+	events := flag.Bool("e", false, "List timed events (emsg)")
+	chunks := flag.Bool("chunks", false, "Analyze low-latency CMAF chunks (moof/mdat, prft)")
 	flag.Parse()
 
 	if *filename == "" {
-		fmt.Println("usage: mp4parser -f <file_name> [-v]")
+		fmt.Println("usage: mp4parser -f <file_name> [-v] [-e] [-chunks]")
+		fmt.Println("       mp4parser extract-subs -f <file_name> -track <id> [-format srt|vtt|ttml] [-o <out_file>]")
+		fmt.Println("       mp4parser extract-chapters -f <file_name> [-format ffmetadata|vtt|json] [-o <out_file>]")
 		fmt.Println("example: mp4parser -f video.mp4")
 		return
 	}
@@ -47,20 +60,308 @@ func main() {
 	// print metadata
 	mp4.PrintMetadata(metadata)
 
+	// print iTunes style tags
+	if tags := parser.GetTags(); tags != nil && len(tags.Items) > 0 {
+		fmt.Println()
+		mp4.PrintTags(tags)
+	}
+
+	// print chapters
+	if chapters := parser.GetChapters(); len(chapters) > 0 {
+		fmt.Println("\nChapters:")
+		for i, c := range chapters {
+			fmt.Printf("  %d. %s - %s  %s\n", i+1, mp4.FormatDuration(c.Start), mp4.FormatDuration(c.End), c.Title)
+		}
+	}
+
 	// if verbose mode is enabled, print track information
 	if *verbose {
 		fmt.Println("\n=== detailed track information ===")
+		mvhd := parser.GetMovieHeader()
+		fmt.Printf("movie: timescale %d, rate %.2f, volume %.2f, next track ID %d\n",
+			mvhd.Timescale, mvhd.PlaybackRate(), mvhd.PlaybackVolume(), mvhd.NextTrackID)
+		for _, w := range parser.GetWarnings() {
+			fmt.Printf("WARNING: %s\n", w)
+		}
 		tracks := parser.GetTracks()
 		for i, track := range tracks {
 			fmt.Printf("\ntrack %d:\n", i+1)
 			fmt.Printf("  ID: %d\n", track.TrackID)
 			fmt.Printf("  Type: %s\n", track.HandlerType)
-			fmt.Printf("  Codec: %s\n", track.Codec)
+			if track.CodecName != "" {
+				fmt.Printf("  Codec: %s (%s)\n", track.Codec, track.CodecName)
+			} else {
+				fmt.Printf("  Codec: %s\n", track.Codec)
+			}
+			h := track.Header
+			fmt.Printf("  Flags: enabled %t, in movie %t, in preview %t", h.Enabled(), h.InMovie(), h.InPreview())
+			if h.SizeIsAspectRatio() {
+				fmt.Printf(", size is aspect ratio")
+			}
+			fmt.Println()
+			if h.Layer != 0 {
+				fmt.Printf("  Layer: %d\n", h.Layer)
+			}
+			if h.AlternateGroup != 0 {
+				fmt.Printf("  Alternate Group: %d", h.AlternateGroup)
+				if alts := parser.GetAlternates(track.TrackID); len(alts) > 0 {
+					fmt.Printf(" (alternatives: tracks %v)", alts)
+				}
+				fmt.Println()
+			}
+			if track.HandlerType == "soun" {
+				fmt.Printf("  Volume: %.2f\n", h.TrackVolume())
+			}
+			for _, ref := range track.References {
+				fmt.Printf("  Reference: %s (%s) -> tracks %v\n", ref.Type, mp4.TrackReferenceTypeName(ref.Type), ref.TrackIDs)
+			}
+			for _, r := range parser.GetTrackRelations() {
+				if r.To == track.TrackID {
+					fmt.Printf("  Referenced By: track %d as %s (%s)\n", r.From, r.Type, mp4.TrackReferenceTypeName(r.Type))
+				}
+			}
 
 			if track.HandlerType == "vide" {
 				fmt.Printf("  Resolution: %d × %d\n", track.Width, track.Height)
 			}
 
+			if track.Profile != "" {
+				fmt.Printf("  Profile: %s\n", track.Profile)
+			}
+			if track.Level != "" {
+				fmt.Printf("  Level: %s\n", track.Level)
+			}
+			if c := track.AVCConfig; c != nil {
+				fmt.Printf("  AVC Config: NAL length %d, %d SPS, %d PPS, chroma format %d, bit depth %d/%d\n",
+					c.NALULengthSize, len(c.SPS), len(c.PPS), c.ChromaFormat, c.BitDepthLuma, c.BitDepthChroma)
+			}
+			if c := track.HEVCConfig; c != nil {
+				fmt.Printf("  HEVC Config: NAL length %d, %d VPS, %d SPS, %d PPS, chroma format %d, bit depth %d/%d\n",
+					c.NALULengthSize, len(c.NALUnits(0x20)), len(c.NALUnits(0x21)), len(c.NALUnits(0x22)),
+					c.ChromaFormatIdc, c.BitDepthLuma, c.BitDepthChroma)
+			}
+			if c := track.AV1Config; c != nil {
+				fmt.Printf("  AV1 Config: version %d, tier %d, bit depth %d, %d bytes of config OBUs\n",
+					c.Version, c.SeqTier, c.BitDepth(), len(c.ConfigOBUs))
+			}
+			if c := track.VPConfig; c != nil {
+				fmt.Printf("  VP Config: version %d, profile %d, level %d, bit depth %d, %d bytes of init data\n",
+					c.Version, c.Profile, c.Level, c.BitDepth, len(c.CodecInitializationData))
+			}
+			if c := track.VVCConfig; c != nil {
+				fmt.Printf("  VVC Config: NAL length %d, %d VPS, %d SPS, %d PPS, %d sublayers, max picture %d × %d\n",
+					c.NALULengthSize, len(c.NALUnits(14)), len(c.NALUnits(15)), len(c.NALUnits(16)),
+					c.NumSublayers, c.MaxPictureWidth, c.MaxPictureHeight)
+			}
+			if c := track.DolbyVision; c != nil {
+				fmt.Printf("  Dolby Vision: %s v%d.%d, profile %s, level %s, RPU %t, EL %t, BL %t, BL compatibility %d\n",
+					c.BoxType, c.VersionMajor, c.VersionMinor, c.ProfileName(), c.LevelName(),
+					c.RPUPresent, c.ELPresent, c.BLPresent, c.BLSignalCompatibilityID)
+			}
+			if !track.DisplayMatrix.IsIdentity() {
+				fmt.Printf("  Transform: %s\n", track.Orientation)
+			}
+			if track.DisplayWidth > 0 && (track.DisplayWidth != track.Width || track.DisplayHeight != track.Height) {
+				fmt.Printf("  Display Size: %d × %d\n", track.DisplayWidth, track.DisplayHeight)
+			}
+			if track.DisplayAspect > 0 {
+				fmt.Printf("  Display Aspect Ratio: %s\n", mp4.AspectRatioName(track.DisplayAspect))
+			}
+			if p := track.PixelAspect; p != nil {
+				fmt.Printf("  Pixel Aspect Ratio: %d:%d\n", p.HSpacing, p.VSpacing)
+			}
+			if c := track.CleanAperture; c != nil {
+				fmt.Printf("  Clean Aperture: %.2f × %.2f, offset %d/%d, %d/%d\n",
+					c.Width(), c.Height(), c.HorizOffN, c.HorizOffD, c.VertOffN, c.VertOffD)
+			}
+			if track.FieldOrder != nil {
+				fmt.Printf("  Field Order: %s\n", track.FieldOrder)
+			}
+			if c := track.Colr; c != nil && c.ICCProfile != nil {
+				fmt.Printf("  ICC Profile: %s, %d bytes\n", c.ColourType, len(c.ICCProfile))
+			}
+			if m := track.MasteringDisplay; m != nil {
+				fmt.Printf("  Mastering Display: %s\n", m)
+			}
+			if c := track.ContentLightLevel; c != nil {
+				fmt.Printf("  Content Light Level: MaxCLL %d cd/m², MaxFALL %d cd/m²\n", c.MaxCLL, c.MaxFALL)
+			}
+			if track.HDRFormat != "" {
+				fmt.Printf("  HDR: %s\n", track.HDRFormat)
+			}
+			if track.CodedWidth > 0 && (track.CodedWidth != track.Width || track.CodedHeight != track.Height) {
+				fmt.Printf("  Coded Size: %d × %d\n", track.CodedWidth, track.CodedHeight)
+			}
+			if track.ChromaFormat != "" {
+				fmt.Printf("  Chroma: %s, %d bit\n", track.ChromaFormat, track.BitDepth)
+			}
+			if sps := track.H264SPS; sps != nil {
+				fmt.Printf("  SPS: frame_mbs_only %t, ref frames %d", sps.FrameMbsOnly, sps.MaxNumRefFrames)
+				if vui := sps.VUI; vui != nil {
+					if w, h := vui.SAR(); w > 0 {
+						fmt.Printf(", SAR %d:%d", w, h)
+					}
+					if fps := sps.FrameRate(); fps > 0 {
+						fmt.Printf(", %.3f fps (%d/%d)", fps, vui.TimeScale, 2*vui.NumUnitsInTick)
+					}
+					if vui.BitstreamRestriction {
+						fmt.Printf(", max reorder %d", vui.MaxNumReorderFrames)
+					}
+				}
+				fmt.Println()
+			}
+			if sps := track.HEVCSPS; sps != nil {
+				fmt.Printf("  SPS: max dec pic buffering %d, max reorder %d", sps.MaxDecPicBuffering, sps.MaxNumReorderPics)
+				if vui := sps.VUI; vui != nil {
+					if w, h := vui.SAR(); w > 0 {
+						fmt.Printf(", SAR %d:%d", w, h)
+					}
+					if fps := sps.FrameRate(); fps > 0 {
+						fmt.Printf(", %.3f fps (%d/%d)", fps, vui.TimeScale, vui.NumUnitsInTick)
+					}
+				}
+				fmt.Println()
+			}
+			if seq := track.AV1SequenceHeader; seq != nil {
+				fmt.Printf("  Sequence Header: max frame %d × %d, %d operating points, film grain %t",
+					seq.MaxFrameWidth, seq.MaxFrameHeight, seq.OperatingPoints, seq.FilmGrainParamsPresent)
+				if fps := seq.FrameRate(); fps > 0 {
+					fmt.Printf(", %.3f fps", fps)
+				}
+				fmt.Println()
+			}
+			if track.HandlerType == "soun" {
+				fmt.Printf("  Audio: %d Hz, %d channels, %d bit", track.SampleRate, track.Channels, track.SampleSize)
+				if f := mp4.LPCMFormat(track.Codec, track.SampleSize, track.AudioFormatFlags); f != "" {
+					fmt.Printf(" (%s)", f)
+				}
+				if track.Bitrate > 0 {
+					fmt.Printf(", %d kbps", track.Bitrate/1000)
+				}
+				if track.MaxBitrate > 0 {
+					fmt.Printf(" (max %d kbps)", track.MaxBitrate/1000)
+				}
+				fmt.Println()
+			}
+			if track.ChannelLayout != "" {
+				fmt.Printf("  Channel Layout: %s\n", track.ChannelLayout)
+			}
+			if track.AudioObjects > 0 {
+				fmt.Printf("  Audio Objects: %d\n", track.AudioObjects)
+			}
+			if track.Atmos {
+				fmt.Println("  Dolby Atmos: yes")
+			}
+			if c := track.EC3; c != nil {
+				fmt.Printf("  E-AC-3: %d kbps, %d independent substreams", c.DataRate, len(c.Substreams))
+				if c.JOC {
+					fmt.Printf(", JOC complexity %d", c.JOCComplexity)
+				}
+				fmt.Println()
+			}
+			if c := track.AC4; c != nil {
+				fmt.Printf("  AC-4: bitstream version %d, %d presentations\n", c.BitstreamVersion, len(c.Presentations))
+			}
+			if es := track.ESDescriptor; es != nil && es.DecoderConfig != nil {
+				dc := es.DecoderConfig
+				fmt.Printf("  ES Descriptor: ES_ID %d, %s, buffer %d bytes", es.ESID, mp4.ObjectTypeIndicationName(dc.ObjectTypeIndication), dc.BufferSizeDB)
+				if a := dc.AudioConfig; a != nil {
+					fmt.Printf(", %s, %d Hz, channel config %d", mp4.AudioObjectTypeName(a.ObjectType), a.SamplingFrequency, a.ChannelConfiguration)
+					if a.SBR {
+						fmt.Printf(", SBR %d Hz", a.ExtensionSamplingFrequency)
+					}
+					if a.PS {
+						fmt.Printf(", PS")
+					}
+				}
+				fmt.Println()
+			}
+			if c := track.Opus; c != nil {
+				fmt.Printf("  Opus: %d channels, pre-skip %d, input rate %d Hz, output gain %.2f dB, mapping family %d",
+					c.OutputChannelCount, c.PreSkip, c.InputSampleRate, c.OutputGainDB(), c.ChannelMappingFamily)
+				if c.ChannelMappingFamily != 0 {
+					fmt.Printf(" (%d streams, %d coupled, mapping %v)", c.StreamCount, c.CoupledCount, c.ChannelMapping)
+				}
+				fmt.Println()
+			}
+			if c := track.FLAC; c != nil {
+				si := c.StreamInfo
+				fmt.Printf("  FLAC: block size %d-%d, frame size %d-%d, %d samples, %d metadata blocks\n",
+					si.MinBlockSize, si.MaxBlockSize, si.MinFrameSize, si.MaxFrameSize, si.TotalSamples, len(c.Blocks))
+				if c.Vendor != "" {
+					fmt.Printf("  FLAC Vendor: %s\n", c.Vendor)
+				}
+				for _, comment := range c.Comments {
+					fmt.Printf("  FLAC Comment: %s\n", comment)
+				}
+			}
+			if c := track.ALAC; c != nil {
+				fmt.Printf("  ALAC: frame length %d, max frame %d bytes, avg bitrate %d\n",
+					c.FrameLength, c.MaxFrameBytes, c.AvgBitRate)
+			}
+			if c := track.TimedText; c != nil {
+				fmt.Printf("  Timed Text: display flags 0x%x, justification %d/%d, text box %v, font size %d",
+					c.DisplayFlags, c.HorizontalJustification, c.VerticalJustification, c.DefaultTextBox, c.DefaultStyle.FontSize)
+				if name := c.Fonts[c.DefaultStyle.FontID]; name != "" {
+					fmt.Printf(", font %s", name)
+				} else if c.FontName != "" {
+					fmt.Printf(", font %s", c.FontName)
+				}
+				fmt.Println()
+			}
+			if c := track.WebVTT; c != nil {
+				fmt.Printf("  WebVTT: %q\n", c.Config)
+			}
+			if c := track.XMLSubtitle; c != nil {
+				fmt.Printf("  TTML: namespace %s", c.Namespace)
+				if c.SchemaLocation != "" {
+					fmt.Printf(", schema %s", c.SchemaLocation)
+				}
+				if c.AuxiliaryMIMETypes != "" {
+					fmt.Printf(", auxiliary %s", c.AuxiliaryMIMETypes)
+				}
+				fmt.Println()
+			}
+			if len(track.Cues) > 0 {
+				fmt.Printf("  Cues: %d\n", len(track.Cues))
+				for i, c := range track.Cues {
+					if i == 3 {
+						fmt.Println("    ...")
+						break
+					}
+					fmt.Printf("    %s --> %s %q\n", c.Start, c.End, c.Text)
+				}
+			}
+			if track.Tags != nil {
+				for _, item := range track.Tags.Items {
+					fmt.Printf("  Tag: %s = %s\n", item.Key, item)
+				}
+			}
+			if tc := track.Timecode; tc != nil {
+				fmt.Printf("  Timecode: %.3f fps (%d frames)", tc.FrameRate(), tc.NumberOfFrames)
+				if tc.DropFrame() {
+					fmt.Printf(", drop frame")
+				}
+				if tc.Wraps24Hours() {
+					fmt.Printf(", 24 hour wrap")
+				}
+				if tc.CounterMode() {
+					fmt.Printf(", counter")
+				}
+				fmt.Println()
+				if tc.Start != "" {
+					fmt.Printf("  Start Timecode: %s\n", tc.Start)
+				} else if tc.HasStart {
+					fmt.Printf("  Start Counter: %d\n", tc.StartFrame)
+				}
+				if tc.ReelName != "" {
+					fmt.Printf("  Reel Name: %s\n", tc.ReelName)
+				}
+			}
+			if track.Color != nil {
+				fmt.Printf("  Color: %s (%s)\n", track.Color, track.Color.Source)
+			}
+
 			if track.Timescale > 0 {
 				duration := float64(track.Duration) / float64(track.Timescale)
 				fmt.Printf("  Duration: %.2f seconds\n", duration)
@@ -73,6 +374,111 @@ func main() {
 			if track.Language != "" && track.Language != "und" {
 				fmt.Printf("  Language: %s\n", track.Language)
 			}
+
+			for _, w := range track.Warnings {
+				fmt.Printf("  WARNING: %s\n", w)
+			}
 		}
 	}
+
+	// if events mode is enabled, list emsg boxes
+	if *events {
+		fmt.Println()
+		mp4.PrintEvents(parser.GetEvents())
+	}
+
+	// if chunks mode is enabled, analyze movie fragments
+	if *chunks {
+		fmt.Println()
+		mp4.PrintChunks(parser.AnalyzeChunks(), parser.GetProducerReferenceTimes())
+	}
+}
+
+// extract-subs subcommand: write the cues of a subtitle track to a file
+func extractSubs(args []string) {
+	fs := flag.NewFlagSet("extract-subs", flag.ExitOnError)
+	filename := fs.String("f", "", "MP4 file path")
+	trackID := fs.Uint("track", 0, "subtitle track ID")
+	format := fs.String("format", "srt", "output format: srt, vtt or ttml")
+	output := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	if *filename == "" || *trackID == 0 {
+		fmt.Println("usage: mp4parser extract-subs -f <file_name> -track <id> [-format srt|vtt|ttml] [-o <out_file>]")
+		return
+	}
+	parser, w := openForExtract(*filename, *output)
+	if err := parser.ExtractSubtitles(uint32(*trackID), *format, w); err != nil {
+		fmt.Fprintf(os.Stderr, "extract subtitles failed: %v\n", err)
+		removeOutput(w)
+		os.Exit(1)
+	}
+	closeOutput(w)
+}
+
+// extract-chapters subcommand: write the chapters of a file
+func extractChapters(args []string) {
+	fs := flag.NewFlagSet("extract-chapters", flag.ExitOnError)
+	filename := fs.String("f", "", "MP4 file path")
+	format := fs.String("format", "ffmetadata", "output format: ffmetadata, vtt or json")
+	output := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	if *filename == "" {
+		fmt.Println("usage: mp4parser extract-chapters -f <file_name> [-format ffmetadata|vtt|json] [-o <out_file>]")
+		return
+	}
+	parser, w := openForExtract(*filename, *output)
+	if err := parser.ExtractChapters(*format, w); err != nil {
+		fmt.Fprintf(os.Stderr, "extract chapters failed: %v\n", err)
+		removeOutput(w)
+		os.Exit(1)
+	}
+	closeOutput(w)
+}
+
+// openForExtract parses a file for the extract subcommands and opens their
+// output, stdout when output is empty. It exits on errors.
+func openForExtract(filename, output string) (*mp4.MP4Parser, *os.File) {
+	parser, err := mp4.NewParser(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create parser failed: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := parser.Parse(); err != nil {
+		fmt.Fprintf(os.Stderr, "parse file failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "" {
+		return parser, os.Stdout
+	}
+	w, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create output failed: %v\n", err)
+		os.Exit(1)
+	}
+	return parser, w
+}
+
+// closeOutput closes the output of an extract subcommand. A failed close
+// can mean the data never reached the file, so it exits with an error.
+func closeOutput(w *os.File) {
+	if err := w.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "close output failed: %v\n", err)
+		if w != os.Stdout {
+			os.Remove(w.Name())
+		}
+		os.Exit(1)
+	}
+}
+
+// removeOutput closes and deletes the partly written output file of a
+// failed extract subcommand.
+func removeOutput(w *os.File) {
+	if w == os.Stdout {
+		return
+	}
+	w.Close()
+	os.Remove(w.Name())
 }